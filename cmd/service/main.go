@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"os"
 	_ "url-shortener/config"
 	"url-shortener/internal/pkg/database"
 	"url-shortener/internal/router"
+	"url-shortener/internal/service"
 	rbacv1 "url-shortener/pkg/apis/rbac/v1"
 	etcdv3 "url-shortener/pkg/etcd/v3"
 
@@ -18,6 +20,15 @@ func main() {
 	database.InitMysqlDB()
 	etcdv3.InitEtcd()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := service.InitURLChecks(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load domain lists")
+	}
+	service.StartHealthChecker(ctx)
+	service.StartMetadataFetcher(ctx)
+	service.StartClickRetention(ctx)
+
 	defer func() {
 		var err error
 		if err = etcdv3.CloseEtcd(); err != nil {
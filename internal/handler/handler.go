@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"sync"
+	"time"
 
 	"url-shortener/internal/pkg/database"
 	"url-shortener/internal/pkg/util"
@@ -38,77 +39,268 @@ func HandleCreateUserShortURL(c *gin.Context) {
 	service.UserShortCodeCreater(c)
 }
 
+// HandleBulkCreateUserShortURLs is an API for creating many short URLs at once.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: POST http://localhost:8080/auth/short/bulk
+//
+// The body is a JSON array of the objects accepted by HandleCreateUserShortURL,
+// or a CSV file (text/csv body or multipart "file" field) as follows:
+//
+//	long_url,alias,expire_at,tags,title
+//	https://www.example.com,spring,2030-01-01T00:00:00Z,campaign;mail,Spring sale
+//
+// Return JSON format as follows:
+//
+//	{
+//	    "created": 1,
+//	    "failed": 0,
+//	    "results": [{"row": 1, "original_url": "https://www.example.com", "short_url": "spring"}]
+//	}
+func HandleBulkCreateUserShortURLs(c *gin.Context) {
+	service.BulkShortCodeCreater(c)
+}
+
+// HandleExportUserShortURLs is an API for downloading the links of the user.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: GET http://localhost:8080/auth/short/export?format=csv
+//
+// format is one of csv, ndjson or html (browser bookmarks). Admins may add all=true
+// to export every link in the system.
+func HandleExportUserShortURLs(c *gin.Context) {
+	service.ExportLinks(c)
+}
+
+// HandleImportUserShortURLs is an API for importing links exported by
+// HandleExportUserShortURLs or a browser bookmark file.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: POST http://localhost:8080/auth/short/import?on_conflict=rename
+//
+// The file is the request body or the multipart "file" field. Short codes are kept
+// when they are free; on_conflict=skip (default) reports taken codes, on_conflict=rename
+// gives those links a new code. The response is the same as HandleBulkCreateUserShortURLs.
+func HandleImportUserShortURLs(c *gin.Context) {
+	service.ImportLinks(c)
+}
+
+// HandleAdminImportShortURLs is an API for migrating links from other shorteners.
+// Requires Authorization and refresh_token in the HTTP header, and the "import urls"
+// permission in the default namespace.
+//
+// Send http request, for example: POST http://localhost:8080/auth/admin/import?format=yourls-sql&owner=<user_id>
+//
+// format is one of csv, ndjson, html, yourls-sql, yourls-csv or bitly. Any other CSV
+// file can be read with a column mapping, e.g. columns=code=Keyword,url=Target,clicks=Hits.
+// Original codes are kept where possible and click totals are carried over.
+func HandleAdminImportShortURLs(c *gin.Context) {
+	service.AdminImportLinks(c)
+}
+
 // HandleRedirectUserCode handles redirection from a short URL to the original URL.
 // Requires Authorization and refresh_token in the HTTP header.
 //
 // Send http request, for example: POST http://localhost:8080/auth/abc123
+//
+// Mobile visitors are sent to the app link of the short URL when one is set.
+// Appending "+" to the code, or sending "Accept: application/json", returns
+// a preview of the link instead of redirecting.
 func HandleRedirectUserCode(c *gin.Context) {
-	shortCode := c.Param("code")
+	shortCode, preview := service.ParsePreview(c)
 
-	originalURL, err := database.GetOriginalURLByShortCode(shortCode)
+	shortURL, err := database.FindUserShortURL(shortCode)
 	if err != nil {
-		if err.Error() == "user short URL has expired" {
-			log.Warn().Str("shortCode", shortCode).Msg("Short URL has expired")
-			c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
-			return
-		}
 		log.Warn().Str("shortCode", shortCode).Msg("Failed to get original URL for shortCode ")
-		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		service.RespondLinkError(c, http.StatusNotFound, "URL not found", nil)
 		return
 	}
 
-	clientIP := c.ClientIP()
-	log.Info().Str("IP", clientIP).Msg("User IP")
-
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := database.LogUserAccess(shortCode, clientIP); err != nil {
-			log.Warn().Str("shortCode", shortCode).Msg("Failed to log access for shortCode ")
-		}
-	}()
-	wg.Wait()
-
-	log.Info().Str("shortCode", shortCode).Str("original URL", originalURL).Msg("Redirecting shortCode ")
-	c.Redirect(http.StatusFound, originalURL)
+	serveUserLink(c, shortURL, preview)
 }
 
 // Public short URL redirection handle.
 // This handle is used to redirect public short URLs.
 // It does not require any authentication or authorization.
+//
+// Mobile visitors are sent to the app link of the short URL when one is set.
+// Appending "+" to the code, or sending "Accept: application/json", returns
+// a preview of the link instead of redirecting.
 func HandleRedirectPublicCode(c *gin.Context) {
-	shortCode := c.Param("code")
+	shortCode, preview := service.ParsePreview(c)
 
-	originalURL, err := database.GetPublicShortURLByShortCode(shortCode)
+	publicShortURL, err := database.FindPublicShortURL(shortCode)
 	if err != nil {
-		if err.Error() == "public short URL has expired" {
-			log.Warn().Str("shortCode", shortCode).Msg("Public short URL has expired")
-			c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		log.Warn().Str("shortCode", shortCode).Msg("Failed to get original URL for shortCode ")
+		service.RespondLinkError(c, http.StatusNotFound, "URL not found", nil)
+		return
+	}
+
+	servePublicLink(c, publicShortURL, preview)
+}
+
+// HandleRedirectCode is the top-level redirect handle, it is served at the domain root.
+// It does not require any authentication or authorization.
+//
+// Send http request, for example: GET http://localhost:8080/abc123
+//
+// It resolves both public and user short URLs, so browsers can follow any short link
+// with a plain GET. Private and namespace links need the Authorization header.
+// Codes of link-in-bio pages render the page.
+//
+// On a verified custom domain only the links of that domain are resolved, by their
+// code on the domain. Unknown codes go to the 404 page of the domain when it has one.
+func HandleRedirectCode(c *gin.Context) {
+	shortCode, preview := service.ParsePreview(c)
+
+	if domain, ok := service.RequestDomain(c); ok {
+		shortURL, err := database.FindDomainShortURL(domain.ID, shortCode)
+		if err != nil {
+			log.Warn().Str("host", domain.Host).Str("shortCode", shortCode).Msg("Failed to get original URL for shortCode ")
+			if !service.DomainNotFound(c) {
+				service.RespondLinkError(c, http.StatusNotFound, "URL not found", nil)
+			}
 			return
 		}
+		serveUserLink(c, shortURL, preview)
+		return
+	}
+
+	if publicShortURL, err := database.FindPublicShortURL(shortCode); err == nil {
+		servePublicLink(c, publicShortURL, preview)
+		return
+	}
+
+	if shortURL, err := database.FindUserShortURL(shortCode); err == nil {
+		serveUserLink(c, shortURL, preview)
+		return
+	}
+
+	bioPage, err := database.GetBioPageByCode(shortCode)
+	if err != nil {
 		log.Warn().Str("shortCode", shortCode).Msg("Failed to get original URL for shortCode ")
-		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		service.RespondLinkError(c, http.StatusNotFound, "URL not found", nil)
 		return
 	}
+	service.RenderBioPage(c, bioPage)
+}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := database.LogPublicAccess(shortCode); err != nil {
-			log.Warn().Str("shortCode", shortCode).Msg("Failed to log access for shortCode ")
-		}
-	}()
-	wg.Wait()
+// HandleRedirectRoot serves the root of a short domain.
+// It does not require any authentication or authorization.
+//
+// Send http request, for example: GET https://go.example.com/
+//
+// Custom domains with a root URL redirect there, anything else is 404.
+func HandleRedirectRoot(c *gin.Context) {
+	service.DomainRoot(c)
+}
+
+// serveUserLink previews or redirects a user short URL and logs the access with the client IP.
+func serveUserLink(c *gin.Context, shortURL database.UserShortURL, preview bool) {
+	clientIP := c.ClientIP()
+	log.Info().Str("IP", clientIP).Msg("User IP")
+
+	serveLink(c, shortURL, preview, func() error {
+		return database.LogUserAccess(shortURL.ShortCode, clientIP)
+	})
+}
+
+// servePublicLink previews or redirects a public short URL and logs the access.
+func servePublicLink(c *gin.Context, publicShortURL database.PublicShortURL, preview bool) {
+	serveLink(c, publicShortURL, preview, func() error {
+		return database.LogPublicAccess(publicShortURL.ShortCode)
+	})
+}
+
+// serveLink previews or redirects link, after checking its visibility or the
+// signed URL of the request, whether it has been disabled and its activation window.
+//
+// Errors are reported as HTML to browsers and as JSON to API clients. Links
+// that are not active yet, or expired, send visitors to their pre-launch or
+// expired URL when they have one.
+//
+// A signed URL only counts as used when the request is redirected.
+//
+// logAccess records the visit. Previews, social cards served to link preview
+// crawlers and HEAD requests only inspect the link, so they are not counted as access,
+// neither are visitors turned away by the redirect rate limit of the link.
+func serveLink(c *gin.Context, link database.Link, preview bool, logAccess func() error) {
+	shortCode := link.GetShortCode()
+
+	// links the caller may not see are reported as missing, so their existence is not leaked.
+	// A signed URL stands in for the JWT of an allowed caller.
+	if !service.CanAccess(c, link) && !service.SignedAccess(c, link) {
+		log.Warn().Str("shortCode", shortCode).Msg("Caller is not allowed to resolve shortCode ")
+		service.RespondLinkError(c, http.StatusNotFound, "URL not found", nil)
+		return
+	}
+
+	// disabled links are checked first, the pre-launch and expired URLs may be blocked too
+	if service.LinkDisabled(link) {
+		log.Warn().Str("shortCode", shortCode).Msg("Short URL is disabled")
+		service.RespondLinkError(c, http.StatusGone, "URL disabled", link)
+		return
+	}
+
+	switch service.LinkStatus(link, time.Now()) {
+	case service.StatusScheduled:
+		log.Warn().Str("shortCode", shortCode).Msg("Short URL is not active yet")
+		service.LinkScheduled(c, link)
+		return
+	case service.StatusExpired:
+		log.Warn().Str("shortCode", shortCode).Msg("Short URL has expired")
+		service.LinkExpired(c, link)
+		return
+	}
+
+	if preview {
+		service.Preview(c, link)
+		return
+	}
+
+	// link preview crawlers get the card of the link and are not counted as clicks
+	if service.SocialCard(c, link) {
+		return
+	}
+
+	// capped links protect their destination, HEAD requests never reach it
+	if c.Request.Method != http.MethodHead && service.Throttle(c, link) {
+		log.Warn().Str("shortCode", shortCode).Msg("Short URL is over its rate limit")
+		return
+	}
+
+	// only a redirect counts as a use of the signed URL, which may have been used up meanwhile
+	if !service.UseSignedAccess(c, link) {
+		log.Warn().Str("shortCode", shortCode).Msg("Signed URL is used up")
+		service.RespondLinkError(c, http.StatusNotFound, "URL not found", nil)
+		return
+	}
+
+	if c.Request.Method != http.MethodHead {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := logAccess(); err != nil {
+				log.Warn().Str("shortCode", shortCode).Msg("Failed to log access for shortCode ")
+			}
+		}()
+		wg.Wait()
+	}
+
+	originalURL := service.ResolveVariant(c, shortCode, link.GetOriginalURL())
+	originalURL = service.BuildDestination(c, link, originalURL)
 	log.Info().Str("shortCode", shortCode).Str("original URL", originalURL).Msg("Redirecting shortCode ")
-	c.Redirect(http.StatusFound, originalURL)
+	service.Redirect(c, link, originalURL)
 }
 
 // HandleGetUserShortURLs retrieves all short URLs created by the user.
 // Requires Authorization and refresh_token in the HTTP header.
 //
 // It returns a list of all short URLs that owned by the user in JSON format.
+// With ?detail=true, every entry carries its metadata and destination health,
+// broken destinations are flagged with "broken": true. The detail listing also
+// keeps the scheduled and expired links, each entry carries its "status".
 func HandleGetUserShortURLs(c *gin.Context) {
 	userID, exist := c.Get("user_id")
 	if !exist {
@@ -124,6 +316,17 @@ func HandleGetUserShortURLs(c *gin.Context) {
 		return
 	}
 
+	if c.Query("detail") == "true" {
+		shortURLs, err := database.ListUserShortURLs(userIDStr)
+		if err != nil {
+			log.Warn().Str("userID", userIDStr).Msg("Failed to get short URLs for userID ")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get short URLs"})
+			return
+		}
+		c.JSON(http.StatusOK, service.SummarizeAllLinks(shortURLs))
+		return
+	}
+
 	shortURLs, err := database.GetUserShortURLsByUserID(userIDStr)
 	if err != nil {
 		log.Warn().Str("userID", userIDStr).Msg("Failed to get short URLs for userID ")
@@ -143,7 +346,19 @@ func HandleGetUserShortURLs(c *gin.Context) {
 // It does not require any authentication or authorization.
 //
 // It returns a list of public short URLs that are available to all users in JSON format.
+// With ?detail=true, every entry carries its metadata and destination health.
 func HandleGetAllPublicShortURLs(c *gin.Context) {
+	if c.Query("detail") == "true" {
+		links, err := database.ListListedShortURLs()
+		if err != nil {
+			log.Warn().Msg("Failed to get all public short URLs")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get all public short URLs"})
+			return
+		}
+		c.JSON(http.StatusOK, service.SummarizeLinks(links))
+		return
+	}
+
 	publicShortURLs, err := database.GetAllPublicShortURLs()
 	if err != nil {
 		log.Warn().Msg("Failed to get all public short URLs")
@@ -178,10 +393,34 @@ func HandleGetAllPublicShortURLs(c *gin.Context) {
 //	}
 //
 // The short URL will expire in 90 days. This is default expiration time.
+//
+// Anonymous creation is rate limited per client IP and per subnet. When enabled in
+// the anonymous config, the request must also carry a solved proof-of-work challenge
+// (X-Pow-Challenge and X-Pow-Nonce headers, see HandleIssueChallenge) and a captcha
+// token (X-Captcha-Token header).
 func HandleCreatePublicShortURL(c *gin.Context) {
 	service.PublicShortCodeCreater(c)
 }
 
+// HandleIssueChallenge hands out a proof-of-work challenge for HandleCreatePublicShortURL.
+// It does not require any authentication or authorization.
+//
+// Send http request, for example: GET http://localhost:8080/public/challenge
+//
+// Return JSON format as follows:
+//
+//	{
+//	    "challenge": "...",
+//	    "difficulty": 20,
+//	    "expires_at": "2030-01-01T00:02:00Z"
+//	}
+//
+// The client finds a nonce such that SHA-256(challenge + ":" + nonce) starts with
+// difficulty zero bits. Every challenge can be used for one short URL.
+func HandleIssueChallenge(c *gin.Context) {
+	service.IssueChallenge(c)
+}
+
 // HandleDeletePublicShortURL is an API for deleting a public short URL.
 // It does not require any authentication or authorization.
 // Send http request, for example:
@@ -209,6 +448,268 @@ func HandleDeletePublicShortURL(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Public short URL deleted successfully"})
 }
 
+// HandleGetUserShortURLStats reports the access count of a user short URL
+// and the click split between its A/B variants.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: GET http://localhost:8080/auth/short/abc123/stats
+//
+// Return JSON format as follows:
+//
+//	{
+//	    "short_url": "abc123",
+//	    "access_count": 10,
+//	    "variants": [
+//	        {"id": 1, "original_url": "https://a.example.com", "weight": 70, "access_count": 7, "share": 0.7}
+//	    ],
+//	    "rate_limit": {"rate": 50, "burst": 100, "throttled": 12}
+//	}
+//
+// rate_limit is only reported for links with a redirect rate limit.
+func HandleGetUserShortURLStats(c *gin.Context) {
+	shortURL, ok := ownedUserShortURL(c)
+	if !ok {
+		return
+	}
+	shortCode := shortURL.ShortCode
+
+	variants, err := service.VariantStats(shortCode)
+	if err != nil {
+		log.Warn().Str("shortCode", shortCode).Msg("Failed to get variant stats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats"})
+		return
+	}
+
+	stats := gin.H{
+		"short_url":    shortCode,
+		"access_count": shortURL.AccessCount,
+		"variants":     variants,
+	}
+	if rateLimit := service.LinkRateLimitStats(c.Request.Context(), shortURL); rateLimit != nil {
+		stats["rate_limit"] = rateLimit
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// HandleGetPublicShortURLStats reports the access count of a public short URL
+// and the click split between its A/B variants.
+// It does not require any authentication or authorization, so the redirect rate
+// limit of the link is left out, and disabled links are reported as gone.
+//
+// Send http request, for example: GET http://localhost:8080/public/short/abc123/stats
+func HandleGetPublicShortURLStats(c *gin.Context) {
+	shortCode := c.Param("code")
+
+	publicShortURL, err := database.FindPublicShortURL(shortCode)
+	if err != nil {
+		log.Warn().Str("shortCode", shortCode).Msg("Public short URL not found")
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+	if service.LinkDisabled(publicShortURL) {
+		log.Warn().Str("shortCode", shortCode).Msg("Short URL is disabled")
+		c.JSON(http.StatusGone, gin.H{"error": "URL disabled"})
+		return
+	}
+
+	variants, err := service.VariantStats(shortCode)
+	if err != nil {
+		log.Warn().Str("shortCode", shortCode).Msg("Failed to get variant stats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats"})
+		return
+	}
+
+	stats := gin.H{
+		"short_url":    shortCode,
+		"access_count": publicShortURL.AccessCount,
+		"variants":     variants,
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// HandlePublicQRCode is an API for the QR code of a public short URL.
+//
+// Send http request, for example: GET http://localhost:8080/public/abc123/qr?format=svg&size=512
+//
+// Query parameters: format (png or svg), size (pixels), level (L, M, Q, H),
+// margin (modules), fg and bg (hex colors).
+// Disabled links and links outside their activation window get the error of
+// their redirect instead.
+func HandlePublicQRCode(c *gin.Context) {
+	shortCode := c.Param("code")
+
+	publicShortURL, err := database.GetPublicShortURLByCode(shortCode)
+	if err != nil {
+		log.Warn().Str("shortCode", shortCode).Msg("Public short URL not found")
+		service.RespondLinkError(c, http.StatusNotFound, "URL not found", nil)
+		return
+	}
+
+	// a QR code is printed and cached, so it is only handed out for links that redirect
+	if service.LinkDisabled(publicShortURL) {
+		log.Warn().Str("shortCode", shortCode).Msg("Short URL is disabled")
+		service.RespondLinkError(c, http.StatusGone, "URL disabled", publicShortURL)
+		return
+	}
+	switch service.LinkStatus(publicShortURL, time.Now()) {
+	case service.StatusScheduled:
+		log.Warn().Str("shortCode", shortCode).Msg("Short URL is not active yet")
+		service.RespondLinkError(c, http.StatusNotFound, "URL not active yet", publicShortURL)
+		return
+	case service.StatusExpired:
+		log.Warn().Str("shortCode", shortCode).Msg("Short URL has expired")
+		service.RespondLinkError(c, http.StatusGone, "URL expired", publicShortURL)
+		return
+	}
+
+	service.QRCode(c, publicShortURL)
+}
+
+// HandleUserQRCode is an API for the QR code of a short URL owned by the user.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: GET http://localhost:8080/auth/abc123/qr?level=H
+//
+// It accepts the same query parameters as HandlePublicQRCode.
+func HandleUserQRCode(c *gin.Context) {
+	shortURL, ok := ownedUserShortURL(c)
+	if !ok {
+		return
+	}
+
+	service.QRCode(c, shortURL)
+}
+
+// HandleRefreshUserShortURLMetadata fetches the title, description and favicon
+// of the destination of a user short URL again and stores them on the link.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: POST http://localhost:8080/auth/short/abc123/metadata
+//
+// Return JSON format as follows:
+//
+//	{
+//	    "short_url": "abc123",
+//	    "metadata": {"title": "Example Domain", "favicon": "https://www.example.com/favicon.ico", "fetched_at": "..."}
+//	}
+//
+// A failed fetch is reported in "metadata.error".
+func HandleRefreshUserShortURLMetadata(c *gin.Context) {
+	shortURL, ok := ownedUserShortURL(c)
+	if !ok {
+		return
+	}
+
+	service.RefreshMetadata(c, shortURL)
+}
+
+// HandleCreateCampaign is an API for creating a campaign that groups short URLs.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send JSON format as follows:
+//
+//	{
+//	    "name": "spring-sale",
+//	    "description": "Spring sale newsletter and ads",
+//	    "namespace": "marketing"
+//	}
+//
+// Without a namespace the campaign belongs to the user. A namespace campaign needs
+// the "create campaigns" permission there and is shared with its members.
+func HandleCreateCampaign(c *gin.Context) {
+	service.CreateCampaign(c)
+}
+
+// HandleListCampaigns lists the campaigns of the user, or with ?namespace= the
+// campaigns of a namespace.
+// Requires Authorization and refresh_token in the HTTP header.
+func HandleListCampaigns(c *gin.Context) {
+	service.ListCampaigns(c)
+}
+
+// HandleDeleteCampaign deletes a campaign, its links are kept and detached.
+// Requires Authorization and refresh_token in the HTTP header.
+func HandleDeleteCampaign(c *gin.Context) {
+	service.DeleteCampaign(c)
+}
+
+// HandleAttachCampaignLinks attaches short URLs of the user to a campaign.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: POST http://localhost:8080/auth/campaigns/1/links
+//
+//	{"short_urls": ["abc123", "spring"]}
+func HandleAttachCampaignLinks(c *gin.Context) {
+	service.AttachCampaignLinks(c)
+}
+
+// HandleDetachCampaignLink removes a short URL from a campaign.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: DELETE http://localhost:8080/auth/campaigns/1/links/abc123
+func HandleDetachCampaignLink(c *gin.Context) {
+	service.DetachCampaignLink(c)
+}
+
+// HandleGetCampaignStats reports the aggregated clicks of a campaign.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: GET http://localhost:8080/auth/campaigns/1/stats?interval=day&from=2030-01-01T00:00:00Z
+//
+// Return JSON format as follows:
+//
+//	{
+//	    "campaign": {"id": 1, "name": "spring-sale", "created_at": "..."},
+//	    "from": "...", "to": "...", "interval": "day",
+//	    "clicks": 120,
+//	    "unique_visitors": 87,
+//	    "series": [{"time": "2030-01-01T00:00:00Z", "clicks": 40, "unique_visitors": 31}],
+//	    "links": [{"short_url": "abc123", "original_url": "https://www.example.com", "clicks": 100, "unique_visitors": 70, "access_count": 150}]
+//	}
+func HandleGetCampaignStats(c *gin.Context) {
+	service.CampaignStats(c)
+}
+
+// HandleCreateBioPage is an API for creating a link-in-bio page, a hosted HTML page
+// served at its own short code that lists short URLs of the user.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send JSON format as follows:
+//
+//	{
+//	    "alias": "jane",
+//	    "title": "Jane Doe",
+//	    "avatar_url": "https://cdn.example.com/jane.png",
+//	    "theme": "dark",
+//	    "links": [{"short_url": "abc123", "label": "Portfolio"}]
+//	}
+//
+// theme is one of light (default), dark, ocean or sunset.
+func HandleCreateBioPage(c *gin.Context) {
+	service.CreateBioPage(c)
+}
+
+// HandleListBioPages lists the link-in-bio pages of the user.
+// Requires Authorization and refresh_token in the HTTP header.
+func HandleListBioPages(c *gin.Context) {
+	service.ListBioPages(c)
+}
+
+// HandleUpdateBioPage replaces the title, avatar, theme and links of a page of the user.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: PUT http://localhost:8080/auth/pages/jane
+// with the JSON body of HandleCreateBioPage.
+func HandleUpdateBioPage(c *gin.Context) {
+	service.UpdateBioPage(c)
+}
+
+// HandleDeleteBioPage deletes a page of the user, the listed short URLs are kept.
+// Requires Authorization and refresh_token in the HTTP header.
+func HandleDeleteBioPage(c *gin.Context) {
+	service.DeleteBioPage(c)
+}
+
 // HandleRefreshToken is an API for refreshing the access token.
 // It requires Authorization and refresh_token in the HTTP header.
 // Send http request, for example: POST http://localhost:8080/auth/refresh
@@ -222,3 +723,125 @@ func HandleDeletePublicShortURL(c *gin.Context) {
 func HandleRefreshToken(c *gin.Context) {
 	util.RefreshToken(c)
 }
+
+// HandleCreateDomain is an API for registering a branded short domain.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send JSON format as follows:
+//
+//	{
+//	    "host": "go.example.com",
+//	    "namespace": "marketing",
+//	    "redirect_status": 301,
+//	    "not_found_url": "https://www.example.com/404",
+//	    "root_url": "https://www.example.com"
+//	}
+//
+// Only host is required. The response carries the TXT record that proves the
+// ownership of the domain, see HandleVerifyDomain. A namespace domain needs the
+// "create domains" permission there and is shared with its members.
+func HandleCreateDomain(c *gin.Context) {
+	service.CreateDomain(c)
+}
+
+// HandleListDomains lists the custom domains of the user, or with ?namespace= the
+// domains of a namespace.
+// Requires Authorization and refresh_token in the HTTP header.
+func HandleListDomains(c *gin.Context) {
+	service.ListDomains(c)
+}
+
+// HandleUpdateDomain replaces the redirect status, 404 page and root URL of a domain.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: PUT http://localhost:8080/auth/domains/1
+func HandleUpdateDomain(c *gin.Context) {
+	service.UpdateDomain(c)
+}
+
+// HandleVerifyDomain checks the TXT record of a domain, links can be put on the
+// domain once it is verified.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: POST http://localhost:8080/auth/domains/1/verify
+func HandleVerifyDomain(c *gin.Context) {
+	service.VerifyDomain(c)
+}
+
+// HandleDeleteDomain deletes a domain that has no links.
+// Requires Authorization and refresh_token in the HTTP header.
+func HandleDeleteDomain(c *gin.Context) {
+	service.DeleteDomain(c)
+}
+
+// HandleCreateSignedURL mints a signed, time-limited URL of a user short URL, which
+// can be followed without an account, e.g. to share a private link.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: POST http://localhost:8080/auth/short/abc123/signed
+//
+// Send http request and JSON format as follows, every field is optional:
+//
+//	{
+//	    "ttl": 3600,
+//	    "max_uses": 5
+//	}
+//
+// Return JSON format as follows:
+//
+//	{
+//	    "short_url": "abc123",
+//	    "url": "http://localhost:8080/abc123?sig=...&sig_expires=1893456000&sig_nonce=...",
+//	    "nonce": "...",
+//	    "expires_at": "2030-01-01T00:00:00Z",
+//	    "max_uses": 5
+//	}
+//
+// expires_at may be sent instead of ttl. Signed URLs expire in a day by default.
+func HandleCreateSignedURL(c *gin.Context) {
+	if shortURL, ok := ownedUserShortURL(c); ok {
+		service.CreateSignedURL(c, shortURL)
+	}
+}
+
+// HandleListSignedURLs lists the signed URLs minted for a user short URL, with
+// their uses and status.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: GET http://localhost:8080/auth/short/abc123/signed
+func HandleListSignedURLs(c *gin.Context) {
+	if shortURL, ok := ownedUserShortURL(c); ok {
+		service.ListSignedURLs(c, shortURL)
+	}
+}
+
+// HandleRevokeSignedURL revokes a signed URL of a user short URL by its nonce.
+// Requires Authorization and refresh_token in the HTTP header.
+//
+// Send http request, for example: DELETE http://localhost:8080/auth/short/abc123/signed/<nonce>
+func HandleRevokeSignedURL(c *gin.Context) {
+	if shortURL, ok := ownedUserShortURL(c); ok {
+		service.RevokeSignedURL(c, shortURL)
+	}
+}
+
+// ownedUserShortURL returns the user short URL of the code in the path when it is
+// owned by the caller, otherwise it responds with an error.
+func ownedUserShortURL(c *gin.Context) (database.UserShortURL, bool) {
+	shortCode := c.Param("code")
+
+	userID, exist := c.Get("user_id")
+	if !exist {
+		log.Warn().Msg("user ID not found")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+		return database.UserShortURL{}, false
+	}
+
+	shortURL, err := database.FindUserShortURL(shortCode)
+	if err != nil || shortURL.UserID != userID {
+		log.Warn().Str("shortCode", shortCode).Msg("User short URL not found")
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return database.UserShortURL{}, false
+	}
+	return shortURL, true
+}
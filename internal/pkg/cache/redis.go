@@ -2,11 +2,13 @@ package cache
 
 import (
 	"context"
+	"net"
 	"time"
 	"url-shortener/internal/pkg/database"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 )
 
 var rDB *redis.Client
@@ -33,6 +35,22 @@ func InitRedis() {
 	log.Debug().Msg("Successfully connected to Redis")
 }
 
+// NewRedisClient connects to the Redis of the redis section in the config.
+// The connection is opened lazily, on the first command.
+func NewRedisClient() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:         net.JoinHostPort(viper.GetString("redis.host"), viper.GetString("redis.port")),
+		Password:     viper.GetString("redis.password"),
+		DB:           viper.GetInt("redis.db"),
+		PoolSize:     10,              // 连接池大小
+		MinIdleConns: 5,               // 最小空闲连接数
+		MaxRetries:   3,               // 最大重试次数
+		DialTimeout:  5 * time.Second, // 连接超时时间
+		ReadTimeout:  3 * time.Second, // 读超时时间
+		WriteTimeout: 3 * time.Second, // 写超时时间
+	})
+}
+
 func CloseRedis() {
 	if err := rDB.Close(); err != nil {
 		log.Fatal().Err(err).Msg("Failed to close Redis connection")
@@ -0,0 +1,61 @@
+package captcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSiteVerify(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.PostForm.Get("secret") != "secret" || r.PostForm.Get("remoteip") != "203.0.113.7" {
+			t.Errorf("unexpected siteverify form: %v", r.PostForm)
+		}
+		if r.PostForm.Get("response") == "good" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	defer srv.Close()
+
+	v := SiteVerify{URL: srv.URL, Secret: "secret"}
+	ctx := context.Background()
+	if ok, err := v.Verify(ctx, "good", "203.0.113.7"); !ok || err != nil {
+		t.Fatalf("Verify(good) = %v, %v", ok, err)
+	}
+	if ok, err := v.Verify(ctx, "bad", "203.0.113.7"); ok || err != nil {
+		t.Fatalf("Verify(bad) = %v, %v", ok, err)
+	}
+	if ok, _ := v.Verify(ctx, "", "203.0.113.7"); ok {
+		t.Fatal("Verify(empty) = true")
+	}
+}
+
+func TestSiteVerifyFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if _, err := (SiteVerify{URL: srv.URL}).Verify(context.Background(), "good", ""); err == nil {
+		t.Fatal("Verify() error = nil")
+	}
+}
+
+func TestFake(t *testing.T) {
+	f := Fake{Token: "pass"}
+	if ok, _ := f.Verify(context.Background(), "pass", ""); !ok {
+		t.Error("Fake rejected its token")
+	}
+	if ok, _ := f.Verify(context.Background(), "fail", ""); ok {
+		t.Error("Fake accepted another token")
+	}
+	if ok, _ := (Fake{}).Verify(context.Background(), "", ""); ok {
+		t.Error("Fake without a token accepted an empty token")
+	}
+}
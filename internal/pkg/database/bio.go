@@ -0,0 +1,117 @@
+package database
+
+import (
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// ###### Bio Page Operations ######
+
+// orderedLinks preloads the entries of bio pages in page order.
+func orderedLinks(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+// CreateBioPage creates a bio page with its entries in one transaction.
+func CreateBioPage(page *BioPage) error {
+	if err := mysqlDB.Create(page).Error; err != nil {
+		log.Debug().Msg("Failed to save bio page.")
+		return err
+	}
+	return nil
+}
+
+// UpdateBioPage saves the fields of a bio page and replaces its entries in one transaction.
+func UpdateBioPage(page *BioPage) error {
+	return mysqlDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&BioPage{}).Where("id = ?", page.ID).Updates(map[string]interface{}{
+			"title":       page.Title,
+			"description": page.Description,
+			"avatar_url":  page.AvatarURL,
+			"theme":       page.Theme,
+		}).Error; err != nil {
+			log.Debug().Msg("Failed to update bio page.")
+			return err
+		}
+		if err := tx.Where("page_id = ?", page.ID).Delete(&BioPageLink{}).Error; err != nil {
+			log.Debug().Msg("Failed to delete bio page links.")
+			return err
+		}
+		if len(page.Links) == 0 {
+			return nil
+		}
+		for i := range page.Links {
+			page.Links[i].ID = 0
+			page.Links[i].PageID = page.ID
+		}
+		if err := tx.Create(&page.Links).Error; err != nil {
+			log.Debug().Msg("Failed to save bio page links.")
+			return err
+		}
+		return nil
+	})
+}
+
+// GetBioPageByCode retrieves a bio page and its ordered entries by short code.
+func GetBioPageByCode(shortCode string) (BioPage, error) {
+	var page BioPage
+	if err := mysqlDB.Preload("Links", orderedLinks).Where("short_code = ?", shortCode).First(&page).Error; err != nil {
+		log.Debug().Msg("Bio page not found.")
+		return BioPage{}, err
+	}
+	return page, nil
+}
+
+// ListBioPages retrieves the bio pages of a user with their entries.
+func ListBioPages(userID string) ([]BioPage, error) {
+	var pages []BioPage
+	if err := mysqlDB.Preload("Links", orderedLinks).Where("user_id = ?", userID).Order("id").Find(&pages).Error; err != nil {
+		log.Debug().Msg("Failed to list bio pages.")
+		return nil, err
+	}
+	return pages, nil
+}
+
+// DeleteBioPage deletes a bio page and its entries. The short URLs it lists are kept.
+func DeleteBioPage(page BioPage) error {
+	return mysqlDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("page_id = ?", page.ID).Delete(&BioPageLink{}).Error; err != nil {
+			log.Debug().Msg("Failed to delete bio page links.")
+			return err
+		}
+		if err := tx.Delete(&BioPage{}, page.ID).Error; err != nil {
+			log.Debug().Msg("Failed to delete bio page.")
+			return err
+		}
+		return nil
+	})
+}
+
+// LogBioPageView increments the view count of a bio page.
+func LogBioPageView(shortCode string) error {
+	if err := mysqlDB.Model(&BioPage{}).Where("short_code = ?", shortCode).Updates(map[string]interface{}{
+		"view_count": gorm.Expr("view_count + 1"),
+	}).Error; err != nil {
+		log.Debug().Msg("Failed to update bio page view count.")
+		return err
+	}
+	return nil
+}
+
+// GetUserShortURLsByCodes retrieves the short URLs of the user with the given codes,
+// keyed by short code. Codes the user does not own are missing from the map.
+func GetUserShortURLsByCodes(userID string, shortCodes []string) (map[string]UserShortURL, error) {
+	byCode := make(map[string]UserShortURL, len(shortCodes))
+	if len(shortCodes) == 0 {
+		return byCode, nil
+	}
+	var shortURLs []UserShortURL
+	if err := mysqlDB.Where("user_id = ? AND short_code IN ?", userID, shortCodes).Find(&shortURLs).Error; err != nil {
+		log.Debug().Msg("Failed to get user short URLs by codes.")
+		return nil, err
+	}
+	for _, shortURL := range shortURLs {
+		byCode[shortURL.ShortCode] = shortURL
+	}
+	return byCode, nil
+}
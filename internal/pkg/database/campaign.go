@@ -0,0 +1,177 @@
+package database
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ###### Campaign Operations ######
+
+// CreateCampaign creates a new campaign and sets its ID.
+func CreateCampaign(campaign *Campaign) error {
+	if err := mysqlDB.Create(campaign).Error; err != nil {
+		log.Debug().Msg("Failed to save campaign.")
+		return err
+	}
+	return nil
+}
+
+// GetCampaign retrieves a campaign by ID.
+func GetCampaign(id uint) (Campaign, error) {
+	var campaign Campaign
+	if err := mysqlDB.First(&campaign, id).Error; err != nil {
+		log.Debug().Msg("Campaign not found.")
+		return Campaign{}, err
+	}
+	return campaign, nil
+}
+
+// ListCampaigns retrieves the campaigns owned by the user, or the campaigns of the
+// namespace when namespace is not empty. Newest campaigns come first.
+func ListCampaigns(ownerID, namespace string) ([]Campaign, error) {
+	query := mysqlDB.Where("owner_id = ? AND namespace = ?", ownerID, "")
+	if namespace != "" {
+		query = mysqlDB.Where("namespace = ?", namespace)
+	}
+	var campaigns []Campaign
+	if err := query.Order("id DESC").Find(&campaigns).Error; err != nil {
+		log.Debug().Msg("Failed to list campaigns.")
+		return nil, err
+	}
+	return campaigns, nil
+}
+
+// DeleteCampaign deletes a campaign and detaches its links. Recorded clicks are kept.
+func DeleteCampaign(id uint) error {
+	if err := mysqlDB.Model(&UserShortURL{}).Where("campaign_id = ?", id).Update("campaign_id", 0).Error; err != nil {
+		log.Debug().Msg("Failed to detach campaign links.")
+		return err
+	}
+	if err := mysqlDB.Delete(&Campaign{}, id).Error; err != nil {
+		log.Debug().Msg("Failed to delete campaign.")
+		return err
+	}
+	return nil
+}
+
+// SetLinksCampaign attaches the short URLs of the user to a campaign, campaign ID 0
+// detaches them. Short codes the user does not own are skipped.
+// It returns the number of updated links.
+func SetLinksCampaign(userID string, shortCodes []string, campaignID uint) (int64, error) {
+	if len(shortCodes) == 0 {
+		return 0, nil
+	}
+	result := mysqlDB.Model(&UserShortURL{}).Where("user_id = ? AND short_code IN ?", userID, shortCodes).
+		Update("campaign_id", campaignID)
+	if result.Error != nil {
+		log.Debug().Msg("Failed to set campaign of short URLs.")
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// GetCampaignLinks retrieves the short URLs attached to a campaign, including expired ones.
+func GetCampaignLinks(campaignID uint) ([]UserShortURL, error) {
+	var shortURLs []UserShortURL
+	if err := mysqlDB.Where("campaign_id = ?", campaignID).Order("id").Find(&shortURLs).Error; err != nil {
+		log.Debug().Msg("Failed to get campaign links.")
+		return nil, err
+	}
+	return shortURLs, nil
+}
+
+// ###### Click Operations ######
+
+// ClickBucket is the number of clicks in one time bucket.
+type ClickBucket struct {
+	Bucket   int64 // 时间段起点的 Unix 时间戳（秒）
+	Clicks   int64
+	Visitors int64 // 不同的访客 IP 数
+}
+
+// ClickCount is the number of clicks of one short code.
+type ClickCount struct {
+	ShortCode string
+	Clicks    int64
+	Visitors  int64
+}
+
+// logClick records one click of a user short URL attached to a campaign. Clicks of
+// other links are only counted in their access count.
+func logClick(shortURL UserShortURL, clientIP string) error {
+	if shortURL.CampaignID == 0 {
+		return nil
+	}
+	click := LinkClick{ShortCode: shortURL.ShortCode, CampaignID: shortURL.CampaignID, VisitorIP: clientIP}
+	if err := mysqlDB.Create(&click).Error; err != nil {
+		log.Debug().Msg("Failed to save link click.")
+		return err
+	}
+	return nil
+}
+
+// deleteClicksBatch bounds the rows removed by one statement of DeleteClicksBefore.
+const deleteClicksBatch = 10000
+
+// DeleteClicksBefore deletes the clicks recorded before the given time, in batches
+// so the table is not locked for long. It returns the number of deleted clicks.
+func DeleteClicksBefore(before time.Time) (int64, error) {
+	var deleted int64
+	for {
+		result := mysqlDB.Where("created_at < ?", before).Limit(deleteClicksBatch).Delete(&LinkClick{})
+		if result.Error != nil {
+			log.Debug().Msg("Failed to delete old link clicks.")
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+		if result.RowsAffected < deleteClicksBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// GetCampaignClickSeries counts the clicks of a campaign between from and to in
+// buckets of the given size, aligned to multiples of the size since the Unix epoch.
+// Empty buckets are omitted.
+//
+// The buckets are measured from an anchor passed as a parameter, which the driver
+// converts like created_at, so they do not depend on the session time zone.
+func GetCampaignClickSeries(campaignID uint, from, to time.Time, bucket time.Duration) ([]ClickBucket, error) {
+	seconds := int64(bucket.Seconds())
+	anchor := time.Unix(from.Unix()/seconds*seconds, 0)
+	var buckets []ClickBucket
+	if err := mysqlDB.Model(&LinkClick{}).
+		Select("? + FLOOR(TIMESTAMPDIFF(SECOND, ?, created_at) / ?) * ? AS bucket, COUNT(*) AS clicks, COUNT(DISTINCT visitor_ip) AS visitors",
+			anchor.Unix(), anchor, seconds, seconds).
+		Where("campaign_id = ? AND created_at >= ? AND created_at < ?", campaignID, from, to).
+		Group("bucket").Order("bucket").Scan(&buckets).Error; err != nil {
+		log.Debug().Msg("Failed to get campaign click series.")
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// GetCampaignClickCounts counts the clicks of a campaign between from and to, in
+// total and per short code.
+func GetCampaignClickCounts(campaignID uint, from, to time.Time) (ClickCount, []ClickCount, error) {
+	where := "campaign_id = ? AND created_at >= ? AND created_at < ?"
+
+	var total ClickCount
+	if err := mysqlDB.Model(&LinkClick{}).
+		Select("COUNT(*) AS clicks, COUNT(DISTINCT visitor_ip) AS visitors").
+		Where(where, campaignID, from, to).Scan(&total).Error; err != nil {
+		log.Debug().Msg("Failed to count campaign clicks.")
+		return ClickCount{}, nil, err
+	}
+
+	var perLink []ClickCount
+	if err := mysqlDB.Model(&LinkClick{}).
+		Select("short_code, COUNT(*) AS clicks, COUNT(DISTINCT visitor_ip) AS visitors").
+		Where(where, campaignID, from, to).
+		Group("short_code").Scan(&perLink).Error; err != nil {
+		log.Debug().Msg("Failed to count campaign clicks per link.")
+		return ClickCount{}, nil, err
+	}
+	return total, perLink, nil
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ###### Domain Operations ######
+
+// CreateDomain creates a new custom domain and sets its ID.
+func CreateDomain(domain *Domain) error {
+	if err := mysqlDB.Create(domain).Error; err != nil {
+		log.Debug().Msg("Failed to save domain.")
+		return err
+	}
+	return nil
+}
+
+// GetDomain retrieves a custom domain by ID.
+func GetDomain(id uint) (Domain, error) {
+	var domain Domain
+	if err := mysqlDB.First(&domain, id).Error; err != nil {
+		log.Debug().Msg("Domain not found.")
+		return Domain{}, err
+	}
+	return domain, nil
+}
+
+// GetVerifiedDomain retrieves the verified custom domain of a lower case host name.
+func GetVerifiedDomain(host string) (Domain, error) {
+	var domain Domain
+	if err := mysqlDB.Where("verified_host = ?", host).First(&domain).Error; err != nil {
+		log.Debug().Msg("Domain not found.")
+		return Domain{}, err
+	}
+	return domain, nil
+}
+
+// ListDomainClaims retrieves every domain registered for a lower case host name,
+// verified or not.
+func ListDomainClaims(host string) ([]Domain, error) {
+	var domains []Domain
+	if err := mysqlDB.Where("host = ?", host).Order("id").Find(&domains).Error; err != nil {
+		log.Debug().Msg("Failed to list domain claims.")
+		return nil, err
+	}
+	return domains, nil
+}
+
+// ListDomains retrieves the custom domains owned by the user, or the domains of the
+// namespace when namespace is not empty.
+func ListDomains(ownerID, namespace string) ([]Domain, error) {
+	query := mysqlDB.Where("owner_id = ? AND namespace = ?", ownerID, "")
+	if namespace != "" {
+		query = mysqlDB.Where("namespace = ?", namespace)
+	}
+	var domains []Domain
+	if err := query.Order("host").Find(&domains).Error; err != nil {
+		log.Debug().Msg("Failed to list domains.")
+		return nil, err
+	}
+	return domains, nil
+}
+
+// UpdateDomainSettings saves the per-domain defaults of a custom domain.
+func UpdateDomainSettings(domain Domain) error {
+	if err := mysqlDB.Model(&Domain{}).Where("id = ?", domain.ID).Updates(map[string]interface{}{
+		"redirect_status": domain.RedirectStatus,
+		"not_found_url":   domain.NotFoundURL,
+		"root_url":        domain.RootURL,
+	}).Error; err != nil {
+		log.Debug().Msg("Failed to update domain.")
+		return err
+	}
+	return nil
+}
+
+// ErrDomainVerified is returned when another domain with the same host is verified.
+var ErrDomainVerified = errors.New("host is already verified by another domain")
+
+// MarkDomainVerified records that the DNS record of a custom domain was found. Only
+// one domain per host can be verified, the first one wins.
+func MarkDomainVerified(domain Domain, verifiedAt time.Time) error {
+	return mysqlDB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Domain{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("verified_host = ? AND id <> ?", domain.Host, domain.ID).Count(&count).Error; err != nil {
+			log.Debug().Msg("Failed to check verified domains.")
+			return err
+		}
+		if count > 0 {
+			return ErrDomainVerified
+		}
+		if err := tx.Model(&Domain{}).Where("id = ?", domain.ID).Updates(map[string]interface{}{
+			"verified_at":   verifiedAt,
+			"verified_host": domain.Host,
+		}).Error; err != nil {
+			log.Debug().Msg("Failed to mark domain verified.")
+			return err
+		}
+		return nil
+	})
+}
+
+// CountDomainLinks counts the short URLs on a custom domain.
+func CountDomainLinks(domainID uint) (int64, error) {
+	var count int64
+	if err := mysqlDB.Model(&UserShortURL{}).Where("domain_id = ?", domainID).Count(&count).Error; err != nil {
+		log.Debug().Msg("Failed to count domain links.")
+		return 0, err
+	}
+	return count, nil
+}
+
+// DeleteDomain deletes a custom domain.
+//
+// The row is removed for good, so the host can be verified again.
+func DeleteDomain(id uint) error {
+	if err := mysqlDB.Unscoped().Delete(&Domain{}, id).Error; err != nil {
+		log.Debug().Msg("Failed to delete domain.")
+		return err
+	}
+	return nil
+}
+
+// DomainCodesInUse reports which of the codes are already taken on a custom domain.
+// Soft deleted short URLs still hold their code, the unique index covers them.
+func DomainCodesInUse(domainID uint, codes []string) (map[string]bool, error) {
+	inUse := make(map[string]bool)
+	if len(codes) == 0 {
+		return inUse, nil
+	}
+
+	var taken []string
+	if err := mysqlDB.Unscoped().Model(&UserShortURL{}).Where("domain_id = ? AND domain_code IN ?", domainID, codes).
+		Pluck("domain_code", &taken).Error; err != nil {
+		log.Debug().Msg("Failed to check domain short codes.")
+		return nil, err
+	}
+	for _, code := range taken {
+		inUse[code] = true
+	}
+	return inUse, nil
+}
+
+// FindDomainShortURL retrieves the user short URL served at code on a custom domain
+// without checking expiration.
+func FindDomainShortURL(domainID uint, code string) (UserShortURL, error) {
+	var shortURL UserShortURL
+	if err := mysqlDB.Where("domain_id = ? AND domain_code = ?", domainID, code).First(&shortURL).Error; err != nil {
+		log.Debug().Msg("Domain short URL not found.")
+		return UserShortURL{}, err
+	}
+	return shortURL, nil
+}
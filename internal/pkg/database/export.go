@@ -0,0 +1,42 @@
+package database
+
+import (
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// ###### Export Operations ######
+
+// EachUserShortURL calls fn with the short URLs of a user, batchSize at a time and
+// ordered by ID, including expired ones. An empty userID walks the links of all users.
+//
+// Unlike ListUserShortURLs, only one batch is held in memory. If fn returns an error,
+// the walk stops and the error is returned.
+func EachUserShortURL(userID string, batchSize int, fn func([]UserShortURL) error) error {
+	query := mysqlDB.Model(&UserShortURL{})
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var batch []UserShortURL
+	if err := query.FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error; err != nil {
+		log.Debug().Msg("Failed to walk user short URLs.")
+		return err
+	}
+	return nil
+}
+
+// EachPublicShortURL calls fn with all public short URLs, batchSize at a time and
+// ordered by ID, including expired ones.
+func EachPublicShortURL(batchSize int, fn func([]PublicShortURL) error) error {
+	var batch []PublicShortURL
+	if err := mysqlDB.Model(&PublicShortURL{}).FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error; err != nil {
+		log.Debug().Msg("Failed to walk public short URLs.")
+		return err
+	}
+	return nil
+}
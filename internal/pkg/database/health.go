@@ -0,0 +1,62 @@
+package database
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ###### Link Health Operations ######
+
+// GetLinksDueForHealthCheck retrieves up to limit unexpired, enabled links which have
+// never been checked or were last checked before checkedBefore.
+// Links checked longest ago come first.
+func GetLinksDueForHealthCheck(checkedBefore time.Time, limit int) ([]Link, error) {
+	now := time.Now()
+	due := "(health_checked_at IS NULL OR health_checked_at < ?) AND disabled = ?"
+
+	var userShortURLs []UserShortURL
+	if err := mysqlDB.Where(due, checkedBefore, false).Where("expire_at > ?", now).
+		Order("health_checked_at").Limit(limit).Find(&userShortURLs).Error; err != nil {
+		log.Debug().Msg("Failed to get user short URLs due for health check.")
+		return nil, err
+	}
+	var publicShortURLs []PublicShortURL
+	if err := mysqlDB.Where(due, checkedBefore, false).Where("expires_at > ?", now).
+		Order("health_checked_at").Limit(limit).Find(&publicShortURLs).Error; err != nil {
+		log.Debug().Msg("Failed to get public short URLs due for health check.")
+		return nil, err
+	}
+
+	links := make([]Link, 0, len(userShortURLs)+len(publicShortURLs))
+	for _, u := range userShortURLs {
+		links = append(links, u)
+	}
+	for _, p := range publicShortURLs {
+		links = append(links, p)
+	}
+	if len(links) > limit {
+		links = links[:limit]
+	}
+	return links, nil
+}
+
+// SaveLinkHealth records the health check result of the link with the given short code.
+func SaveLinkHealth(shortCode string, health LinkHealth) error {
+	updates := map[string]interface{}{
+		"health_status":     health.HealthStatus,
+		"health_latency_ms": health.HealthLatencyMs,
+		"health_error":      health.HealthError,
+		"health_checked_at": health.HealthCheckedAt,
+		"broken":            health.Broken,
+	}
+	if err := mysqlDB.Model(&UserShortURL{}).Where("short_code = ?", shortCode).Updates(updates).Error; err != nil {
+		log.Debug().Msg("Failed to save user short URL health.")
+		return err
+	}
+	if err := mysqlDB.Model(&PublicShortURL{}).Where("short_code = ?", shortCode).Updates(updates).Error; err != nil {
+		log.Debug().Msg("Failed to save public short URL health.")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+// ###### Link Metadata Operations ######
+
+// SaveLinkMetadata records the destination metadata of the link with the given short code.
+func SaveLinkMetadata(shortCode string, meta LinkMetadata) error {
+	updates := map[string]interface{}{
+		"page_title":       meta.PageTitle,
+		"page_description": meta.PageDescription,
+		"page_image":       meta.PageImage,
+		"favicon_url":      meta.FaviconURL,
+		"metadata_error":   meta.MetadataError,
+		"metadata_at":      meta.MetadataAt,
+	}
+	if err := mysqlDB.Model(&UserShortURL{}).Where("short_code = ?", shortCode).Updates(updates).Error; err != nil {
+		log.Debug().Msg("Failed to save user short URL metadata.")
+		return err
+	}
+	if err := mysqlDB.Model(&PublicShortURL{}).Where("short_code = ?", shortCode).Updates(updates).Error; err != nil {
+		log.Debug().Msg("Failed to save public short URL metadata.")
+		return err
+	}
+	return nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -41,6 +42,42 @@ func (p PublicShortURL) GetShortCode() string {
 func (p PublicShortURL) GetExpireAt() time.Time {
 	return p.ExpiresAt
 }
+func (u UserShortURL) GetCreatedAt() time.Time {
+	return u.CreatedAt
+}
+func (p PublicShortURL) GetCreatedAt() time.Time {
+	return p.CreatedAt
+}
+func (u UserShortURL) GetOwnerID() string {
+	return u.UserID
+}
+func (p PublicShortURL) GetOwnerID() string {
+	return ""
+}
+func (u UserShortURL) GetAccessCount() uint {
+	return uint(u.AccessCount)
+}
+func (p PublicShortURL) GetAccessCount() uint {
+	return p.AccessCount
+}
+func (u UserShortURL) GetHealth() LinkHealth {
+	return u.LinkHealth
+}
+func (p PublicShortURL) GetHealth() LinkHealth {
+	return p.LinkHealth
+}
+func (u UserShortURL) GetMetadata() LinkMetadata {
+	return u.LinkMetadata
+}
+func (p PublicShortURL) GetMetadata() LinkMetadata {
+	return p.LinkMetadata
+}
+func (u UserShortURL) GetOptions() LinkOptions {
+	return u.LinkOptions
+}
+func (p PublicShortURL) GetOptions() LinkOptions {
+	return p.LinkOptions
+}
 
 const (
 	retries         = 25                     // 最大重试次数
@@ -56,6 +93,19 @@ type ShowCoder interface {
 	GetExpireAt() time.Time
 }
 
+// Link is implemented by both UserShortURL and PublicShortURL.
+// It exposes what the redirect path needs regardless of the link type.
+type Link interface {
+	ShowCoder
+	GetCreatedAt() time.Time
+	// GetOwnerID returns the user ID of the owner, public short URLs have no owner.
+	GetOwnerID() string
+	GetAccessCount() uint
+	GetOptions() LinkOptions
+	GetHealth() LinkHealth
+	GetMetadata() LinkMetadata
+}
+
 // ###### DB Oprations ######
 
 // InitMysqlDB initializes the MySQL database connection.
@@ -125,6 +175,56 @@ func InitMysqlDB() {
 		log.Info().Msg("Public tables already exist, skipping migration.")
 	}
 
+	// check whether the link variant table exists in the database
+	if !mysqlDB.Migrator().HasTable(&LinkVariant{}) {
+		log.Info().Msg("Link variant table does not exist, starting migration.")
+		if err := mysqlDB.AutoMigrate(&LinkVariant{}); err != nil {
+			log.Err(err).Msg("Failed to migrate LinkVariant.")
+		}
+	} else {
+		log.Info().Msg("Link variant table already exists, skipping migration.")
+	}
+
+	// check whether the bio page tables exist in the database
+	if !mysqlDB.Migrator().HasTable(&BioPage{}) || !mysqlDB.Migrator().HasTable(&BioPageLink{}) {
+		log.Info().Msg("Bio page tables do not exist, starting migration.")
+		if err := mysqlDB.AutoMigrate(&BioPage{}, &BioPageLink{}); err != nil {
+			log.Err(err).Msg("Failed to migrate BioPage.")
+		}
+	} else {
+		log.Info().Msg("Bio page tables already exist, skipping migration.")
+	}
+
+	// check whether the campaign tables exist in the database
+	if !mysqlDB.Migrator().HasTable(&Campaign{}) || !mysqlDB.Migrator().HasTable(&LinkClick{}) {
+		log.Info().Msg("Campaign tables do not exist, starting migration.")
+		if err := mysqlDB.AutoMigrate(&Campaign{}, &LinkClick{}); err != nil {
+			log.Err(err).Msg("Failed to migrate Campaign.")
+		}
+	} else {
+		log.Info().Msg("Campaign tables already exist, skipping migration.")
+	}
+
+	// check whether the domain table exists in the database
+	if !mysqlDB.Migrator().HasTable(&Domain{}) {
+		log.Info().Msg("Domain table does not exist, starting migration.")
+		if err := mysqlDB.AutoMigrate(&Domain{}); err != nil {
+			log.Err(err).Msg("Failed to migrate Domain.")
+		}
+	} else {
+		log.Info().Msg("Domain table already exists, skipping migration.")
+	}
+
+	// check whether the signed URL table exists in the database
+	if !mysqlDB.Migrator().HasTable(&SignedURL{}) {
+		log.Info().Msg("Signed URL table does not exist, starting migration.")
+		if err := mysqlDB.AutoMigrate(&SignedURL{}); err != nil {
+			log.Err(err).Msg("Failed to migrate SignedURL.")
+		}
+	} else {
+		log.Info().Msg("Signed URL table already exists, skipping migration.")
+	}
+
 	if config.TestMode {
 		log.Debug().Msg("Test mode enabled, check tables difference and force migration.")
 		if err := mysqlDB.AutoMigrate(&User{}, &UserShortURL{}, &ClientIP{}); err != nil {
@@ -133,13 +233,79 @@ func InitMysqlDB() {
 		if err := mysqlDB.AutoMigrate(&PublicShortURL{}); err != nil {
 			log.Err(err).Msg("Failed to migrate PublicShortURL.")
 		}
+		if err := mysqlDB.AutoMigrate(&LinkVariant{}); err != nil {
+			log.Err(err).Msg("Failed to migrate LinkVariant.")
+		}
+		if err := mysqlDB.AutoMigrate(&Campaign{}, &LinkClick{}); err != nil {
+			log.Err(err).Msg("Failed to migrate Campaign.")
+		}
+		if err := mysqlDB.AutoMigrate(&BioPage{}, &BioPageLink{}); err != nil {
+			log.Err(err).Msg("Failed to migrate BioPage.")
+		}
+		if err := mysqlDB.AutoMigrate(&Domain{}); err != nil {
+			log.Err(err).Msg("Failed to migrate Domain.")
+		}
+		if err := mysqlDB.AutoMigrate(&SignedURL{}); err != nil {
+			log.Err(err).Msg("Failed to migrate SignedURL.")
+		}
 	}
 
+	// link options are added to the existing tables over time
+	migrateMissingColumns(&UserShortURL{}, &PublicShortURL{})
+	migrateDomainClaims()
+
 	log.Info().Msg("MySQL migration completed.")
 
 	log.Info().Msg("** Init mysql finished! **")
 }
 
+// migrateMissingColumns adds the columns and indexes of newly introduced fields to existing tables.
+//
+// Existing tables skip AutoMigrate, so without this new link options would never be created.
+func migrateMissingColumns(models ...any) {
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: mysqlDB}
+		if err := stmt.Parse(model); err != nil {
+			log.Err(err).Msg("Failed to parse model schema.")
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || mysqlDB.Migrator().HasColumn(model, field.DBName) {
+				continue
+			}
+			log.Info().Str("table", stmt.Schema.Table).Str("column", field.DBName).Msg("Adding missing column.")
+			if err := mysqlDB.Migrator().AddColumn(model, field.Name); err != nil {
+				log.Err(err).Str("column", field.DBName).Msg("Failed to add column.")
+			}
+		}
+		for name := range stmt.Schema.ParseIndexes() {
+			if mysqlDB.Migrator().HasIndex(model, name) {
+				continue
+			}
+			log.Info().Str("table", stmt.Schema.Table).Str("index", name).Msg("Adding missing index.")
+			if err := mysqlDB.Migrator().CreateIndex(model, name); err != nil {
+				log.Err(err).Str("index", name).Msg("Failed to add index.")
+			}
+		}
+	}
+}
+
+// migrateDomainClaims lets several users claim an unverified host. The host used to
+// be unique among all domains, now only the verified_host of verified domains is.
+func migrateDomainClaims() {
+	if mysqlDB.Migrator().HasIndex(&Domain{}, "idx_domains_host") {
+		log.Info().Msg("Dropping unique index of domain hosts.")
+		if err := mysqlDB.Migrator().DropIndex(&Domain{}, "idx_domains_host"); err != nil {
+			log.Err(err).Msg("Failed to drop index of domain hosts.")
+		}
+	}
+	migrateMissingColumns(&Domain{})
+	if err := mysqlDB.Model(&Domain{}).Where("verified_at IS NOT NULL AND verified_host IS NULL").
+		Update("verified_host", gorm.Expr("host")).Error; err != nil {
+		log.Err(err).Msg("Failed to back-fill verified domain hosts.")
+	}
+}
+
 // CloseMysqlDB closes the MySQL database connection.
 func CloseMysqlDB() error {
 	sqlDB, err := mysqlDB.DB()
@@ -179,6 +345,15 @@ func GetUserByEmail(email string) (User, error) {
 	return user, nil
 }
 
+// GetUserByID retrieves a user by user ID from the database.
+func GetUserByID(userID string) (User, error) {
+	var user User
+	if err := mysqlDB.Where("user_id = ?", userID).First(&user).Error; err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
 // GetOriginalURLByShortCode retrieves the User original URL by short code.
 func GetOriginalURLByShortCode(shortCode string) (string, error) {
 	var shortURL UserShortURL
@@ -212,6 +387,16 @@ func GetUserShortURLByCode(shortCode string) (UserShortURL, error) {
 	return shortURL, nil
 }
 
+// FindUserShortURL retrieves the User short URL by short code without checking expiration.
+func FindUserShortURL(shortCode string) (UserShortURL, error) {
+	var shortURL UserShortURL
+	if err := mysqlDB.Where("short_code = ?", shortCode).First(&shortURL).Error; err != nil {
+		log.Debug().Msg("User short URL not found.")
+		return UserShortURL{}, err
+	}
+	return shortURL, nil
+}
+
 // CreateUserShortURL creates a new short URL for the user.
 func CreateUserShortURL(short UserShortURL, clientIP string) error {
 	if err := mysqlDB.Create(&short).Error; err != nil {
@@ -225,6 +410,67 @@ func CreateUserShortURL(short UserShortURL, clientIP string) error {
 	return nil
 }
 
+// CreateUserShortURLs creates several short URLs for the user in one transaction.
+// If one of them can not be saved, none of them is.
+func CreateUserShortURLs(shorts []UserShortURL, clientIP string) error {
+	return mysqlDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&shorts).Error; err != nil {
+			log.Debug().Msg("Failed to save short URLs.")
+			return err
+		}
+		clientIPs := make([]ClientIP, 0, len(shorts))
+		for _, short := range shorts {
+			clientIPs = append(clientIPs, ClientIP{IPAddress: clientIP, ShortURLID: short.ID})
+		}
+		if err := tx.Create(&clientIPs).Error; err != nil {
+			log.Debug().Msg("Failed to save client IPs.")
+			return err
+		}
+		return nil
+	})
+}
+
+// CountUserShortURLs counts the short URLs owned by the user.
+func CountUserShortURLs(userID string) (int64, error) {
+	var count int64
+	if err := mysqlDB.Model(&UserShortURL{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		log.Debug().Msg("Failed to count short URLs for userID.")
+		return 0, err
+	}
+	return count, nil
+}
+
+// ShortCodesInUse reports which of the codes are already taken by a user or public short URL
+// or a bio page.
+// Soft deleted short URLs still hold their code, the unique index covers them.
+func ShortCodesInUse(codes []string) (map[string]bool, error) {
+	inUse := make(map[string]bool)
+	if len(codes) == 0 {
+		return inUse, nil
+	}
+
+	var taken []string
+	if err := mysqlDB.Unscoped().Model(&UserShortURL{}).Where("short_code IN ?", codes).Pluck("short_code", &taken).Error; err != nil {
+		log.Debug().Msg("Failed to check user short codes.")
+		return nil, err
+	}
+	var publicTaken []string
+	if err := mysqlDB.Unscoped().Model(&PublicShortURL{}).Where("short_code IN ?", codes).Pluck("short_code", &publicTaken).Error; err != nil {
+		log.Debug().Msg("Failed to check public short codes.")
+		return nil, err
+	}
+	var pageTaken []string
+	if err := mysqlDB.Unscoped().Model(&BioPage{}).Where("short_code IN ?", codes).Pluck("short_code", &pageTaken).Error; err != nil {
+		log.Debug().Msg("Failed to check bio page codes.")
+		return nil, err
+	}
+	taken = append(taken, publicTaken...)
+	for _, code := range append(taken, pageTaken...) {
+		inUse[code] = true
+	}
+	return inUse, nil
+}
+
 func ShowCodes(s ShowCoder) (string, string) {
 	if s.GetExpireAt().Before(time.Now()) {
 		log.Debug().Msg("Short URL has expired.")
@@ -233,9 +479,18 @@ func ShowCodes(s ShowCoder) (string, string) {
 	return s.GetOriginalURL(), s.GetShortCode()
 }
 
+// slideExpiration returns the expression that pushes the expiration column of a link
+// with a sliding TTL to at least now+ttl, capped by its max_expire_at. Links without
+// a sliding TTL keep their expiration.
+func slideExpiration(column string, now time.Time) clause.Expr {
+	next := "DATE_ADD(?, INTERVAL sliding_ttl SECOND)"
+	return gorm.Expr("CASE WHEN sliding_ttl > 0 THEN GREATEST("+column+", LEAST("+next+", COALESCE(max_expire_at, "+next+"))) ELSE "+column+" END", now, now)
+}
+
 // LogUserAccess increments user access count and updates the client IP table.
 //
 // It will search for the short code in the database before updating the access count.
+// The sliding expiration of the link is extended by the same update.
 func LogUserAccess(shortCode string, clientIP string) error {
 	var (
 		userShortURL UserShortURL
@@ -251,6 +506,7 @@ func LogUserAccess(shortCode string, clientIP string) error {
 	// 更新访问计数和 IP 列表
 	if err = mysqlDB.Model(&UserShortURL{}).Where("short_code = ?", shortCode).Updates(map[string]interface{}{
 		"access_count": gorm.Expr("access_count + 1"),
+		"expire_at":    slideExpiration("expire_at", time.Now()),
 	}).Error; err != nil {
 		log.Debug().Msg("Failed to update access count.")
 		return err
@@ -261,6 +517,10 @@ func LogUserAccess(shortCode string, clientIP string) error {
 		return err
 	}
 
+	if err = logClick(userShortURL, clientIP); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -275,14 +535,23 @@ func SaveClientIP(shortURLID uint, clientIP string) error {
 	return nil
 }
 
-// GetUserShortURLsByUserID retrieves all original URLs and short codes
-// for a user by user ID. It returns a map of short codes to original URLs.
-func GetUserShortURLsByUserID(userID string) (map[string]string, error) {
+// ListUserShortURLs retrieves all short URLs of a user by user ID, including expired ones.
+func ListUserShortURLs(userID string) ([]UserShortURL, error) {
 	var shortURLs []UserShortURL
 	if err := mysqlDB.Where("user_id = ?", userID).Find(&shortURLs).Error; err != nil {
 		log.Debug().Msg("Failed to get short URLs for userID.")
 		return nil, err
 	}
+	return shortURLs, nil
+}
+
+// GetUserShortURLsByUserID retrieves all original URLs and short codes
+// for a user by user ID. It returns a map of short codes to original URLs.
+func GetUserShortURLsByUserID(userID string) (map[string]string, error) {
+	shortURLs, err := ListUserShortURLs(userID)
+	if err != nil {
+		return nil, err
+	}
 	codes := make(map[string]string)
 	for _, shortURL := range shortURLs {
 		originalURL, shortCode := ShowCodes(shortURL)
@@ -297,7 +566,8 @@ func GetUserShortURLsByUserID(userID string) (map[string]string, error) {
 
 // LogPublicAccess logs public access count.
 //
-// If the short code exists, increment the access count by 1.
+// If the short code exists, increment the access count by 1 and extend the sliding
+// expiration of the link.
 func LogPublicAccess(shortcode string) error {
 	var (
 		publicShortURL PublicShortURL
@@ -313,6 +583,7 @@ func LogPublicAccess(shortcode string) error {
 	// 更新访问计数
 	if err = mysqlDB.Model(&PublicShortURL{}).Where("short_code = ?", shortcode).Updates(map[string]interface{}{
 		"access_count": gorm.Expr("access_count + 1"),
+		"expires_at":   slideExpiration("expires_at", time.Now()),
 	}).Error; err != nil {
 		log.Debug().Msg("Failed to update access count.")
 		return err
@@ -350,16 +621,88 @@ func GetPublicShortURLByShortCode(shortCode string) (string, error) {
 	return publicShortURL.OriginalURL, nil
 }
 
-// Get all public short URLs.
-func GetAllPublicShortURLs() (map[string]string, error) {
+// FindPublicShortURL retrieves the public short URL by short code without checking expiration.
+func FindPublicShortURL(shortCode string) (PublicShortURL, error) {
+	var publicShortURL PublicShortURL
+	if err := mysqlDB.Where("short_code = ?", shortCode).First(&publicShortURL).Error; err != nil {
+		log.Debug().Msg("Public short URL not found.")
+		return PublicShortURL{}, err
+	}
+	return publicShortURL, nil
+}
+
+// GetPublicShortURLByCode retrieves the public short URL by short code.
+//
+// If the short code expires, return an error "public short URL has expired".
+func GetPublicShortURLByCode(shortCode string) (PublicShortURL, error) {
+	publicShortURL, err := FindPublicShortURL(shortCode)
+	if err != nil {
+		return PublicShortURL{}, err
+	}
+
+	if publicShortURL.ExpiresAt.Before(time.Now()) {
+		log.Debug().Msg("Public short URL has expired.")
+		return PublicShortURL{}, errors.New("public short URL has expired")
+	}
+
+	return publicShortURL, nil
+}
+
+// DisableLink disables the user or public short URL with the given short code.
+func DisableLink(shortCode, reason string) error {
+	updates := map[string]interface{}{"disabled": true, "disabled_reason": reason}
+	if err := mysqlDB.Model(&UserShortURL{}).Where("short_code = ?", shortCode).Updates(updates).Error; err != nil {
+		log.Debug().Msg("Failed to disable user short URL.")
+		return err
+	}
+	if err := mysqlDB.Model(&PublicShortURL{}).Where("short_code = ?", shortCode).Updates(updates).Error; err != nil {
+		log.Debug().Msg("Failed to disable public short URL.")
+		return err
+	}
+	return nil
+}
+
+// ListListedShortURLs retrieves all listed short URLs, including expired ones.
+//
+// Public short URLs are listed unless their visibility says otherwise,
+// user short URLs are only listed when their visibility is "public".
+func ListListedShortURLs() ([]Link, error) {
 	var publicShortURLs []PublicShortURL
-	if err := mysqlDB.Find(&publicShortURLs).Error; err != nil {
+	if err := mysqlDB.Where("visibility IN ?", []string{"", "public"}).Find(&publicShortURLs).Error; err != nil {
 		log.Debug().Msg("Failed to get all public short URLs.")
 		return nil, err
 	}
+	var userShortURLs []UserShortURL
+	if err := mysqlDB.Where("visibility = ?", "public").Find(&userShortURLs).Error; err != nil {
+		log.Debug().Msg("Failed to get listed user short URLs.")
+		return nil, err
+	}
+
+	links := make([]Link, 0, len(publicShortURLs)+len(userShortURLs))
+	for _, p := range publicShortURLs {
+		links = append(links, p)
+	}
+	for _, u := range userShortURLs {
+		links = append(links, u)
+	}
+	return links, nil
+}
+
+// Get all listed short URLs that are active. It returns a map of short codes to original URLs.
+func GetAllPublicShortURLs() (map[string]string, error) {
+	links, err := ListListedShortURLs()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	codes := make(map[string]string)
-	for _, publicShortURL := range publicShortURLs {
-		originalURL, shortCode := ShowCodes(publicShortURL)
+	for _, link := range links {
+		// 尚未生效的链接不公开
+		if activeFrom := link.GetOptions().ActiveFrom; activeFrom != nil && activeFrom.After(now) {
+			continue
+		}
+		originalURL, shortCode := ShowCodes(link)
 		if originalURL != "" && shortCode != "" {
 			codes[shortCode] = originalURL
 		}
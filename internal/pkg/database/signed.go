@@ -0,0 +1,78 @@
+package database
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// ###### Signed URL Operations ######
+
+// CreateSignedURL saves a newly minted signed URL.
+func CreateSignedURL(signed *SignedURL) error {
+	if err := mysqlDB.Create(signed).Error; err != nil {
+		log.Debug().Msg("Failed to save signed URL.")
+		return err
+	}
+	return nil
+}
+
+// ListSignedURLs retrieves the signed URLs of a short code, newest first.
+func ListSignedURLs(shortCode string) ([]SignedURL, error) {
+	var signed []SignedURL
+	if err := mysqlDB.Where("short_code = ?", shortCode).Order("id DESC").Find(&signed).Error; err != nil {
+		log.Debug().Msg("Failed to list signed URLs.")
+		return nil, err
+	}
+	return signed, nil
+}
+
+// GetSignedURL retrieves the signed URL of a short code with the given nonce.
+func GetSignedURL(shortCode, nonce string) (SignedURL, error) {
+	var signed SignedURL
+	if err := mysqlDB.Where("short_code = ? AND nonce = ?", shortCode, nonce).First(&signed).Error; err != nil {
+		log.Debug().Msg("Signed URL not found.")
+		return SignedURL{}, err
+	}
+	return signed, nil
+}
+
+// RevokeSignedURL revokes the signed URL of a short code with the given nonce.
+//
+// It returns gorm.ErrRecordNotFound when there is no such signed URL.
+func RevokeSignedURL(shortCode, nonce string, revokedAt time.Time) error {
+	result := mysqlDB.Model(&SignedURL{}).Where("short_code = ? AND nonce = ? AND revoked_at IS NULL", shortCode, nonce).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		log.Debug().Msg("Failed to revoke signed URL.")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := mysqlDB.Model(&SignedURL{}).Where("short_code = ? AND nonce = ?", shortCode, nonce).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	return nil
+}
+
+// UseSignedURL counts one use of the signed URL of a short code with the given nonce.
+//
+// It reports false when the signed URL does not exist, was revoked, has expired or
+// is used up. The check and the count are one statement, so concurrent visitors can
+// never exceed the max uses.
+func UseSignedURL(shortCode, nonce string, now time.Time) (bool, error) {
+	result := mysqlDB.Model(&SignedURL{}).
+		Where("short_code = ? AND nonce = ? AND revoked_at IS NULL AND expires_at > ?", shortCode, nonce, now).
+		Where("max_uses = 0 OR uses < max_uses").
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		log.Debug().Msg("Failed to use signed URL.")
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
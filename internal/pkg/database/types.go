@@ -20,12 +20,20 @@ type User struct {
 // User Short URL table
 type UserShortURL struct {
 	gorm.Model
-	OriginalURL string     `gorm:"type:text;not null"`
-	ShortCode   string     `gorm:"type:varchar(10);uniqueIndex;not null"` // 短码6-10位
-	ExpireAt    time.Time  `gorm:"index"`                                 // 过期时间索引
-	AccessCount int        `gorm:"default:0"`
-	ClientIPs   []ClientIP `gorm:"foreignKey:ShortURLID"`           // 一对多关系（一个短链接对应多个IP）
-	UserID      string     `gorm:"type:varchar(36);index;not null"` // 外键关联
+	OriginalURL  string     `gorm:"type:text;not null"`
+	ShortCode    string     `gorm:"type:varchar(10);uniqueIndex;not null"` // 短码6-10位
+	ExpireAt     time.Time  `gorm:"index"`                                 // 过期时间索引
+	AccessCount  int        `gorm:"default:0"`
+	ClientIPs    []ClientIP `gorm:"foreignKey:ShortURLID"`           // 一对多关系（一个短链接对应多个IP）
+	UserID       string     `gorm:"type:varchar(36);index;not null"` // 外键关联
+	CampaignID   uint       `gorm:"default:0;index"`                 // 所属营销活动，0 表示不属于任何活动
+	LinkOptions  `gorm:"embedded"`
+	LinkHealth   `gorm:"embedded"`
+	LinkMetadata `gorm:"embedded"`
+
+	// 自定义域名上的短链以 DomainCode 访问，同一短码可以出现在不同域名下
+	DomainID   uint    `gorm:"default:0;uniqueIndex:idx_user_short_urls_domain_code,priority:1"` // 所属自定义域名，0 表示默认域名
+	DomainCode *string `gorm:"type:varchar(10);uniqueIndex:idx_user_short_urls_domain_code,priority:2"`
 }
 
 // Client IP table
@@ -38,8 +46,174 @@ type ClientIP struct {
 // Public Short URL table
 type PublicShortURL struct {
 	gorm.Model
-	ShortCode   string    `gorm:"size:10;uniqueIndex;not null"` // 短链码
-	OriginalURL string    `gorm:"type:text;not null"`           // 原始URL
-	ExpiresAt   time.Time // 过期时间
-	AccessCount uint      `gorm:"default:0"` // 访问计数
+	ShortCode    string    `gorm:"size:10;uniqueIndex;not null"` // 短链码
+	OriginalURL  string    `gorm:"type:text;not null"`           // 原始URL
+	ExpiresAt    time.Time // 过期时间
+	AccessCount  uint      `gorm:"default:0"` // 访问计数
+	LinkOptions  `gorm:"embedded"`
+	LinkHealth   `gorm:"embedded"`
+	LinkMetadata `gorm:"embedded"`
+}
+
+// Link options shared by user and public short URLs
+//
+// They are embedded in both tables and only change how a link is redirected.
+type LinkOptions struct {
+	IOSURL      string `gorm:"column:ios_url;type:text"`      // iOS 应用链接（如 myapp://path）
+	AndroidURL  string `gorm:"column:android_url;type:text"`  // Android 应用链接
+	FallbackURL string `gorm:"column:fallback_url;type:text"` // 未安装应用时的回退链接
+
+	RedirectStatus int `gorm:"default:0"` // 重定向状态码 301/302/307/308，0 表示使用全局默认值
+
+	Title        string `gorm:"type:varchar(255)"` // 所有者设置的标题，预览时展示
+	Interstitial bool   `gorm:"default:false"`     // 总是先展示中间页，用于不可信的目标地址
+
+	Visibility string `gorm:"type:varchar(16);index"` // public, unlisted, private, namespace；为空时用户短链为 private，公共短链为 public
+	Namespace  string `gorm:"type:varchar(64)"`       // visibility 为 namespace 时，可访问该链接的 RBAC 命名空间
+
+	Disabled       bool   `gorm:"default:false"`     // 已停用，如目标域名被加入黑名单
+	DisabledReason string `gorm:"type:varchar(255)"` // 停用原因
+
+	Tags string `gorm:"type:varchar(255)"` // 标签，逗号分隔
+
+	ExpiredURL string `gorm:"column:expired_url;type:text"` // 过期后跳转的地址，为空时返回 404
+
+	SlidingTTL  int64      `gorm:"column:sliding_ttl;default:0"` // 滑动过期时长（秒），每次跳转把过期时间推迟到至少 now+ttl，0 表示不启用
+	MaxExpireAt *time.Time `gorm:"column:max_expire_at"`         // 滑动过期的最晚过期时间，为空表示不限制
+
+	ActiveFrom   *time.Time `gorm:"column:active_from"`             // 生效时间，之前访问返回"即将上线"，为空表示创建后立即生效
+	PrelaunchURL string     `gorm:"column:prelaunch_url;type:text"` // 生效前跳转的地址
+
+	RateLimit    float64 `gorm:"column:rate_limit;default:0"`     // 每秒最多跳转次数，所有副本共享，0 表示不限制
+	RateBurst    int     `gorm:"column:rate_burst;default:0"`     // 允许的突发跳转次数
+	RateLimitURL string  `gorm:"column:rate_limit_url;type:text"` // 超过限制时跳转的备用地址，为空时返回 429
+
+	OGTitle       string `gorm:"column:og_title;type:varchar(255)"`       // 社交卡片标题，为空时使用 Title
+	OGDescription string `gorm:"column:og_description;type:varchar(512)"` // 社交卡片描述
+	OGImage       string `gorm:"column:og_image;type:text"`               // 社交卡片图片地址
+
+	// 跳转时合并到目标地址的 UTM 参数
+	UTMSource   string `gorm:"column:utm_source;type:varchar(128)"`
+	UTMMedium   string `gorm:"column:utm_medium;type:varchar(128)"`
+	UTMCampaign string `gorm:"column:utm_campaign;type:varchar(128)"`
+	UTMTerm     string `gorm:"column:utm_term;type:varchar(128)"`
+	UTMContent  string `gorm:"column:utm_content;type:varchar(128)"`
+	Passthrough bool   `gorm:"default:false"` // 把短链请求的查询参数转发到目标地址
+}
+
+// Link Variant table
+//
+// A short code with variants splits its traffic between several destinations by weight.
+type LinkVariant struct {
+	gorm.Model
+	ShortCode   string `gorm:"size:10;index;not null"` // 所属短链码
+	OriginalURL string `gorm:"type:text;not null"`     // 目标URL
+	Weight      uint   `gorm:"not null"`               // 权重
+	AccessCount uint   `gorm:"default:0"`              // 访问计数
+}
+
+// Destination health shared by user and public short URLs
+//
+// It is written by the health checker, never by users.
+type LinkHealth struct {
+	HealthStatus    int        `gorm:"default:0"`         // 最近一次探测的状态码，0 表示请求失败或未探测
+	HealthLatencyMs int64      `gorm:"default:0"`         // 最近一次探测的耗时（毫秒）
+	HealthError     string     `gorm:"type:varchar(255)"` // 最近一次探测的错误
+	HealthCheckedAt *time.Time `gorm:"index"`             // 最近一次探测时间
+	Broken          bool       `gorm:"default:false"`     // 目标地址失效
+}
+
+// Destination metadata shared by user and public short URLs
+//
+// It is fetched from the destination page in the background, never set by users.
+type LinkMetadata struct {
+	PageTitle       string     `gorm:"type:varchar(255)"` // 目标页面标题
+	PageDescription string     `gorm:"type:varchar(512)"` // 目标页面描述
+	PageImage       string     `gorm:"type:text"`         // 目标页面预览图
+	FaviconURL      string     `gorm:"type:text"`         // 目标页面图标
+	MetadataError   string     `gorm:"type:varchar(255)"` // 最近一次抓取的错误
+	MetadataAt      *time.Time // 最近一次抓取时间
+}
+
+// Campaign table
+//
+// A campaign groups user short URLs for aggregated analytics. It is owned either
+// by a user or, when Namespace is set, by an RBAC namespace.
+type Campaign struct {
+	gorm.Model
+	Name        string `gorm:"type:varchar(64);not null"`
+	Description string `gorm:"type:varchar(255)"`
+	OwnerID     string `gorm:"type:varchar(36);index;not null"` // 创建者
+	Namespace   string `gorm:"type:varchar(64);index"`          // 为空时只有创建者可以管理
+}
+
+// Link Click table
+//
+// One row per redirect of a user short URL, used for time series analytics.
+type LinkClick struct {
+	ID         uint      `gorm:"primarykey"`
+	ShortCode  string    `gorm:"type:varchar(10);index;not null"`
+	CampaignID uint      `gorm:"index:idx_link_clicks_campaign,priority:1"` // 点击时所属的营销活动
+	VisitorIP  string    `gorm:"type:varchar(45)"`
+	CreatedAt  time.Time `gorm:"index:idx_link_clicks_campaign,priority:2"`
+}
+
+// Bio Page table
+//
+// A link-in-bio page is a hosted HTML page served at its own short code, listing
+// short URLs of its owner. Codes are shared with the short URL tables.
+type BioPage struct {
+	gorm.Model
+	ShortCode   string        `gorm:"type:varchar(10);uniqueIndex;not null"`
+	UserID      string        `gorm:"type:varchar(36);index;not null"` // 所有者
+	Title       string        `gorm:"type:varchar(128);not null"`
+	Description string        `gorm:"type:varchar(512)"`
+	AvatarURL   string        `gorm:"type:text"`
+	Theme       string        `gorm:"type:varchar(16)"` // 页面主题，为空时使用默认主题
+	ViewCount   uint          `gorm:"default:0"`        // 页面访问次数
+	Links       []BioPageLink `gorm:"foreignKey:PageID;constraint:OnDelete:CASCADE"`
+}
+
+// Bio Page Link table
+//
+// One entry of a bio page, pointing to a short URL so its clicks are logged as usual.
+type BioPageLink struct {
+	ID        uint   `gorm:"primarykey"`
+	PageID    uint   `gorm:"index;not null"`
+	Position  int    `gorm:"not null"` // 在页面中的顺序，从 0 开始
+	Label     string `gorm:"type:varchar(128)"`
+	ShortCode string `gorm:"type:varchar(10);not null"`
+}
+
+// Domain table
+//
+// A branded short domain owned by a user or, when Namespace is set, by an RBAC
+// namespace. Links can only be put on a domain once its DNS TXT record is verified.
+type Domain struct {
+	gorm.Model
+	Host         string     `gorm:"type:varchar(253);index:idx_domains_host_name;not null"` // 小写主机名，不含端口；未验证的主机名可被多人申请
+	OwnerID      string     `gorm:"type:varchar(36);index;not null"`                        // 创建者
+	Namespace    string     `gorm:"type:varchar(64);index"`                                 // 为空时只有创建者可以管理
+	VerifyToken  string     `gorm:"type:varchar(64);not null"`                              // DNS TXT 验证记录的值
+	VerifiedAt   *time.Time // 验证通过的时间，为空表示未验证
+	VerifiedHost *string    `gorm:"type:varchar(253);uniqueIndex"` // 验证通过后等于 host，保证同一主机名只有一个已验证的域名
+
+	RedirectStatus int    `gorm:"default:0"` // 该域名下短链的默认重定向状态码，0 表示使用全局默认值
+	NotFoundURL    string `gorm:"type:text"` // 短码不存在时跳转的页面，为空时返回 404
+	RootURL        string `gorm:"type:text"` // 访问域名根路径时跳转的地址，为空时返回 404
+}
+
+// Signed URL table
+//
+// A signed URL grants access to a short URL without an account until it expires,
+// is used up or is revoked. The signature itself is not stored, only its nonce.
+type SignedURL struct {
+	gorm.Model
+	Nonce     string     `gorm:"type:varchar(32);uniqueIndex;not null"`
+	ShortCode string     `gorm:"type:varchar(10);index;not null"` // 所属短链码
+	OwnerID   string     `gorm:"type:varchar(36);index;not null"` // 签发者
+	ExpiresAt time.Time  `gorm:"not null"`                        // 签名过期时间
+	MaxUses   uint       `gorm:"default:0"`                       // 最多使用次数，0 表示不限制
+	Uses      uint       `gorm:"default:0"`                       // 已使用次数
+	RevokedAt *time.Time // 撤销时间，为空表示未撤销
 }
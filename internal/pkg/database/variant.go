@@ -0,0 +1,46 @@
+package database
+
+import (
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// ###### Link Variant Operations ######
+
+// CreateLinkVariants saves the weighted destinations of a short code in one transaction.
+//
+// You can not use this function as a public API to create variants.
+// You should use the UserShortCodeCreater or PublicShortCodeCreater function instead.
+func CreateLinkVariants(variants []LinkVariant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if err := mysqlDB.Create(&variants).Error; err != nil {
+		log.Debug().Msg("Failed to save link variants.")
+		return err
+	}
+	return nil
+}
+
+// GetLinkVariants retrieves all weighted destinations of a short code, ordered by creation.
+//
+// A short code without variants returns an empty slice and no error.
+func GetLinkVariants(shortCode string) ([]LinkVariant, error) {
+	var variants []LinkVariant
+	if err := mysqlDB.Where("short_code = ?", shortCode).Order("id").Find(&variants).Error; err != nil {
+		log.Debug().Msg("Failed to get link variants.")
+		return nil, err
+	}
+	return variants, nil
+}
+
+// LogVariantAccess increments the access count of a link variant.
+func LogVariantAccess(variantID uint) error {
+	if err := mysqlDB.Model(&LinkVariant{}).Where("id = ?", variantID).Updates(map[string]interface{}{
+		"access_count": gorm.Expr("access_count + 1"),
+	}).Error; err != nil {
+		log.Debug().Msg("Failed to update variant access count.")
+		return err
+	}
+	return nil
+}
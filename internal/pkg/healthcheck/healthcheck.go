@@ -0,0 +1,302 @@
+// Package healthcheck periodically probes the destinations of short links.
+//
+// The Checker sends a HEAD request (falling back to GET when HEAD is not supported)
+// with a timeout, limits the number of concurrent probes and waits between two
+// probes of the same host, so destinations are not hammered.
+//
+// The destinations come from users, so like the metadata fetcher the default client
+// only connects to public IP addresses, bypasses proxies and follows a limited
+// number of redirects.
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"url-shortener/internal/pkg/metadata"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultConcurrency = 4
+	defaultTimeout     = 10 * time.Second
+	defaultHostDelay   = time.Second
+	defaultInterval    = time.Hour
+	defaultBatchSize   = 100
+	defaultMaxRedirect = 5
+	userAgent          = "url-shortener-healthcheck/1.0"
+)
+
+var (
+	// ErrBlockedAddress is returned when the destination resolves to a non public address.
+	ErrBlockedAddress = errors.New("healthcheck: destination address is not public")
+	// ErrTooManyRedirects is returned when the redirect limit is exceeded.
+	ErrTooManyRedirects = errors.New("healthcheck: too many redirects")
+)
+
+// Target is a link destination to probe.
+type Target struct {
+	ShortCode string
+	URL       string
+	OwnerID   string
+	// WasBroken is the result of the previous probe, owners are only notified
+	// when a link becomes broken.
+	WasBroken bool
+}
+
+// Result is the outcome of one probe.
+type Result struct {
+	ShortCode  string
+	StatusCode int
+	Latency    time.Duration
+	CheckedAt  time.Time
+	Err        error
+}
+
+// Broken reports whether the destination failed or answered with an error status.
+func (r Result) Broken() bool {
+	return r.Err != nil || r.StatusCode >= http.StatusBadRequest
+}
+
+// Store provides the targets and records the results.
+type Store interface {
+	// DueTargets returns up to limit links that were last checked before the given time.
+	DueTargets(checkedBefore time.Time, limit int) ([]Target, error)
+	SaveResult(result Result) error
+}
+
+// Notifier tells the owner that a link became broken.
+type Notifier interface {
+	NotifyBroken(ctx context.Context, target Target, result Result) error
+}
+
+// Checker probes link destinations.
+type Checker struct {
+	// Client sends the probes. The default client only connects to public addresses.
+	Client      *http.Client
+	Concurrency int           // 同时进行的探测数
+	Timeout     time.Duration // 单次探测超时
+	HostDelay   time.Duration // 同一域名两次探测之间的最小间隔
+	Interval    time.Duration // 一个链接两次探测之间的间隔
+	BatchSize   int           // 每轮最多探测的链接数
+	Store       Store
+	Notifier    Notifier // 可选
+	MaxRedirect int      // 默认客户端最多跟随的重定向次数
+	// AllowPrivate lets the default client connect to private and loopback addresses, for tests only.
+	AllowPrivate bool
+
+	mu       sync.Mutex
+	hostNext map[string]time.Time
+}
+
+// withDefaults fills the zero fields of the checker.
+func (c *Checker) withDefaults() {
+	if c.Concurrency <= 0 {
+		c.Concurrency = defaultConcurrency
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.MaxRedirect <= 0 {
+		c.MaxRedirect = defaultMaxRedirect
+	}
+	if c.Client == nil {
+		c.Client = c.newClient()
+	}
+	if c.HostDelay <= 0 {
+		c.HostDelay = defaultHostDelay
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.hostNext == nil {
+		c.hostNext = make(map[string]time.Time)
+	}
+}
+
+// newClient builds the default client, guarded against SSRF.
+func (c *Checker) newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: c.Timeout}
+	if !c.AllowPrivate {
+		dialer.Control = checkAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // 经代理连接时无法检查目标地址
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > c.MaxRedirect {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("healthcheck: redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// checkAddress rejects connections to non public addresses, after DNS resolution.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !metadata.IsPublic(addrPort.Addr()) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// Run checks the due links every minute until ctx is done.
+func (c *Checker) Run(ctx context.Context) {
+	c.withDefaults()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		if err := c.RunOnce(ctx); err != nil {
+			log.Warn().Err(err).Msg("Health check round failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce checks one batch of due links and stores the results.
+func (c *Checker) RunOnce(ctx context.Context) error {
+	c.withDefaults()
+	if c.Store == nil {
+		return errors.New("health check store is not set")
+	}
+
+	targets, err := c.Store.DueTargets(time.Now().Add(-c.Interval), c.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for i, result := range c.CheckAll(ctx, targets) {
+		if err := c.Store.SaveResult(result); err != nil {
+			log.Warn().Err(err).Str("shortCode", result.ShortCode).Msg("Failed to save health check result")
+		}
+		if c.Notifier != nil && result.Broken() && !targets[i].WasBroken {
+			if err := c.Notifier.NotifyBroken(ctx, targets[i], result); err != nil {
+				log.Warn().Err(err).Str("shortCode", result.ShortCode).Msg("Failed to notify owner")
+			}
+		}
+	}
+	return nil
+}
+
+// CheckAll probes the targets with at most Concurrency probes in flight.
+// The results are in the order of targets.
+func (c *Checker) CheckAll(ctx context.Context, targets []Target) []Result {
+	c.withDefaults()
+	results := make([]Result, len(targets))
+	sem := make(chan struct{}, c.Concurrency)
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = c.Check(ctx, target)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Check probes one target.
+func (c *Checker) Check(ctx context.Context, target Target) Result {
+	c.withDefaults()
+	result := Result{ShortCode: target.ShortCode}
+
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		result.Err = err
+		result.CheckedAt = time.Now()
+		return result
+	}
+	if err := c.waitForHost(ctx, strings.ToLower(u.Host)); err != nil {
+		result.Err = err
+		result.CheckedAt = time.Now()
+		return result
+	}
+
+	start := time.Now()
+	status, err := c.probe(ctx, http.MethodHead, target.URL)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = c.probe(ctx, http.MethodGet, target.URL)
+	}
+	result.Latency = time.Since(start)
+	result.StatusCode = status
+	result.Err = err
+	result.CheckedAt = time.Now()
+	return result
+}
+
+// probe sends one request and returns the status code.
+func (c *Checker) probe(ctx context.Context, method, target string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// the body is not needed, read a little so the connection can be reused
+	io.CopyN(io.Discard, resp.Body, 4096)
+	return resp.StatusCode, nil
+}
+
+// waitForHost blocks until host may be probed again.
+func (c *Checker) waitForHost(ctx context.Context, host string) error {
+	c.mu.Lock()
+	now := time.Now()
+	next := c.hostNext[host]
+	if next.Before(now) {
+		next = now
+	}
+	c.hostNext[host] = next.Add(c.HostDelay)
+	c.mu.Unlock()
+
+	wait := time.Until(next)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,150 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-memory Store.
+type memoryStore struct {
+	mu      sync.Mutex
+	targets []Target
+	results map[string]Result
+}
+
+func (s *memoryStore) DueTargets(time.Time, int) ([]Target, error) {
+	return s.targets, nil
+}
+
+func (s *memoryStore) SaveResult(r Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[r.ShortCode] = r
+	return nil
+}
+
+// recordNotifier remembers the notified short codes.
+type recordNotifier struct {
+	mu    sync.Mutex
+	codes []string
+}
+
+func (n *recordNotifier) NotifyBroken(_ context.Context, t Target, _ Result) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.codes = append(n.codes, t.ShortCode)
+	return nil
+}
+
+func TestRunOnce(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	store := &memoryStore{
+		targets: []Target{
+			{ShortCode: "ok", URL: srv.URL + "/ok"},
+			{ShortCode: "missing", URL: srv.URL + "/missing"},
+			{ShortCode: "nohead", URL: srv.URL + "/no-head"},
+			{ShortCode: "slow", URL: srv.URL + "/slow"},
+			{ShortCode: "known", URL: srv.URL + "/missing", WasBroken: true},
+		},
+		results: map[string]Result{},
+	}
+	notifier := &recordNotifier{}
+	checker := &Checker{AllowPrivate: true, Timeout: 50 * time.Millisecond, HostDelay: time.Millisecond, Store: store, Notifier: notifier}
+
+	if err := checker.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := store.results["ok"]; r.Broken() || r.StatusCode != http.StatusOK {
+		t.Errorf("ok: unexpected result %+v", r)
+	}
+	if r := store.results["missing"]; !r.Broken() || r.StatusCode != http.StatusNotFound {
+		t.Errorf("missing: unexpected result %+v", r)
+	}
+	if r := store.results["nohead"]; r.Broken() {
+		t.Errorf("nohead: expected GET fallback, got %+v", r)
+	}
+	if r := store.results["slow"]; !r.Broken() || r.Err == nil {
+		t.Errorf("slow: expected timeout, got %+v", r)
+	}
+	if len(notifier.codes) != 2 {
+		t.Errorf("expected owners of missing and slow to be notified, got %v", notifier.codes)
+	}
+}
+
+func TestConcurrencyAndHostDelay(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	targets := make([]Target, 6)
+	for i := range targets {
+		targets[i] = Target{ShortCode: "c", URL: srv.URL}
+	}
+
+	checker := &Checker{AllowPrivate: true, Concurrency: 2, HostDelay: 20 * time.Millisecond}
+	start := time.Now()
+	checker.CheckAll(context.Background(), targets)
+
+	if maxInFlight.Load() > 2 {
+		t.Errorf("expected at most 2 probes in flight, got %d", maxInFlight.Load())
+	}
+	// six probes of one host, 20ms apart
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected host politeness delay, finished in %v", elapsed)
+	}
+}
+
+func TestCheckBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	checker := &Checker{HostDelay: time.Millisecond}
+	r := checker.Check(context.Background(), Target{ShortCode: "internal", URL: srv.URL})
+	if !errors.Is(r.Err, ErrBlockedAddress) {
+		t.Fatalf("expected ErrBlockedAddress, got %+v", r)
+	}
+}
+
+func TestCheckRedirectLimit(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, srv.URL, http.StatusFound)
+	}))
+	defer srv.Close()
+
+	checker := &Checker{AllowPrivate: true, MaxRedirect: 2, HostDelay: time.Millisecond}
+	r := checker.Check(context.Background(), Target{ShortCode: "loop", URL: srv.URL})
+	if !errors.Is(r.Err, ErrTooManyRedirects) {
+		t.Fatalf("expected ErrTooManyRedirects, got %+v", r)
+	}
+}
@@ -0,0 +1,69 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier posts broken links to a webhook as JSON:
+//
+//	{
+//	    "short_url": "abc123",
+//	    "owner_id": "uuid",
+//	    "destination": "https://www.example.com",
+//	    "status_code": 404,
+//	    "error": "",
+//	    "checked_at": "2025-01-01T00:00:00Z"
+//	}
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n WebhookNotifier) NotifyBroken(ctx context.Context, target Target, result Result) error {
+	payload := struct {
+		ShortURL    string    `json:"short_url"`
+		OwnerID     string    `json:"owner_id,omitempty"`
+		Destination string    `json:"destination"`
+		StatusCode  int       `json:"status_code"`
+		Error       string    `json:"error,omitempty"`
+		CheckedAt   time.Time `json:"checked_at"`
+	}{
+		ShortURL:    target.ShortCode,
+		OwnerID:     target.OwnerID,
+		Destination: target.URL,
+		StatusCode:  result.StatusCode,
+		CheckedAt:   result.CheckedAt,
+	}
+	if result.Err != nil {
+		payload.Error = result.Err.Error()
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("webhook responded %d", resp.StatusCode)
+	}
+	return nil
+}
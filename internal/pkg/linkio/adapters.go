@@ -0,0 +1,327 @@
+package linkio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formats of other shorteners, they can only be read.
+const (
+	FormatYOURLSSQL = "yourls-sql" // mysqldump of the YOURLS yourls_url table
+	FormatYOURLSCSV = "yourls-csv" // CSV export of the YOURLS admin
+	FormatBitly     = "bitly"      // Bitly link export CSV
+)
+
+// YOURLSColumns maps the columns of the YOURLS yourls_url table.
+var YOURLSColumns = ColumnMap{
+	Code:        "keyword",
+	URL:         "url",
+	Title:       "title",
+	CreatedAt:   "timestamp",
+	AccessCount: "clicks",
+}
+
+// BitlyColumns maps the columns of the Bitly CSV exports. Bitlinks such as
+// "bit.ly/3abcDEF" are reduced to their code.
+var BitlyColumns = ColumnMap{
+	Code:        "bitlink|link|short url|short_url|custom bitlinks",
+	URL:         "long_url|long url|destination url|original url",
+	Title:       "title",
+	Tags:        "tags",
+	TagSep:      ",",
+	CreatedAt:   "created_at|created|date created|creation date",
+	AccessCount: "clicks|total clicks|total_clicks|user_clicks",
+}
+
+// yourlsSQLColumns is the column order of yourls_url when an INSERT names no columns.
+var yourlsSQLColumns = []string{"keyword", "url", "title", "timestamp", "ip", "clicks"}
+
+// yourlsSQLReader reads the rows of INSERT statements for a table whose name ends
+// with "url", which is yourls_url for any YOURLS table prefix. Other statements are skipped.
+type yourlsSQLReader struct {
+	r       *prefixReader
+	columns []string
+	inRows  bool // 正在读取 VALUES 后的元组
+}
+
+func newYOURLSSQLReader(r io.Reader) *yourlsSQLReader {
+	return &yourlsSQLReader{r: &prefixReader{base: bufio.NewReader(r)}}
+}
+
+// prefixReader reads prefix before base, so the rest of a line can be put back.
+type prefixReader struct {
+	prefix     string
+	i          int
+	base       *bufio.Reader
+	fromPrefix bool
+}
+
+func (p *prefixReader) ReadByte() (byte, error) {
+	if p.i < len(p.prefix) {
+		p.i++
+		p.fromPrefix = true
+		return p.prefix[p.i-1], nil
+	}
+	p.fromPrefix = false
+	return p.base.ReadByte()
+}
+
+func (p *prefixReader) UnreadByte() error {
+	if p.fromPrefix {
+		p.i--
+		return nil
+	}
+	return p.base.UnreadByte()
+}
+
+// ReadLine reads up to and including the next '\n'.
+func (p *prefixReader) ReadLine() (string, error) {
+	var b strings.Builder
+	if p.i < len(p.prefix) {
+		rest := p.prefix[p.i:]
+		if n := strings.IndexByte(rest, '\n'); n >= 0 {
+			p.i += n + 1
+			return rest[:n+1], nil
+		}
+		b.WriteString(rest)
+		p.i = len(p.prefix)
+	}
+	line, err := p.base.ReadString('\n')
+	b.WriteString(line)
+	return b.String(), err
+}
+
+// Put makes s the next bytes to read. Unread bytes of the previous prefix are dropped,
+// s must be taken from them.
+func (p *prefixReader) Put(s string) {
+	p.prefix, p.i = s, 0
+}
+
+func (r *yourlsSQLReader) Read() (Record, error) {
+	for {
+		if !r.inRows {
+			if err := r.nextInsert(); err != nil {
+				return Record{}, err
+			}
+			r.inRows = true
+		}
+
+		values, more, err := r.tuple()
+		if err != nil {
+			return Record{}, err
+		}
+		r.inRows = more
+		if values == nil {
+			continue
+		}
+
+		row := make(map[string]string, len(values))
+		for i, v := range values {
+			if i < len(r.columns) {
+				row[r.columns[i]] = v
+			}
+		}
+		rec := Record{Code: row["keyword"], URL: row["url"], Title: row["title"]}
+		if rec.CreatedAt, err = ParseTime(row["timestamp"]); err != nil {
+			return Record{}, fmt.Errorf("linkio: keyword %q: %w", rec.Code, err)
+		}
+		if clicks := row["clicks"]; clicks != "" {
+			var n uint
+			if _, err := fmt.Sscanf(clicks, "%d", &n); err != nil {
+				return Record{}, fmt.Errorf("linkio: keyword %q: invalid clicks %q", rec.Code, clicks)
+			}
+			rec.AccessCount = n
+		}
+		return rec, nil
+	}
+}
+
+// nextInsert skips to the VALUES of the next INSERT into the url table and reads its column list.
+func (r *yourlsSQLReader) nextInsert() error {
+	for {
+		line, err := r.r.ReadLine()
+		if line == "" && err != nil {
+			return err
+		}
+		head, rest, ok := cutFold(line, "VALUES")
+		if !ok || !hasPrefixFold(strings.TrimSpace(head), "INSERT INTO") {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		target := strings.TrimSpace(strings.TrimSpace(head)[len("INSERT INTO"):])
+		table, columnList, _ := strings.Cut(target, "(")
+		table = strings.Trim(strings.TrimSpace(table), "`\"")
+		if !strings.HasSuffix(strings.ToLower(table), "url") {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		r.columns = yourlsSQLColumns
+		if columnList != "" {
+			r.columns = nil
+			for _, col := range strings.Split(strings.TrimSuffix(strings.TrimSpace(columnList), ")"), ",") {
+				r.columns = append(r.columns, strings.ToLower(strings.Trim(strings.TrimSpace(col), "`\"")))
+			}
+		}
+		// 把 VALUES 之后的内容放回读取流
+		r.r.Put(rest)
+		return nil
+	}
+}
+
+// tuple reads one "(...)" tuple of values and the separator after it.
+// more is false after the ';' ending the statement.
+func (r *yourlsSQLReader) tuple() (values []string, more bool, err error) {
+	if err := r.skipSpace(); err != nil {
+		return nil, false, unexpectedEOF(err)
+	}
+	c, _ := r.r.ReadByte()
+	if c == ';' {
+		return nil, false, nil
+	}
+	if c != '(' {
+		return nil, false, fmt.Errorf("linkio: unexpected %q in INSERT values", c)
+	}
+
+	for {
+		if err := r.skipSpace(); err != nil {
+			return nil, false, unexpectedEOF(err)
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, false, err
+		}
+		values = append(values, v)
+
+		if err := r.skipSpace(); err != nil {
+			return nil, false, unexpectedEOF(err)
+		}
+		c, _ := r.r.ReadByte()
+		if c == ',' {
+			continue
+		}
+		if c != ')' {
+			return nil, false, fmt.Errorf("linkio: unexpected %q in INSERT values", c)
+		}
+		break
+	}
+
+	if err := r.skipSpace(); err != nil {
+		return values, false, nil
+	}
+	c, _ = r.r.ReadByte()
+	return values, c == ',', nil
+}
+
+// value reads a quoted string, NULL or a bare number.
+func (r *yourlsSQLReader) value() (string, error) {
+	c, err := r.r.ReadByte()
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+	if c != '\'' && c != '"' {
+		var b strings.Builder
+		b.WriteByte(c)
+		for {
+			next, err := r.r.ReadByte()
+			if err != nil {
+				return "", unexpectedEOF(err)
+			}
+			if next == ',' || next == ')' || next == ' ' || next == '\t' || next == '\n' || next == '\r' {
+				r.r.UnreadByte()
+				break
+			}
+			b.WriteByte(next)
+		}
+		if strings.EqualFold(b.String(), "NULL") {
+			return "", nil
+		}
+		return b.String(), nil
+	}
+
+	quote := c
+	var b strings.Builder
+	for {
+		c, err := r.r.ReadByte()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		switch {
+		case c == '\\':
+			next, err := r.r.ReadByte()
+			if err != nil {
+				return "", unexpectedEOF(err)
+			}
+			b.WriteByte(unescapeSQL(next))
+		case c == quote:
+			// 连续两个引号表示一个引号
+			if next, err := r.r.ReadByte(); err == nil {
+				if next == quote {
+					b.WriteByte(quote)
+					continue
+				}
+				r.r.UnreadByte()
+			}
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (r *yourlsSQLReader) skipSpace() error {
+	for {
+		c, err := r.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return r.r.UnreadByte()
+		}
+	}
+}
+
+// unescapeSQL returns the byte of a MySQL backslash escape.
+func unescapeSQL(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case '0':
+		return 0
+	default:
+		return c
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// cutFold is strings.Cut with a case-insensitive ASCII separator.
+func cutFold(s, sep string) (before, after string, found bool) {
+	for i := 0; i+len(sep) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(sep)], sep) {
+			return s[:i], s[i+len(sep):], true
+		}
+	}
+	return s, "", false
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
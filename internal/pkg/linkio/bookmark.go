@@ -0,0 +1,169 @@
+package linkio
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const bookmarkHeader = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+`
+
+const bookmarkFooter = "</DL><p>\n"
+
+// bookmarkWriter writes the Netscape bookmark file format. Besides the usual
+// HREF, ADD_DATE and TAGS attributes, every link carries SHORTCODE, EXPIRE_DATE
+// and ACCESS_COUNT so the file can be imported again. Browsers ignore them.
+type bookmarkWriter struct {
+	w           *bufio.Writer
+	wroteHeader bool
+}
+
+func newBookmarkWriter(w io.Writer) *bookmarkWriter {
+	return &bookmarkWriter{w: bufio.NewWriter(w)}
+}
+
+func (w *bookmarkWriter) header() error {
+	if w.wroteHeader {
+		return nil
+	}
+	w.wroteHeader = true
+	_, err := w.w.WriteString(bookmarkHeader)
+	return err
+}
+
+func (w *bookmarkWriter) Write(r Record) error {
+	if err := w.header(); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `    <DT><A HREF="%s"`, html.EscapeString(r.URL))
+	if !r.CreatedAt.IsZero() {
+		fmt.Fprintf(&b, ` ADD_DATE="%d"`, r.CreatedAt.Unix())
+	}
+	if len(r.Tags) > 0 {
+		fmt.Fprintf(&b, ` TAGS="%s"`, html.EscapeString(strings.Join(r.Tags, ",")))
+	}
+	fmt.Fprintf(&b, ` SHORTCODE="%s"`, html.EscapeString(r.Code))
+	if !r.ExpireAt.IsZero() {
+		fmt.Fprintf(&b, ` EXPIRE_DATE="%d"`, r.ExpireAt.Unix())
+	}
+	fmt.Fprintf(&b, ` ACCESS_COUNT="%d"`, r.AccessCount)
+
+	title := r.Title
+	if title == "" {
+		title = r.URL
+	}
+	fmt.Fprintf(&b, ">%s</A>\n", html.EscapeString(title))
+
+	_, err := w.w.WriteString(b.String())
+	return err
+}
+
+func (w *bookmarkWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *bookmarkWriter) Close() error {
+	if err := w.header(); err != nil {
+		return err
+	}
+	if _, err := w.w.WriteString(bookmarkFooter); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// bookmarkReader reads the links of a bookmark file, folders are flattened.
+type bookmarkReader struct {
+	z *xhtml.Tokenizer
+}
+
+func newBookmarkReader(r io.Reader) (*bookmarkReader, error) {
+	return &bookmarkReader{z: xhtml.NewTokenizer(r)}, nil
+}
+
+func (r *bookmarkReader) Read() (Record, error) {
+	for {
+		switch r.z.Next() {
+		case xhtml.ErrorToken:
+			return Record{}, r.z.Err()
+		case xhtml.StartTagToken:
+			tok := r.z.Token()
+			if tok.DataAtom != atom.A {
+				continue
+			}
+			rec, err := bookmarkRecord(tok.Attr)
+			if err != nil {
+				return Record{}, err
+			}
+			if rec.URL == "" {
+				continue
+			}
+			rec.Title = r.text()
+			if rec.Title == rec.URL {
+				rec.Title = ""
+			}
+			return rec, nil
+		}
+	}
+}
+
+// text reads the text of the current element up to its end tag.
+func (r *bookmarkReader) text() string {
+	var b strings.Builder
+	for {
+		switch r.z.Next() {
+		case xhtml.TextToken:
+			b.Write(r.z.Text())
+		case xhtml.ErrorToken, xhtml.EndTagToken, xhtml.StartTagToken:
+			return strings.TrimSpace(b.String())
+		}
+	}
+}
+
+func bookmarkRecord(attrs []xhtml.Attribute) (Record, error) {
+	var rec Record
+	for _, attr := range attrs {
+		switch strings.ToLower(attr.Key) {
+		case "href":
+			rec.URL = strings.TrimSpace(attr.Val)
+		case "shortcode":
+			rec.Code = strings.TrimSpace(attr.Val)
+		case "tags":
+			rec.Tags = splitTags(attr.Val, ",")
+		case "add_date":
+			rec.CreatedAt = unixTime(attr.Val)
+		case "expire_date":
+			rec.ExpireAt = unixTime(attr.Val)
+		case "access_count":
+			n, err := strconv.ParseUint(attr.Val, 10, 32)
+			if err != nil {
+				return Record{}, fmt.Errorf("linkio: invalid access count %q", attr.Val)
+			}
+			rec.AccessCount = uint(n)
+		}
+	}
+	return rec, nil
+}
+
+// unixTime parses Unix seconds, invalid values are the zero time.
+func unixTime(s string) time.Time {
+	sec, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
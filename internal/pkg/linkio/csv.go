@@ -0,0 +1,267 @@
+package linkio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvHeader is the column order of exported CSV files, tags are separated by ';'.
+var csvHeader = []string{"code", "url", "title", "tags", "visibility", "namespace", "owner", "created_at", "expire_at", "access_count"}
+
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(r Record) error {
+	if !w.wroteHeader {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+	return w.w.Write([]string{
+		r.Code,
+		r.URL,
+		r.Title,
+		strings.Join(r.Tags, ";"),
+		r.Visibility,
+		r.Namespace,
+		r.Owner,
+		formatTime(r.CreatedAt),
+		formatTime(r.ExpireAt),
+		strconv.FormatUint(uint64(r.AccessCount), 10),
+	})
+}
+
+func (w *csvWriter) Close() error {
+	if !w.wroteHeader {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// Flush writes the buffered rows to the underlying writer.
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// ColumnMap maps Record fields to CSV column names. Empty fields are not read.
+// A field may list alternative names separated by '|', the first column present
+// in the header is used.
+type ColumnMap struct {
+	Code        string
+	URL         string
+	Title       string
+	Tags        string
+	TagSep      string // 默认 ";"
+	Visibility  string
+	Namespace   string
+	Owner       string
+	CreatedAt   string
+	ExpireAt    string
+	AccessCount string
+}
+
+// DefaultColumns is the column map of the files written by this package.
+var DefaultColumns = ColumnMap{
+	Code:        "code",
+	URL:         "url",
+	Title:       "title",
+	Tags:        "tags",
+	Visibility:  "visibility",
+	Namespace:   "namespace",
+	Owner:       "owner",
+	CreatedAt:   "created_at",
+	ExpireAt:    "expire_at",
+	AccessCount: "access_count",
+}
+
+// CSVReader reads records from a CSV file with a header line.
+type CSVReader struct {
+	r       *csv.Reader
+	columns ColumnMap
+	index   map[string]int
+	line    int
+}
+
+func newCSVReader(r io.Reader) (*CSVReader, error) {
+	return NewCSVReader(r, DefaultColumns)
+}
+
+// NewCSVReader reads the header of r and maps its columns with columns.
+// Column names are compared case-insensitively.
+func NewCSVReader(r io.Reader, columns ColumnMap) (*CSVReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("linkio: csv header is required")
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		// 去掉 Excel 导出文件的 BOM
+		name = strings.TrimPrefix(name, "\ufeff")
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := lookupColumn(index, columns.URL); !ok {
+		return nil, fmt.Errorf("linkio: csv header has no %q column", columns.URL)
+	}
+	if columns.TagSep == "" {
+		columns.TagSep = ";"
+	}
+	return &CSVReader{r: reader, columns: columns, index: index, line: 1}, nil
+}
+
+// Read returns the next record. Malformed values are reported with their line number.
+func (r *CSVReader) Read() (Record, error) {
+	row, err := r.r.Read()
+	if err != nil {
+		return Record{}, err
+	}
+	r.line++
+
+	field := func(name string) string {
+		i, ok := lookupColumn(r.index, name)
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	rec := Record{
+		Code:       codeFromURL(field(r.columns.Code)),
+		URL:        field(r.columns.URL),
+		Title:      field(r.columns.Title),
+		Tags:       splitTags(field(r.columns.Tags), r.columns.TagSep),
+		Visibility: field(r.columns.Visibility),
+		Namespace:  field(r.columns.Namespace),
+		Owner:      field(r.columns.Owner),
+	}
+	if rec.CreatedAt, err = ParseTime(field(r.columns.CreatedAt)); err != nil {
+		return Record{}, fmt.Errorf("linkio: line %d: %w", r.line, err)
+	}
+	if rec.ExpireAt, err = ParseTime(field(r.columns.ExpireAt)); err != nil {
+		return Record{}, fmt.Errorf("linkio: line %d: %w", r.line, err)
+	}
+	if count := field(r.columns.AccessCount); count != "" {
+		n, err := strconv.ParseUint(count, 10, 32)
+		if err != nil {
+			return Record{}, fmt.Errorf("linkio: line %d: invalid access count %q", r.line, count)
+		}
+		rec.AccessCount = uint(n)
+	}
+	return rec, nil
+}
+
+// lookupColumn returns the index of the first of the '|' separated names found in index.
+func lookupColumn(index map[string]int, names string) (int, bool) {
+	if names == "" {
+		return 0, false
+	}
+	for _, name := range strings.Split(names, "|") {
+		if i, ok := index[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// codeFromURL reduces a full short link such as "https://bit.ly/abc" to its code.
+// Short codes never contain '/', so plain codes are returned as they are.
+func codeFromURL(code string) string {
+	trimmed := strings.TrimRight(code, "/")
+	if i := strings.LastIndexByte(trimmed, '/'); i >= 0 {
+		return trimmed[i+1:]
+	}
+	return code
+}
+
+// ParseColumnMap parses a column mapping such as "code=Keyword,url=Long URL|Target,clicks=Hits".
+// Keys are code, url, title, tags, tag_sep, visibility, namespace, owner, created_at,
+// expire_at and clicks (or access_count). Unmapped fields are not read, url is required.
+func ParseColumnMap(spec string) (ColumnMap, error) {
+	var m ColumnMap
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, name, ok := strings.Cut(pair, "=")
+		if !ok {
+			return ColumnMap{}, fmt.Errorf("linkio: invalid column mapping %q", pair)
+		}
+		name = strings.TrimSpace(name)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "code":
+			m.Code = name
+		case "url":
+			m.URL = name
+		case "title":
+			m.Title = name
+		case "tags":
+			m.Tags = name
+		case "tag_sep":
+			m.TagSep = name
+		case "visibility":
+			m.Visibility = name
+		case "namespace":
+			m.Namespace = name
+		case "owner":
+			m.Owner = name
+		case "created_at":
+			m.CreatedAt = name
+		case "expire_at":
+			m.ExpireAt = name
+		case "clicks", "access_count":
+			m.AccessCount = name
+		default:
+			return ColumnMap{}, fmt.Errorf("linkio: unknown column mapping key %q", key)
+		}
+	}
+	if m.URL == "" {
+		return ColumnMap{}, errors.New("linkio: column mapping must map url")
+	}
+	return m, nil
+}
+
+// timeLayouts are the time formats accepted by ParseTime, besides Unix seconds.
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05-0700", "2006-01-02 15:04:05", "2006-01-02"}
+
+// ParseTime parses RFC 3339, "2006-01-02 15:04:05" (UTC), "2006-01-02" and Unix seconds.
+// An empty string is the zero time.
+func ParseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
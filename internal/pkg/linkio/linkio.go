@@ -0,0 +1,126 @@
+// Package linkio reads and writes short links in portable file formats:
+// CSV, JSON Lines (NDJSON) and the Netscape bookmark HTML file browsers import.
+// It also reads the exports of other shorteners (YOURLS and Bitly).
+//
+// Writers stream one record at a time, so a whole account can be exported
+// without holding it in memory.
+package linkio
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// Supported formats.
+const (
+	FormatCSV      = "csv"
+	FormatNDJSON   = "ndjson"
+	FormatBookmark = "html"
+)
+
+// ErrUnknownFormat is returned for a format name that is not supported.
+var ErrUnknownFormat = errors.New("linkio: unknown format")
+
+// Record is one exported or imported link.
+type Record struct {
+	Code        string    `json:"code"`
+	URL         string    `json:"url"`
+	Title       string    `json:"title,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Visibility  string    `json:"visibility,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Owner       string    `json:"owner,omitempty"` // 为空表示公共短链
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+	ExpireAt    time.Time `json:"expire_at,omitzero"`
+	AccessCount uint      `json:"access_count"`
+}
+
+// Writer writes records in one format. Writes may be buffered until Flush.
+// Close must be called to write the trailer of the file, it also flushes.
+type Writer interface {
+	Write(Record) error
+	Flush() error
+	Close() error
+}
+
+// Reader reads records in one format, it returns io.EOF after the last one.
+type Reader interface {
+	Read() (Record, error)
+}
+
+// NewWriter returns a Writer of format writing to w.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	case FormatBookmark:
+		return newBookmarkWriter(w), nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// NewReader returns a Reader of format reading from r.
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		return newNDJSONReader(r), nil
+	case FormatBookmark:
+		return newBookmarkReader(r)
+	case FormatYOURLSSQL:
+		return newYOURLSSQLReader(r), nil
+	case FormatYOURLSCSV:
+		return NewCSVReader(r, YOURLSColumns)
+	case FormatBitly:
+		return NewCSVReader(r, BitlyColumns)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// ContentType returns the MIME type of format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatBookmark:
+		return "text/html; charset=utf-8"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// FormatFromContentType guesses the format of an uploaded file from its MIME type.
+// It returns "" when the type does not name a format.
+func FormatFromContentType(contentType string) string {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	switch strings.TrimSpace(contentType) {
+	case "text/csv":
+		return FormatCSV
+	case "application/x-ndjson", "application/jsonl", "application/json-lines":
+		return FormatNDJSON
+	case "text/html":
+		return FormatBookmark
+	default:
+		return ""
+	}
+}
+
+// splitTags splits a tag list separated by sep, dropping empty tags.
+func splitTags(s, sep string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, sep) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
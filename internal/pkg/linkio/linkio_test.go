@@ -0,0 +1,226 @@
+package linkio
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRecords() []Record {
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []Record{
+		{
+			Code:        "spring",
+			URL:         "https://example.com/a?x=1&y=2",
+			Title:       `Spring "sale" <50%>`,
+			Tags:        []string{"campaign", "mail"},
+			Visibility:  "unlisted",
+			Owner:       "u1",
+			CreatedAt:   created,
+			ExpireAt:    created.Add(90 * 24 * time.Hour),
+			AccessCount: 42,
+		},
+		{
+			Code: "abc123XYZ0",
+			URL:  "https://example.org/",
+		},
+	}
+}
+
+func roundTrip(t *testing.T, format string, records []Record) []Record {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatalf("NewWriter(%q) error = %v", format, err)
+	}
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	r, err := NewReader(format, &buf)
+	if err != nil {
+		t.Fatalf("NewReader(%q) error = %v", format, err)
+	}
+	var got []Record
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		got = append(got, rec)
+	}
+	return got
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			want := testRecords()
+			if got := roundTrip(t, format, want); !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestBookmarkRoundTrip(t *testing.T) {
+	records := testRecords()
+	got := roundTrip(t, FormatBookmark, records)
+	if len(got) != len(records) {
+		t.Fatalf("got %d records, want %d", len(got), len(records))
+	}
+
+	// bookmark files do not carry visibility, namespace and owner
+	want := records[0]
+	want.Visibility, want.Owner = "", ""
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("first record\n got %+v\nwant %+v", got[0], want)
+	}
+	if got[1].Code != records[1].Code || got[1].URL != records[1].URL || got[1].Title != "" {
+		t.Errorf("second record = %+v", got[1])
+	}
+}
+
+func TestBookmarkReaderBrowserExport(t *testing.T) {
+	input := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<DL><p>
+    <DT><H3>Folder</H3>
+    <DL><p>
+        <DT><A HREF="https://go.dev/" ADD_DATE="1700000000" TAGS="go, lang">The Go Programming Language</A>
+    </DL><p>
+    <DT><A>no href</A>
+</DL><p>`
+
+	r, err := NewReader(FormatBookmark, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := r.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if rec.URL != "https://go.dev/" || rec.Title != "The Go Programming Language" || rec.Code != "" {
+		t.Errorf("record = %+v", rec)
+	}
+	if !reflect.DeepEqual(rec.Tags, []string{"go", "lang"}) || rec.CreatedAt.Unix() != 1700000000 {
+		t.Errorf("record tags/date = %v %v", rec.Tags, rec.CreatedAt)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("second Read() error = %v, want io.EOF", err)
+	}
+}
+
+func TestCSVReaderColumnMap(t *testing.T) {
+	input := "Short,Long URL,Clicks,Created\nabc,https://example.com,7,2024-01-02 03:04:05\n"
+	r, err := NewCSVReader(strings.NewReader(input), ColumnMap{Code: "short", URL: "long url", AccessCount: "clicks", CreatedAt: "created"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := r.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := Record{Code: "abc", URL: "https://example.com", AccessCount: 7, CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if !reflect.DeepEqual(rec, want) {
+		t.Errorf("record = %+v, want %+v", rec, want)
+	}
+
+	if _, err := NewCSVReader(strings.NewReader("a,b\n"), DefaultColumns); err == nil {
+		t.Error("NewCSVReader() without url column error = nil")
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("xml", io.Discard); err != ErrUnknownFormat {
+		t.Errorf("NewWriter() error = %v", err)
+	}
+	if _, err := NewReader("xml", strings.NewReader("")); err != ErrUnknownFormat {
+		t.Errorf("NewReader() error = %v", err)
+	}
+}
+
+func readAll(t *testing.T, r Reader) []Record {
+	t.Helper()
+	var got []Record
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		got = append(got, rec)
+	}
+}
+
+func TestYOURLSSQLReader(t *testing.T) {
+	input := "-- MySQL dump\n" +
+		"CREATE TABLE `yourls_url` (`keyword` varchar(100));\n" +
+		"INSERT INTO `yourls_options` VALUES (1,'version','1.9');\n" +
+		"INSERT INTO `yourls_url` VALUES ('ozh','http://ozh.org/','Ozh''s \\\"blog\\\"','2009-04-24 12:00:00','127.0.0.1',12),('yourls','http://yourls.org/',NULL,'2009-04-24 12:00:00','127.0.0.1',0);\n" +
+		"INSERT INTO yourls_url (`url`, `keyword`, `clicks`) VALUES\n('https://example.com/ü','ex',3);\n"
+
+	r, err := NewReader(FormatYOURLSSQL, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readAll(t, r)
+	want := []Record{
+		{Code: "ozh", URL: "http://ozh.org/", Title: `Ozh's "blog"`, CreatedAt: time.Date(2009, 4, 24, 12, 0, 0, 0, time.UTC), AccessCount: 12},
+		{Code: "yourls", URL: "http://yourls.org/", CreatedAt: time.Date(2009, 4, 24, 12, 0, 0, 0, time.UTC)},
+		{Code: "ex", URL: "https://example.com/ü", AccessCount: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestBitlyReader(t *testing.T) {
+	input := "\ufeffTitle,Bitlink,Long URL,Created,Clicks,Tags\n" +
+		"Docs,https://bit.ly/3abcDEF,https://example.com/docs,2023-01-05T10:00:00+0000,15,\"a, b\"\n"
+
+	r, err := NewReader(FormatBitly, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readAll(t, r)
+	want := []Record{{Code: "3abcDEF", URL: "https://example.com/docs", Title: "Docs", Tags: []string{"a", "b"}, CreatedAt: time.Date(2023, 1, 5, 10, 0, 0, 0, time.UTC), AccessCount: 15}}
+	if len(got) != 1 || !got[0].CreatedAt.Equal(want[0].CreatedAt) {
+		t.Fatalf("records = %+v", got)
+	}
+	got[0].CreatedAt = want[0].CreatedAt
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseColumnMap(t *testing.T) {
+	m, err := ParseColumnMap("code=Keyword, url=Long URL|Target ,clicks=Hits")
+	if err != nil {
+		t.Fatalf("ParseColumnMap() error = %v", err)
+	}
+	want := ColumnMap{Code: "Keyword", URL: "Long URL|Target", AccessCount: "Hits"}
+	if m != want {
+		t.Errorf("ParseColumnMap() = %+v, want %+v", m, want)
+	}
+
+	for _, spec := range []string{"code=a", "url", "link=a,url=b"} {
+		if _, err := ParseColumnMap(spec); err == nil {
+			t.Errorf("ParseColumnMap(%q) error = nil", spec)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package linkio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxNDJSONLine is the longest line read from an NDJSON file.
+const maxNDJSONLine = 1 << 20
+
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	bw := bufio.NewWriter(w)
+	return &ndjsonWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Write writes r as one JSON object followed by a newline.
+func (w *ndjsonWriter) Write(r Record) error {
+	return w.enc.Encode(r)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.w.Flush()
+}
+
+type ndjsonReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxNDJSONLine)
+	return &ndjsonReader{s: s}
+}
+
+// Read decodes the next non-empty line.
+func (r *ndjsonReader) Read() (Record, error) {
+	for r.s.Scan() {
+		r.line++
+		line := r.s.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return Record{}, fmt.Errorf("linkio: line %d: %w", r.line, err)
+		}
+		return rec, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
@@ -0,0 +1,295 @@
+// Package metadata fetches the title, description, preview image and favicon of a web page.
+//
+// The URLs come from users, so the Fetcher guards against SSRF: it only connects to
+// public IP addresses, checked on the resolved address of every connection including
+// redirects, follows a limited number of redirects and reads a limited part of the page
+// within a time budget.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultMaxBytes     = 512 << 10
+	defaultMaxRedirects = 5
+	userAgent           = "url-shortener-metadata/1.0"
+)
+
+var (
+	// ErrBlockedAddress is returned when the destination resolves to a non public address.
+	ErrBlockedAddress = errors.New("metadata: destination address is not public")
+	// ErrTooManyRedirects is returned when the redirect limit is exceeded.
+	ErrTooManyRedirects = errors.New("metadata: too many redirects")
+	// ErrNotHTML is returned when the destination is not an HTML page.
+	ErrNotHTML = errors.New("metadata: destination is not an HTML page")
+)
+
+// Metadata describes a web page.
+type Metadata struct {
+	// URL is the final URL after redirects, relative links are resolved against it.
+	URL         string
+	Title       string
+	Description string
+	Image       string
+	Favicon     string
+}
+
+// Fetcher fetches the metadata of pages. The zero value is ready to use.
+type Fetcher struct {
+	Timeout      time.Duration // 整个请求（含重定向）的超时
+	MaxBytes     int64         // 最多读取的页面字节数
+	MaxRedirects int           // 最多跟随的重定向次数
+	// AllowPrivate allows connections to private and loopback addresses, for tests only.
+	AllowPrivate bool
+
+	once   sync.Once
+	client *http.Client
+}
+
+// withDefaults fills the zero fields of the fetcher and builds its client.
+func (f *Fetcher) withDefaults() {
+	f.once.Do(func() {
+		if f.Timeout <= 0 {
+			f.Timeout = defaultTimeout
+		}
+		if f.MaxBytes <= 0 {
+			f.MaxBytes = defaultMaxBytes
+		}
+		if f.MaxRedirects <= 0 {
+			f.MaxRedirects = defaultMaxRedirects
+		}
+
+		dialer := &net.Dialer{Timeout: f.Timeout}
+		if !f.AllowPrivate {
+			dialer.Control = checkAddress
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil // 经代理连接时无法检查目标地址
+		transport.DialContext = dialer.DialContext
+		f.client = &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > f.MaxRedirects {
+					return ErrTooManyRedirects
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return fmt.Errorf("metadata: redirect to unsupported scheme %q", req.URL.Scheme)
+				}
+				return nil
+			},
+		}
+	})
+}
+
+// checkAddress rejects connections to non public addresses. It runs after DNS
+// resolution, so host names pointing to internal addresses are caught as well.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublic(addrPort.Addr()) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// nonPublicPrefixes are the special purpose ranges not covered by the netip predicates.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // TEST-NET-1
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // TEST-NET-2
+	netip.MustParsePrefix("203.0.113.0/24"),  // TEST-NET-3
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, may embed any IPv4 address
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// IsPublic reports whether addr is a globally routable unicast address.
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fetch downloads the page at rawURL and parses its metadata.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Metadata, error) {
+	f.withDefaults()
+	ctx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Metadata{}, fmt.Errorf("metadata: unsupported scheme %q", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Metadata{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return Metadata{}, fmt.Errorf("metadata: unexpected status %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Metadata{}, ErrNotHTML
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.MaxBytes), contentType)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return Parse(body, resp.Request.URL), nil
+}
+
+// Parse reads the metadata from the head of an HTML document. Relative URLs are
+// resolved against base. Without an icon link, the favicon is /favicon.ico of base.
+func Parse(r io.Reader, base *url.URL) Metadata {
+	var (
+		meta                         = Metadata{URL: base.String()}
+		title, ogTitle, twitterTitle string
+		description, ogDescription   string
+		icon, touchIcon              string
+		inTitle                      bool
+		z                            = html.NewTokenizer(r)
+	)
+
+parse:
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			break parse // io.EOF 或读取错误，已解析的部分仍然可用
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				break parse
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(val)
+			}
+
+			switch string(name) {
+			case "body":
+				break parse
+			case "title":
+				inTitle = tt == html.StartTagToken && title == ""
+			case "meta":
+				key := strings.ToLower(attrs["property"])
+				if key == "" {
+					key = strings.ToLower(attrs["name"])
+				}
+				content := strings.TrimSpace(attrs["content"])
+				switch key {
+				case "og:title":
+					ogTitle = content
+				case "twitter:title":
+					twitterTitle = content
+				case "og:description":
+					ogDescription = content
+				case "description", "twitter:description":
+					if description == "" {
+						description = content
+					}
+				case "og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src":
+					if meta.Image == "" {
+						meta.Image = resolve(base, content)
+					}
+				}
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+					switch {
+					case rel == "icon" && icon == "":
+						icon = resolve(base, attrs["href"])
+					case strings.HasPrefix(rel, "apple-touch-icon") && touchIcon == "":
+						touchIcon = resolve(base, attrs["href"])
+					}
+				}
+			}
+		}
+	}
+
+	meta.Title = clean(firstNonEmpty(title, ogTitle, twitterTitle))
+	meta.Description = clean(firstNonEmpty(ogDescription, description))
+	meta.Favicon = firstNonEmpty(icon, touchIcon, resolve(base, "/favicon.ico"))
+	return meta
+}
+
+// resolve returns ref as an absolute http(s) URL, or an empty string.
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+// clean collapses the white space of s.
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
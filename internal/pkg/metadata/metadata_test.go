@@ -0,0 +1,140 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const page = `<!DOCTYPE html>
+<html><head>
+<meta charset="utf-8">
+<title>
+  Example &amp; Co
+</title>
+<meta property="og:title" content="OG title">
+<meta name="description" content="Plain description">
+<meta property="og:image" content="/img/card.png">
+<link rel="apple-touch-icon" href="/touch.png">
+<link rel="shortcut icon" href="https://cdn.example.com/favicon.png">
+</head><body><title>not this</title></body></html>`
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://www.example.com/a/b")
+	got := Parse(strings.NewReader(page), base)
+	want := Metadata{
+		URL:         "https://www.example.com/a/b",
+		Title:       "Example & Co",
+		Description: "Plain description",
+		Image:       "https://www.example.com/img/card.png",
+		Favicon:     "https://cdn.example.com/favicon.png",
+	}
+	if got != want {
+		t.Errorf("Parse() = %+v, want %+v", got, want)
+	}
+
+	got = Parse(strings.NewReader(`<head><meta property="og:title" content="Only OG"></head>`), base)
+	if got.Title != "Only OG" || got.Favicon != "https://www.example.com/favicon.ico" {
+		t.Errorf("Parse() fallbacks = %+v", got)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for _, s := range []string{"8.8.8.8", "1.1.1.1", "2606:4700:4700::1111"} {
+		if !IsPublic(netip.MustParseAddr(s)) {
+			t.Errorf("IsPublic(%s) = false", s)
+		}
+	}
+	for _, s := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fd00::1", "fe80::1", "::ffff:127.0.0.1", "64:ff9b::a00:1"} {
+		if IsPublic(netip.MustParseAddr(s)) {
+			t.Errorf("IsPublic(%s) = true", s)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/latin1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte("<title>Caf\xe9</title>"))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<head>" + strings.Repeat(" ", 4096) + "<title>too far</title>"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := &Fetcher{AllowPrivate: true, Timeout: 100 * time.Millisecond, MaxRedirects: 3, MaxBytes: 1024}
+	ctx := context.Background()
+
+	meta, err := f.Fetch(ctx, srv.URL+"/moved")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if meta.URL != srv.URL+"/page" || meta.Title != "Example & Co" || meta.Image != srv.URL+"/img/card.png" {
+		t.Errorf("Fetch() = %+v", meta)
+	}
+	if meta, err := f.Fetch(ctx, srv.URL+"/latin1"); err != nil || meta.Title != "Café" {
+		t.Errorf("Fetch(latin1) = %+v, %v", meta, err)
+	}
+	if meta, err := f.Fetch(ctx, srv.URL+"/large"); err != nil || meta.Title != "" {
+		t.Errorf("Fetch(large) = %+v, %v, want the size budget to stop reading", meta, err)
+	}
+
+	if _, err := f.Fetch(ctx, srv.URL+"/loop"); !errors.Is(err, ErrTooManyRedirects) {
+		t.Errorf("Fetch(loop) error = %v, want ErrTooManyRedirects", err)
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/image"); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("Fetch(image) error = %v, want ErrNotHTML", err)
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/slow"); err == nil {
+		t.Error("Fetch(slow) error = nil, want timeout")
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/missing"); err == nil {
+		t.Error("Fetch(missing) error = nil, want status error")
+	}
+	if _, err := f.Fetch(ctx, "ftp://example.com/"); err == nil {
+		t.Error("Fetch(ftp) error = nil")
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<title>internal</title>"))
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{}
+	if _, err := f.Fetch(context.Background(), srv.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch(loopback) error = %v, want ErrBlockedAddress", err)
+	}
+	// 通过主机名解析到回环地址同样被拦截
+	u := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	if _, err := f.Fetch(context.Background(), u); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch(localhost) error = %v, want ErrBlockedAddress", err)
+	}
+}
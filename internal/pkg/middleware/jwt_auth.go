@@ -60,3 +60,18 @@ func JwtAuth(testMode bool) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// OptionalJwtAuth middleware, identifies the caller when a valid access token is sent.
+// Anonymous requests and invalid tokens are let through without user information,
+// so it can be used on routes that are public but behave differently for owners.
+func OptionalJwtAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if accessToken := c.GetHeader("Authorization"); accessToken != "" {
+			if claims, err := util.ParseAccessToken(accessToken); err == nil {
+				c.Set("user_id", claims.UserID)
+				c.Set("email", claims.Email)
+			}
+		}
+		c.Next()
+	}
+}
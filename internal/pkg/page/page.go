@@ -0,0 +1,88 @@
+// Package page renders the small HTML pages served on the redirect path,
+// such as the app deep-link page. Templates are embedded into the binary.
+package page
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// Render executes the named template and writes it with the given status code.
+//
+// The page is rendered into a buffer first, so a template error never leaves
+// a half written response.
+func Render(c *gin.Context, status int, name string, data any) {
+	tmpl := templates.Lookup(name)
+	if tmpl == nil {
+		log.Error().Str("template", name).Msg("Page template not found")
+		c.String(http.StatusInternalServerError, "internal server error")
+		return
+	}
+	RenderTemplate(c, status, tmpl, data)
+}
+
+// RenderTemplate executes tmpl and writes it with the given status code, like Render.
+func RenderTemplate(c *gin.Context, status int, tmpl *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Err(err).Str("template", tmpl.Name()).Msg("Failed to render page")
+		c.String(http.StatusInternalServerError, "internal server error")
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// Dir loads page templates from a directory, so operators can restyle pages
+// without rebuilding. A template is parsed again when its file changes.
+type Dir struct {
+	root  string
+	mu    sync.Mutex
+	cache map[string]dirTemplate
+}
+
+type dirTemplate struct {
+	modTime time.Time
+	tmpl    *template.Template
+}
+
+// NewDir returns the templates stored in the directory root.
+func NewDir(root string) *Dir {
+	return &Dir{root: root, cache: make(map[string]dirTemplate)}
+}
+
+// Lookup returns the template stored in the file name, relative to the directory.
+// Missing files and invalid templates return false, the latter are logged.
+func (d *Dir) Lookup(name string) (*template.Template, bool) {
+	path := filepath.Join(d.root, filepath.FromSlash(name))
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return nil, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cached, ok := d.cache[name]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.tmpl, true
+	}
+	tmpl, err := template.ParseFiles(path)
+	if err != nil {
+		log.Err(err).Str("template", path).Msg("Failed to parse page template")
+		return nil, false
+	}
+	d.cache[name] = dirTemplate{modTime: info.ModTime(), tmpl: tmpl}
+	return tmpl, true
+}
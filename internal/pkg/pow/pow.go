@@ -0,0 +1,139 @@
+// Package pow issues and checks proof-of-work challenges.
+//
+// A challenge is stateless: it carries its expiration and difficulty and is signed
+// with an HMAC key, so any replica sharing the key can check it. A client solves it
+// by finding a nonce such that SHA-256(challenge + ":" + nonce) starts with
+// difficulty zero bits. Every solved challenge is accepted once per SpentSet.
+package pow
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDifficulty keeps challenges solvable in a browser.
+const maxDifficulty = 32
+
+var (
+	ErrInvalid  = errors.New("invalid challenge")
+	ErrExpired  = errors.New("challenge expired")
+	ErrUnsolved = errors.New("challenge not solved")
+	ErrSpent    = errors.New("challenge already used")
+)
+
+// Challenge is a challenge handed to a client.
+type Challenge struct {
+	Challenge  string    `json:"challenge"`
+	Difficulty int       `json:"difficulty"` // 哈希需要的前导零比特数
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Issuer issues challenges of one difficulty and checks their solutions.
+type Issuer struct {
+	key        []byte
+	difficulty int
+	ttl        time.Duration
+	spent      SpentSet
+}
+
+// NewIssuer creates an issuer signing with key. Challenges need difficulty leading
+// zero bits, at most 32, and expire after ttl. Solved challenges are remembered in
+// spent, or in process when it is nil.
+func NewIssuer(key []byte, difficulty int, ttl time.Duration, spent SpentSet) *Issuer {
+	if spent == nil {
+		spent = NewMemorySpentSet()
+	}
+	return &Issuer{
+		key:        key,
+		difficulty: min(max(difficulty, 0), maxDifficulty),
+		ttl:        ttl,
+		spent:      spent,
+	}
+}
+
+// Issue returns a new challenge expiring ttl after now.
+func (i *Issuer) Issue(now time.Time) (Challenge, error) {
+	payload := make([]byte, 8+1+16)
+	expiresAt := now.Add(i.ttl).Truncate(time.Second)
+	binary.BigEndian.PutUint64(payload, uint64(expiresAt.Unix()))
+	payload[8] = byte(i.difficulty)
+	if _, err := rand.Read(payload[9:]); err != nil {
+		return Challenge{}, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return Challenge{
+		Challenge:  encoded + "." + i.sign(encoded),
+		Difficulty: i.difficulty,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// sign returns the signature of an encoded payload.
+func (i *Issuer) sign(encoded string) string {
+	mac := hmac.New(sha256.New, i.key)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Verify checks that nonce solves challenge and marks the challenge as used. Errors
+// of the spent set are returned as they are.
+func (i *Issuer) Verify(ctx context.Context, challenge, nonce string, now time.Time) error {
+	encoded, sig, ok := strings.Cut(challenge, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(i.sign(encoded))) {
+		return ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) != 8+1+16 {
+		return ErrInvalid
+	}
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	if !now.Before(expiresAt) {
+		return ErrExpired
+	}
+	if !Solves(challenge, nonce, int(payload[8])) {
+		return ErrUnsolved
+	}
+
+	unused, err := i.spent.Spend(ctx, challenge, expiresAt, now)
+	if err != nil {
+		return err
+	}
+	if !unused {
+		return ErrSpent
+	}
+	return nil
+}
+
+// Solves reports whether the hash of challenge and nonce starts with difficulty zero bits.
+func Solves(challenge, nonce string, difficulty int) bool {
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			zeros += bits.LeadingZeros8(b)
+			break
+		}
+		zeros += 8
+	}
+	return zeros >= difficulty
+}
+
+// Solve finds the nonce of a challenge, the way a client does.
+func Solve(challenge string, difficulty int) string {
+	for n := 0; ; n++ {
+		nonce := strconv.Itoa(n)
+		if Solves(challenge, nonce, difficulty) {
+			return nonce
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package pow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIssuer(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	issuer := NewIssuer([]byte("secret"), 8, time.Minute, nil)
+
+	c, err := issuer.Issue(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Difficulty != 8 || !c.ExpiresAt.After(now) {
+		t.Fatalf("Issue() = %+v", c)
+	}
+
+	nonce := Solve(c.Challenge, c.Difficulty)
+	wrong := "x"
+	for Solves(c.Challenge, wrong, c.Difficulty) {
+		wrong += "x"
+	}
+	if err := issuer.Verify(ctx, c.Challenge, wrong, now); !errors.Is(err, ErrUnsolved) {
+		t.Fatalf("Verify(wrong nonce) = %v", err)
+	}
+	if err := issuer.Verify(ctx, c.Challenge, nonce, now.Add(2*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Verify(expired) = %v", err)
+	}
+	if err := issuer.Verify(ctx, c.Challenge, nonce, now); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+	if err := issuer.Verify(ctx, c.Challenge, nonce, now); !errors.Is(err, ErrSpent) {
+		t.Fatalf("Verify(reused) = %v", err)
+	}
+
+	other := NewIssuer([]byte("other"), 8, time.Minute, nil)
+	if err := other.Verify(ctx, c.Challenge, nonce, now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Verify(other key) = %v", err)
+	}
+	if err := issuer.Verify(ctx, "garbage", "0", now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Verify(garbage) = %v", err)
+	}
+}
+
+// failingSpentSet is a SpentSet which can not be reached.
+type failingSpentSet struct{ err error }
+
+func (s failingSpentSet) Spend(context.Context, string, time.Time, time.Time) (bool, error) {
+	return false, s.err
+}
+
+func TestIssuerSpentSet(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// replicas sharing the key and the spent set accept a challenge once
+	spent := NewMemorySpentSet()
+	a := NewIssuer([]byte("secret"), 4, time.Minute, spent)
+	b := NewIssuer([]byte("secret"), 4, time.Minute, spent)
+	c, err := a.Issue(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := Solve(c.Challenge, c.Difficulty)
+	if err := a.Verify(ctx, c.Challenge, nonce, now); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+	if err := b.Verify(ctx, c.Challenge, nonce, now); !errors.Is(err, ErrSpent) {
+		t.Fatalf("Verify(reused on another replica) = %v", err)
+	}
+
+	unreachable := errors.New("connection refused")
+	failing := NewIssuer([]byte("secret"), 4, time.Minute, failingSpentSet{err: unreachable})
+	if err := failing.Verify(ctx, c.Challenge, nonce, now); !errors.Is(err, unreachable) {
+		t.Fatalf("Verify(unreachable spent set) = %v", err)
+	}
+}
+
+func TestSolves(t *testing.T) {
+	if !Solves("anything", "0", 0) {
+		t.Error("difficulty 0 is not solved by any nonce")
+	}
+	nonce := Solve("challenge", 12)
+	if !Solves("challenge", nonce, 12) {
+		t.Errorf("Solve() = %q does not solve the challenge", nonce)
+	}
+}
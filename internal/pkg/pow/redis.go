@@ -0,0 +1,25 @@
+package pow
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisSpentSet is a SpentSet shared by every process using the same Redis. A
+// challenge is spent by the first SET NX of its key, which expires with it.
+type RedisSpentSet struct {
+	client redis.Cmdable
+	prefix string // Redis 键前缀
+}
+
+// NewRedisSpentSet creates a spent set keeping its challenges in Redis under prefix.
+func NewRedisSpentSet(client redis.Cmdable, prefix string) *RedisSpentSet {
+	return &RedisSpentSet{client: client, prefix: prefix}
+}
+
+// Spend marks challenge as used until expiresAt and reports whether it was unused.
+func (s *RedisSpentSet) Spend(ctx context.Context, challenge string, expiresAt, now time.Time) (bool, error) {
+	return s.client.SetNX(ctx, s.prefix+challenge, 1, expiresAt.Sub(now)).Result()
+}
@@ -0,0 +1,46 @@
+package pow
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxSpent is the number of solved challenges kept before expired ones are dropped.
+const maxSpent = 100000
+
+// SpentSet remembers the solved challenges until they expire, so each of them is
+// accepted once.
+type SpentSet interface {
+	// Spend marks challenge as used until expiresAt and reports whether it was unused.
+	Spend(ctx context.Context, challenge string, expiresAt, now time.Time) (bool, error)
+}
+
+// MemorySpentSet is a SpentSet of one process.
+type MemorySpentSet struct {
+	mu    sync.Mutex
+	spent map[string]time.Time // 已使用的挑战及其过期时间
+}
+
+// NewMemorySpentSet creates an empty in-process spent set.
+func NewMemorySpentSet() *MemorySpentSet {
+	return &MemorySpentSet{spent: make(map[string]time.Time)}
+}
+
+// Spend marks challenge as used, dropping expired challenges once the set is full.
+func (s *MemorySpentSet) Spend(_ context.Context, challenge string, expiresAt, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.spent[challenge]; ok {
+		return false, nil
+	}
+	if len(s.spent) >= maxSpent {
+		for c, exp := range s.spent {
+			if !now.Before(exp) {
+				delete(s.spent, c)
+			}
+		}
+	}
+	s.spent[challenge] = expiresAt
+	return true, nil
+}
@@ -0,0 +1,270 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" at 1-M, from the Thonky QR code tutorial
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomonRemainder(data, reedSolomonDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("reedSolomonRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	formats := map[Level]int{Low: 0b111011111000100, Medium: 0b101010000010010, Quartile: 0b011010101011111, High: 0b001011010001001}
+	for level, want := range formats {
+		if got := formatBits(level, 0); got != want {
+			t.Errorf("formatBits(%v, 0) = %015b, want %015b", level, got, want)
+		}
+	}
+	if got := formatBits(Medium, 5); got != 0b100000011001110 {
+		t.Errorf("formatBits(M, 5) = %015b", got)
+	}
+	if got := versionBits(7); got != 0b000111110010010100 {
+		t.Errorf("versionBits(7) = %018b", got)
+	}
+	if got := versionBits(40); got != 0b101000110001101001 {
+		t.Errorf("versionBits(40) = %018b", got)
+	}
+}
+
+func TestAlignmentPatternPositions(t *testing.T) {
+	tests := map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		7:  {6, 22, 38},
+		32: {6, 34, 60, 86, 112, 138},
+		40: {6, 30, 58, 86, 114, 142, 170},
+	}
+	for version, want := range tests {
+		if got := alignmentPatternPositions(version); !reflect.DeepEqual(got, want) {
+			t.Errorf("alignmentPatternPositions(%d) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestDataCapacity(t *testing.T) {
+	// data codewords from the capacity table of the standard
+	tests := []struct {
+		version int
+		level   Level
+		want    int
+	}{
+		{1, Low, 19}, {1, High, 9}, {5, Quartile, 62}, {10, Medium, 216}, {40, Low, 2956}, {40, High, 1276},
+	}
+	for _, tt := range tests {
+		if got := numDataCodewords(tt.version, tt.level); got != tt.want {
+			t.Errorf("numDataCodewords(%d, %v) = %d, want %d", tt.version, tt.level, got, tt.want)
+		}
+	}
+}
+
+// decode reads the data back from a symbol, checking the format information and
+// the error correction codewords of every block.
+func decode(t *testing.T, c *Code) []byte {
+	t.Helper()
+
+	var bits int
+	for i := 0; i <= 5; i++ {
+		bits |= b2i(c.Dark(8, i)) << i
+	}
+	bits |= b2i(c.Dark(8, 7))<<6 | b2i(c.Dark(8, 8))<<7 | b2i(c.Dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		bits |= b2i(c.Dark(14-i, 8)) << i
+	}
+	if want := formatBits(c.Level, c.Mask); bits != want {
+		t.Fatalf("format bits = %015b, want %015b", bits, want)
+	}
+
+	// 复制一份后去掉掩码，再按之字形读出码字
+	d := newCode(c.Version, c.Level)
+	d.drawFunctionPatterns()
+	for y := range c.Size {
+		copy(d.modules[y], c.modules[y])
+	}
+	d.applyMask(c.Mask)
+
+	raw := make([]byte, numRawDataModules(c.Version)/8)
+	i := 0
+	for right := d.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := range d.Size {
+			for j := range 2 {
+				x, y := right-j, vert
+				if upward {
+					y = d.Size - 1 - vert
+				}
+				if !d.isFunction[y][x] && i < len(raw)*8 {
+					if d.modules[y][x] {
+						raw[i>>3] |= 1 << (7 - i&7)
+					}
+					i++
+				}
+			}
+		}
+	}
+
+	numBlocks := numErrorCorrectionBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	numShort := numBlocks - len(raw)%numBlocks
+	shortDataLen := len(raw)/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortDataLen; i++ {
+		for j := range blocks {
+			if i < shortDataLen || j >= numShort {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], raw[k])
+			k++
+		}
+	}
+
+	divisor := reedSolomonDivisor(eccLen)
+	var data []byte
+	for j, block := range blocks {
+		dat, ecc := block[:len(block)-eccLen], block[len(block)-eccLen:]
+		if got := reedSolomonRemainder(dat, divisor); !bytes.Equal(got, ecc) {
+			t.Fatalf("block %d: ecc mismatch", j)
+		}
+		data = append(data, dat...)
+	}
+
+	if data[0]>>4 != 0x4 {
+		t.Fatalf("mode = %x, want byte mode", data[0]>>4)
+	}
+	read := func(pos, n int) int {
+		v := 0
+		for i := range n {
+			bit := (data[(pos+i)>>3] >> (7 - (pos+i)&7)) & 1
+			v = v<<1 | int(bit)
+		}
+		return v
+	}
+	countBits := charCountBits(c.Version)
+	n := read(4, countBits)
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(read(4+countBits+8*i, 8))
+	}
+	return out
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"https://s.example.com/abc123XYZ0",
+		strings.Repeat("https://example.com/", 20),
+		strings.Repeat("x", 1273), // version 40-H 容量上限
+	}
+	for _, input := range inputs {
+		for level := Low; level <= High; level++ {
+			c, err := Encode([]byte(input), level)
+			if len(input) == 1273 && level != High {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Encode(%d bytes, %v) error = %v", len(input), level, err)
+			}
+			if c.Size != c.Version*4+17 {
+				t.Errorf("size = %d for version %d", c.Size, c.Version)
+			}
+			if got := decode(t, c); string(got) != input {
+				t.Errorf("decode(Encode(%d bytes, %v)) = %d bytes", len(input), level, len(got))
+			}
+		}
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	c, err := Encode([]byte("https://s.example.com/abc123XYZ0"), Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 32 bytes need 4+8+256 bits, version 2-M holds 28 data codewords, version 3-M 44
+	if c.Version != 3 {
+		t.Errorf("version = %d, want 3", c.Version)
+	}
+
+	if _, err := Encode(make([]byte, 1274), High); err != ErrTooLong {
+		t.Errorf("Encode(1274 bytes, H) error = %v, want ErrTooLong", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	c, err := Encode([]byte("https://example.com"), Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultRenderOptions()
+	opts.Size = 100
+
+	data, err := c.PNG(opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	// 25 个模块加 8 个边距模块，每个模块 3 像素，不足 100 像素时补到边距
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 100 {
+		t.Errorf("PNG size = %v, want 100x100", b)
+	}
+	size, scale, offset := c.layout(opts)
+	if size != 100 || scale != 3 || offset != 12 {
+		t.Errorf("layout() = %d, %d, %d", size, scale, offset)
+	}
+	if r, _, _, _ := img.At(offset, offset).RGBA(); r != 0 {
+		t.Error("top left finder module is not dark")
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Error("margin is not light")
+	}
+
+	svg := string(c.SVG(opts))
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `fill="#000000"`) || !strings.Contains(svg, `width="100"`) {
+		t.Errorf("SVG() = %s", svg)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := map[string]color.NRGBA{
+		"#000":      {A: 0xff},
+		"ff8800":    {R: 0xff, G: 0x88, A: 0xff},
+		"#11223380": {R: 0x11, G: 0x22, B: 0x33, A: 0x80},
+	}
+	for s, want := range tests {
+		if got, err := ParseColor(s); err != nil || got != want {
+			t.Errorf("ParseColor(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "red", "#12345", "#gggggg"} {
+		if _, err := ParseColor(s); err == nil {
+			t.Errorf("ParseColor(%q) error = nil", s)
+		}
+	}
+}
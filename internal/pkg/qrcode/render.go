@@ -0,0 +1,129 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// DefaultMargin is the quiet zone width in modules required by the standard.
+const DefaultMargin = 4
+
+// RenderOptions controls the rendered image.
+type RenderOptions struct {
+	// Size is the width and height of the image in pixels. Every module gets the same
+	// whole number of pixels, the rest is added to the margin. It is raised to one
+	// pixel per module if too small.
+	Size int
+	// Margin is the quiet zone width in modules.
+	Margin     int
+	Foreground color.NRGBA
+	Background color.NRGBA
+}
+
+// DefaultRenderOptions renders black on white with the standard margin.
+func DefaultRenderOptions() RenderOptions {
+	return RenderOptions{
+		Size:       256,
+		Margin:     DefaultMargin,
+		Foreground: color.NRGBA{A: 0xff},
+		Background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+// layout returns the image size, the pixels per module and the offset of the first module.
+func (c *Code) layout(opts RenderOptions) (size, scale, offset int) {
+	modules := c.Size + 2*opts.Margin
+	scale = max(1, opts.Size/modules)
+	size = max(opts.Size, modules*scale)
+	offset = (size - c.Size*scale) / 2
+	return size, scale, offset
+}
+
+// PNG renders the symbol as a PNG image.
+func (c *Code) PNG(opts RenderOptions) ([]byte, error) {
+	size, scale, offset := c.layout(opts)
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{opts.Background, opts.Foreground})
+	for y := range c.Size {
+		for x := range c.Size {
+			if !c.modules[y][x] {
+				continue
+			}
+			for py := range scale {
+				row := img.Pix[(offset+y*scale+py)*img.Stride:]
+				for px := range scale {
+					row[offset+x*scale+px] = 1
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the symbol as an SVG image, one path for all dark modules.
+func (c *Code) SVG(opts RenderOptions) []byte {
+	size, scale, offset := c.layout(opts)
+
+	var path strings.Builder
+	for y := range c.Size {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			// 合并同一行连续的深色模块
+			run := 1
+			for x+run < c.Size && c.modules[y][x+run] {
+				run++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv%dh-%dz", offset+x*scale, offset+y*scale, run*scale, scale, run*scale)
+			x += run - 1
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, size, size)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"%s/>`, hexColor(opts.Background), opacity(opts.Background))
+	fmt.Fprintf(&buf, `<path d="%s" fill="%s"%s/>`, path.String(), hexColor(opts.Foreground), opacity(opts.Foreground))
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func opacity(c color.NRGBA) string {
+	if c.A == 0xff {
+		return ""
+	}
+	return fmt.Sprintf(` fill-opacity="%.3f"`, float64(c.A)/0xff)
+}
+
+// ParseColor parses a hex color: "RGB", "RRGGBB" or "RRGGBBAA", with or without '#'.
+// Colors with alpha are returned non-premultiplied, as the renderers expect.
+func ParseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("qrcode: invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("qrcode: invalid color %q", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
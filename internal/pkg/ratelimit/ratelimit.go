@@ -0,0 +1,80 @@
+// Package ratelimit provides keyed token bucket limiters.
+//
+// Unlike the tollbooth middleware used on the login route, a Limiter can take
+// several tokens at once, so a whole batch can be charged against one key.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxIdleBuckets is the number of buckets kept before full buckets are dropped.
+const maxIdleBuckets = 10000
+
+// Limiter decides whether n events for key may happen now.
+type Limiter interface {
+	AllowN(ctx context.Context, key string, n int) (bool, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryLimiter is an in-process Limiter. Every key has its own bucket which
+// refills at rate tokens per second up to burst tokens.
+type MemoryLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemoryLimiter creates a limiter refilling rate tokens per second up to burst.
+func NewMemoryLimiter(rate float64, burst int) *MemoryLimiter {
+	return &MemoryLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// AllowN takes n tokens from the bucket of key if it has enough of them.
+// A batch larger than the burst is never allowed.
+func (l *MemoryLimiter) AllowN(_ context.Context, key string, n int) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.dropFullBuckets(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < float64(n) {
+		return false, nil
+	}
+	b.tokens -= float64(n)
+	return true, nil
+}
+
+// dropFullBuckets forgets the keys whose bucket has refilled completely,
+// they behave exactly like new keys.
+func (l *MemoryLimiter) dropFullBuckets(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	now := time.Now()
+	l := NewMemoryLimiter(1, 10)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if ok, _ := l.AllowN(ctx, "a", 8); !ok {
+		t.Fatal("expected first batch to be allowed")
+	}
+	if ok, _ := l.AllowN(ctx, "a", 3); ok {
+		t.Fatal("expected batch over the remaining tokens to be rejected")
+	}
+	if ok, _ := l.AllowN(ctx, "b", 10); !ok {
+		t.Fatal("expected other key to have its own bucket")
+	}
+	if ok, _ := l.AllowN(ctx, "c", 11); ok {
+		t.Fatal("expected batch larger than burst to be rejected")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := l.AllowN(ctx, "a", 3); !ok {
+		t.Fatal("expected bucket to refill")
+	}
+}
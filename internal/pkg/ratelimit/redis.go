@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucket takes ARGV[3] tokens from the bucket in KEYS[1], which refills at
+// ARGV[1] tokens per second up to ARGV[2] tokens. The clock of the Redis server is
+// used, so replicas with skewed clocks share the same bucket correctly. Idle buckets
+// expire once they would be full again.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or burst
+local last = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+
+local allowed = 0
+if tokens >= n then
+  tokens = tokens - n
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'last', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return allowed
+`)
+
+// RedisLimiter is a Limiter shared by every process using the same Redis. Every
+// key has its own bucket which refills at rate tokens per second up to burst tokens.
+type RedisLimiter struct {
+	client redis.Scripter
+	prefix string // Redis 键前缀
+	rate   float64
+	burst  int
+}
+
+// NewRedisLimiter creates a limiter refilling rate tokens per second up to burst,
+// keeping its buckets in Redis under prefix.
+func NewRedisLimiter(client redis.Scripter, prefix string, rate float64, burst int) *RedisLimiter {
+	return &RedisLimiter{
+		client: client,
+		prefix: prefix,
+		rate:   rate,
+		burst:  burst,
+	}
+}
+
+// AllowN takes n tokens from the bucket of key if it has enough of them.
+// A batch larger than the burst is never allowed.
+func (l *RedisLimiter) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	if n > l.burst {
+		return false, nil
+	}
+	allowed, err := tokenBucket.Run(ctx, l.client, []string{l.prefix + key}, l.rate, l.burst, n).Int()
+	if err != nil {
+		return false, err
+	}
+	return allowed == 1, nil
+}
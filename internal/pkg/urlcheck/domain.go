@@ -0,0 +1,197 @@
+package urlcheck
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DomainList matches hosts against a blocklist and an allowlist.
+//
+// A pattern is either a host name, which matches only that host, or
+// "*.example.com", which matches example.com and all of its subdomains.
+// Allowlisted hosts are trusted. With AllowlistOnly set, hosts that are
+// not on the allowlist are rejected.
+type DomainList struct {
+	mu            sync.RWMutex
+	block         []string
+	allow         []string
+	allowlistOnly bool
+}
+
+// NewDomainList creates a domain list from the given patterns.
+func NewDomainList(block, allow []string, allowlistOnly bool) *DomainList {
+	l := &DomainList{allowlistOnly: allowlistOnly}
+	l.Set(block, allow)
+	return l
+}
+
+// Set replaces both lists.
+func (l *DomainList) Set(block, allow []string) {
+	block, allow = normalizePatterns(block), normalizePatterns(allow)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.block, l.allow = block, allow
+}
+
+// Check implements URLChecker.
+func (l *DomainList) Check(_ context.Context, rawURL string) (Verdict, error) {
+	host := hostOf(rawURL)
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if matchAny(l.allow, host) {
+		return Verdict{Allowed: true, Trusted: true}, nil
+	}
+	if matchAny(l.block, host) {
+		return Verdict{Allowed: false, Reason: "domain " + host + " is blocked"}, nil
+	}
+	if l.allowlistOnly {
+		return Verdict{Allowed: false, Reason: "domain " + host + " is not allowed"}, nil
+	}
+	return Verdict{Allowed: true}, nil
+}
+
+// Blocked reports whether the host of rawURL is on the blocklist and not allowlisted.
+func (l *DomainList) Blocked(rawURL string) bool {
+	host := hostOf(rawURL)
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return !matchAny(l.allow, host) && matchAny(l.block, host)
+}
+
+// matchAny reports whether host matches one of the patterns.
+func matchAny(patterns []string, host string) bool {
+	if host == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if base, ok := strings.CutPrefix(p, "*."); ok {
+			if host == base || strings.HasSuffix(host, "."+base) {
+				return true
+			}
+			continue
+		}
+		if host == p {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePatterns lower cases the patterns and drops empty ones.
+func normalizePatterns(patterns []string) []string {
+	normalized := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		p = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(p)), ".")
+		if p != "" {
+			normalized = append(normalized, p)
+		}
+	}
+	return normalized
+}
+
+// readPatterns reads one pattern per line, "#" starts a comment.
+func readPatterns(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// FileDomainList is a DomainList loaded from a blocklist file and an allowlist file.
+// Either path may be empty.
+type FileDomainList struct {
+	*DomainList
+	blockFile string
+	allowFile string
+	modTime   time.Time
+}
+
+// NewFileDomainList loads the domain list from the given files.
+func NewFileDomainList(blockFile, allowFile string, allowlistOnly bool) (*FileDomainList, error) {
+	l := &FileDomainList{
+		DomainList: NewDomainList(nil, nil, allowlistOnly),
+		blockFile:  blockFile,
+		allowFile:  allowFile,
+	}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload reads both files again and replaces the lists.
+// On error the previous lists are kept.
+func (l *FileDomainList) Reload() error {
+	block, err := readPatternFile(l.blockFile)
+	if err != nil {
+		return err
+	}
+	allow, err := readPatternFile(l.allowFile)
+	if err != nil {
+		return err
+	}
+	l.Set(block, allow)
+	l.modTime = l.latestModTime()
+	log.Info().Int("blocked", len(block)).Int("allowed", len(allow)).Msg("Domain lists loaded")
+	return nil
+}
+
+// Watch reloads the lists whenever one of the files changes, until ctx is done.
+func (l *FileDomainList) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if l.latestModTime().After(l.modTime) {
+				if err := l.Reload(); err != nil {
+					log.Warn().Err(err).Msg("Failed to reload domain lists")
+				}
+			}
+		}
+	}
+}
+
+// latestModTime returns the newest modification time of the two files.
+func (l *FileDomainList) latestModTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{l.blockFile, l.allowFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// readPatternFile reads the patterns of path, an empty path has no patterns.
+func readPatternFile(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readPatterns(f)
+}
@@ -0,0 +1,97 @@
+package urlcheck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDomainList(t *testing.T) {
+	l := NewDomainList([]string{"*.evil.com", "phish.example.org"}, []string{"good.evil.com"}, false)
+
+	tests := []struct {
+		url         string
+		wantAllowed bool
+		wantTrusted bool
+	}{
+		{url: "https://evil.com/x", wantAllowed: false},
+		{url: "https://a.b.EVIL.com/x", wantAllowed: false},
+		{url: "https://notevil.com/x", wantAllowed: true},
+		{url: "https://phish.example.org/login", wantAllowed: false},
+		{url: "https://www.example.org/", wantAllowed: true},
+		{url: "https://good.evil.com/", wantAllowed: true, wantTrusted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			v, err := l.Check(context.Background(), tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Allowed != tt.wantAllowed || v.Trusted != tt.wantTrusted {
+				t.Errorf("got %+v, want allowed=%v trusted=%v", v, tt.wantAllowed, tt.wantTrusted)
+			}
+		})
+	}
+
+	only := NewDomainList(nil, []string{"*.example.com"}, true)
+	if v, _ := only.Check(context.Background(), "https://other.com/"); v.Allowed {
+		t.Error("expected host outside the allowlist to be rejected")
+	}
+}
+
+// rejectAll is a reputation provider that rejects every URL.
+type rejectAll struct{}
+
+func (rejectAll) Check(context.Context, string) (Verdict, error) {
+	return Verdict{Allowed: false, Reason: "rejected"}, nil
+}
+
+func TestChain(t *testing.T) {
+	chain := Chain{NewDomainList(nil, []string{"trusted.com"}, false), rejectAll{}}
+
+	if v, _ := chain.Check(context.Background(), "https://trusted.com/"); !v.Allowed {
+		t.Error("expected trusted host to skip the remaining checkers")
+	}
+	if v, _ := chain.Check(context.Background(), "https://other.com/"); v.Allowed {
+		t.Error("expected the provider to reject the URL")
+	}
+}
+
+func TestFileDomainListReload(t *testing.T) {
+	dir := t.TempDir()
+	blockFile := filepath.Join(dir, "blocklist.txt")
+	if err := os.WriteFile(blockFile, []byte("# comment\nfirst.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewFileDomainList(blockFile, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Blocked("https://first.com/") || l.Blocked("https://second.com/") {
+		t.Fatal("unexpected initial blocklist")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Watch(ctx, 10*time.Millisecond)
+
+	later := time.Now().Add(time.Second)
+	if err := os.WriteFile(blockFile, []byte("second.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(blockFile, later, later)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !l.Blocked("https://second.com/") {
+		if time.Now().After(deadline) {
+			t.Fatal("blocklist was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if l.Blocked("https://first.com/") {
+		t.Error("expected first.com to be removed after reload")
+	}
+}
@@ -0,0 +1,53 @@
+// Package urlcheck decides whether a destination URL may be shortened.
+//
+// Reputation providers implement URLChecker and are combined with Chain.
+// DomainList is the local implementation, it matches hosts against a blocklist
+// and an allowlist which can be reloaded from files at runtime.
+package urlcheck
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// Verdict is the result of a URL check.
+type Verdict struct {
+	// Allowed is false when the URL must not be shortened or followed.
+	Allowed bool
+	// Trusted URLs are allowed without asking the remaining checkers.
+	Trusted bool
+	// Reason explains a rejection.
+	Reason string
+}
+
+// URLChecker checks the reputation of a destination URL.
+type URLChecker interface {
+	Check(ctx context.Context, rawURL string) (Verdict, error)
+}
+
+// Chain asks each checker in order.
+// The first rejection or trusted verdict wins, otherwise the URL is allowed.
+type Chain []URLChecker
+
+func (ch Chain) Check(ctx context.Context, rawURL string) (Verdict, error) {
+	for _, checker := range ch {
+		verdict, err := checker.Check(ctx, rawURL)
+		if err != nil {
+			return Verdict{}, err
+		}
+		if !verdict.Allowed || verdict.Trusted {
+			return verdict, nil
+		}
+	}
+	return Verdict{Allowed: true}, nil
+}
+
+// hostOf returns the lower cased host name of rawURL, without port.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
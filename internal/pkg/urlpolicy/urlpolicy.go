@@ -0,0 +1,158 @@
+// Package urlpolicy validates and normalizes the destination URLs of short links.
+//
+// Every rejection is an *Error carrying a stable code, so API clients can tell
+// the reasons apart.
+package urlpolicy
+
+import (
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/idna"
+)
+
+const (
+	defaultMaxLength = 2048 // 默认目标地址最大长度
+)
+
+var defaultSchemes = []string{"http", "https"}
+
+// Error is a destination URL rejection.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrEmpty            = &Error{Code: "empty_url", Message: "destination url is empty"}
+	ErrInvalid          = &Error{Code: "invalid_url", Message: "destination url can not be parsed"}
+	ErrRelative         = &Error{Code: "relative_url", Message: "destination url must be absolute"}
+	ErrSchemeNotAllowed = &Error{Code: "scheme_not_allowed", Message: "destination url scheme is not allowed"}
+	ErrInvalidHost      = &Error{Code: "invalid_host", Message: "destination url host is invalid"}
+	ErrTooLong          = &Error{Code: "url_too_long", Message: "destination url is too long"}
+	ErrSelfReference    = &Error{Code: "self_referencing_url", Message: "destination url points back at the shortener"}
+)
+
+// Policy is the set of rules a destination URL must follow.
+type Policy struct {
+	// AllowedSchemes lists the accepted schemes in lower case.
+	AllowedSchemes []string
+	// MaxLength is the maximum length of the normalized URL.
+	MaxLength int
+	// SelfHosts are the shortener's own hosts, links to them would loop.
+	SelfHosts []string
+}
+
+// FromConfig builds the policy from the url_policy section of the config.
+//
+//	url_policy:
+//	  allowed_schemes: ["http", "https"]
+//	  max_length: 2048
+//	  self_hosts: ["s.example.com"]
+//
+// redirect.host is always treated as a self host.
+func FromConfig() Policy {
+	p := Policy{
+		AllowedSchemes: viper.GetStringSlice("url_policy.allowed_schemes"),
+		MaxLength:      viper.GetInt("url_policy.max_length"),
+		SelfHosts:      viper.GetStringSlice("url_policy.self_hosts"),
+	}
+	if len(p.AllowedSchemes) == 0 {
+		p.AllowedSchemes = defaultSchemes
+	}
+	if p.MaxLength <= 0 {
+		p.MaxLength = defaultMaxLength
+	}
+	if host := viper.GetString("redirect.host"); host != "" {
+		p.SelfHosts = append(p.SelfHosts, host)
+	}
+	return p
+}
+
+// Normalize validates raw and returns its normalized form.
+//
+// The scheme and host are lower cased, default ports are dropped and
+// internationalized host names are converted to punycode.
+func (p Policy) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ErrEmpty
+	}
+	if len(raw) > p.MaxLength {
+		return "", ErrTooLong
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	if !u.IsAbs() {
+		return "", ErrRelative
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if !slices.Contains(p.AllowedSchemes, u.Scheme) {
+		return "", ErrSchemeNotAllowed
+	}
+
+	if u.Scheme == "http" || u.Scheme == "https" {
+		if u.Host == "" {
+			return "", ErrRelative
+		}
+		host, err := normalizeHost(u.Scheme, u.Host)
+		if err != nil {
+			return "", err
+		}
+		u.Host = host
+
+		hostname := u.Hostname()
+		for _, self := range p.SelfHosts {
+			if strings.EqualFold(hostname, self) {
+				return "", ErrSelfReference
+			}
+		}
+	}
+
+	normalized := u.String()
+	if len(normalized) > p.MaxLength {
+		return "", ErrTooLong
+	}
+	return normalized, nil
+}
+
+// normalizeHost lower cases host, drops the default port of scheme and
+// converts an internationalized name to punycode.
+func normalizeHost(scheme, host string) (string, error) {
+	hostname, port := host, ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		hostname, port = h, p
+	}
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	if ip := net.ParseIP(strings.Trim(hostname, "[]")); ip != nil {
+		hostname = ip.String()
+	} else {
+		ascii, err := idna.Lookup.ToASCII(hostname)
+		if err != nil || ascii == "" {
+			return "", ErrInvalidHost
+		}
+		hostname = ascii
+	}
+
+	if port != "" || strings.Contains(hostname, ":") {
+		if port == "" {
+			return "[" + hostname + "]", nil
+		}
+		return net.JoinHostPort(hostname, port), nil
+	}
+	return hostname, nil
+}
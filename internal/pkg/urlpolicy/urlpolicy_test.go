@@ -0,0 +1,52 @@
+package urlpolicy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	p := Policy{
+		AllowedSchemes: []string{"http", "https"},
+		MaxLength:      64,
+		SelfHosts:      []string{"s.example.com"},
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr *Error
+	}{
+		{name: "plain", raw: "https://www.example.com/a?b=c", want: "https://www.example.com/a?b=c"},
+		{name: "host case and scheme", raw: "HTTPS://WWW.Example.COM/Path", want: "https://www.example.com/Path"},
+		{name: "default port", raw: "http://example.com:80/", want: "http://example.com/"},
+		{name: "custom port", raw: "https://example.com:8443/", want: "https://example.com:8443/"},
+		{name: "idn", raw: "https://bücher.example/", want: "https://xn--bcher-kva.example/"},
+		{name: "ipv6", raw: "http://[::1]:80/", want: "http://[::1]/"},
+		{name: "empty", raw: "  ", wantErr: ErrEmpty},
+		{name: "javascript", raw: "javascript:alert(1)", wantErr: ErrSchemeNotAllowed},
+		{name: "relative", raw: "/relative/path", wantErr: ErrRelative},
+		{name: "no host", raw: "https:///path", wantErr: ErrRelative},
+		{name: "too long", raw: "https://example.com/" + string(make([]byte, 64)), wantErr: ErrTooLong},
+		{name: "self reference", raw: "https://S.Example.com/abc123", wantErr: ErrSelfReference},
+		{name: "invalid", raw: "http://[::1", wantErr: ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Normalize(tt.raw)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
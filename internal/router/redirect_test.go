@@ -0,0 +1,112 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveRoute sends a request for target to r, with the given Host when set.
+func serveRoute(r *gin.Engine, method, host, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if host != "" {
+		req.Host = host
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRedirectRoutes(t *testing.T) {
+	viper.SetConfigName("config")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("../../")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	database.InitMysqlDB()
+	defer database.CloseMysqlDB()
+
+	gin.SetMode(gin.TestMode)
+
+	// one code taken by a public link, a user link and a bio page, one taken by a
+	// user link and a bio page, and one taken by a bio page only
+	base := "r" + strconv.FormatInt(time.Now().UnixNano()%1e8, 36)
+	shared, userAndPage, pageOnly := base+"a", base+"b", base+"c"
+	expireAt := time.Now().Add(time.Hour)
+	public := database.LinkOptions{Visibility: "public"}
+	userID := "router-test-" + base
+	assert.NoError(t, database.CreateUser(database.User{UserID: userID, Email: userID + "@example.com", PasswordHash: "x"}))
+
+	assert.NoError(t, database.CreatePublicShortURL(database.PublicShortURL{ShortCode: shared, OriginalURL: "https://public.example.com", ExpiresAt: expireAt}))
+	defer database.DeletePublicShortURLByShortCode(shared)
+	assert.NoError(t, database.CreateUserShortURLs([]database.UserShortURL{
+		{UserID: userID, ShortCode: shared, OriginalURL: "https://user.example.com/a", ExpireAt: expireAt, LinkOptions: public},
+		{UserID: userID, ShortCode: userAndPage, OriginalURL: "https://user.example.com/b", ExpireAt: expireAt, LinkOptions: public},
+	}, "127.0.0.1"))
+	for _, code := range []string{shared, userAndPage, pageOnly} {
+		page := database.BioPage{ShortCode: code, UserID: userID, Title: "Page " + code}
+		assert.NoError(t, database.CreateBioPage(&page))
+		defer database.DeleteBioPage(page)
+	}
+
+	r := newRouter(nil)
+	registerRedirectRoutes(r)
+
+	t.Run("Public links win over user links", func(t *testing.T) {
+		w := serveRoute(r, http.MethodGet, "", "/"+shared)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://public.example.com", w.Header().Get("Location"))
+	})
+
+	t.Run("User links win over bio pages", func(t *testing.T) {
+		w := serveRoute(r, http.MethodGet, "", "/"+userAndPage)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://user.example.com/b", w.Header().Get("Location"))
+	})
+
+	t.Run("Bio pages are rendered", func(t *testing.T) {
+		w := serveRoute(r, http.MethodGet, "", "/"+pageOnly)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Page "+pageOnly)
+	})
+
+	t.Run("HEAD is answered but not counted", func(t *testing.T) {
+		before, err := database.FindPublicShortURL(shared)
+		assert.NoError(t, err)
+		w := serveRoute(r, http.MethodHead, "", "/"+shared)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://public.example.com", w.Header().Get("Location"))
+		after, err := database.FindPublicShortURL(shared)
+		assert.NoError(t, err)
+		assert.Equal(t, before.AccessCount, after.AccessCount)
+	})
+
+	t.Run("Unknown codes are not found", func(t *testing.T) {
+		w := serveRoute(r, http.MethodGet, "", "/"+base+"z")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Only the redirect host is served", func(t *testing.T) {
+		viper.Set("redirect.host", "s.example.com")
+		defer viper.Set("redirect.host", "")
+		hostRouter := newRouter(nil)
+		registerRedirectRoutes(hostRouter)
+
+		w := serveRoute(hostRouter, http.MethodGet, "s.example.com", "/"+shared)
+		assert.Equal(t, http.StatusFound, w.Code)
+		w = serveRoute(hostRouter, http.MethodGet, "other.example.com", "/"+shared)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		// API routes are not filtered by host
+		w = serveRoute(hostRouter, http.MethodGet, "other.example.com", "/health")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
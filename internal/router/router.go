@@ -5,17 +5,48 @@ import (
 	"url-shortener/internal/handler"
 	"url-shortener/internal/pkg/controller"
 	"url-shortener/internal/pkg/middleware"
+	"url-shortener/internal/service"
 	rbacv1 "url-shortener/pkg/apis/rbac/v1"
 
 	"github.com/didip/tollbooth/v7"
 	"github.com/didip/tollbooth_gin"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 
 	"github.com/gin-gonic/gin"
 )
 
 func Router(rbacSys *rbacv1.RBACSystem) {
-	r := gin.Default()
+	// namespace visibility of links is decided by the RBAC system
+	service.SetAuthorizer(rbacSys)
+
+	r := newRouter(rbacSys)
+
+	// Short codes are also served at the domain root, either on the API server
+	// or on a dedicated listener (redirect.listen).
+	if listen := viper.GetString("redirect.listen"); listen != "" {
+		redirectRouter := newEngine()
+		registerRedirectRoutes(redirectRouter)
+		go func() {
+			if err := redirectRouter.Run(listen); err != nil {
+				log.Fatal().Err(err).Msg("failed to start redirect server")
+			}
+		}()
+		log.Info().Str("listen", listen).Msg("redirect server started")
+	} else {
+		registerRedirectRoutes(r)
+	}
+
+	if err := r.Run(":8080"); err != nil {
+		log.Fatal().Err(err).Msg("failed to start server")
+	}
+	log.Info().Msg("server started on 8080")
+}
+
+// newRouter returns an engine serving the API routes, without the redirect routes
+// at the domain root.
+func newRouter(rbacSys *rbacv1.RBACSystem) *gin.Engine {
+	r := newEngine()
 	r.GET("/health", func(c *gin.Context) {
 		log.Info().Msg("health check")
 		c.JSON(200, gin.H{
@@ -29,9 +60,13 @@ func Router(rbacSys *rbacv1.RBACSystem) {
 		limiter := tollbooth.NewLimiter(5, nil) // 每秒5次请求
 		public.POST("/login", tollbooth_gin.LimitHandler(limiter), controller.Login)
 		public.POST("/short/new", handler.HandleCreatePublicShortURL)
-		public.GET("/:code", handler.HandleRedirectPublicCode)
+		public.GET("/challenge", handler.HandleIssueChallenge)
+		public.GET("/:code", middleware.OptionalJwtAuth(), handler.HandleRedirectPublicCode)
+		public.HEAD("/:code", middleware.OptionalJwtAuth(), handler.HandleRedirectPublicCode)
 		public.GET("/shortcodes", handler.HandleGetAllPublicShortURLs)
 		public.DELETE("/short/:code", handler.HandleDeletePublicShortURL)
+		public.GET("/short/:code/stats", handler.HandleGetPublicShortURLStats)
+		public.GET("/:code/qr", handler.HandlePublicQRCode)
 	}
 
 	authGroup := r.Group("/v1/auth")
@@ -40,8 +75,34 @@ func Router(rbacSys *rbacv1.RBACSystem) {
 	{
 		authGroup.POST("/refresh", handler.HandleRefreshToken)
 		authGroup.POST("/short/new", handler.HandleCreateUserShortURL)
+		authGroup.POST("/short/bulk", handler.HandleBulkCreateUserShortURLs)
+		authGroup.GET("/short/export", handler.HandleExportUserShortURLs)
+		authGroup.POST("/short/import", handler.HandleImportUserShortURLs)
+		authGroup.POST("/admin/import", handler.HandleAdminImportShortURLs)
 		authGroup.POST("/:code", handler.HandleRedirectUserCode)
+		authGroup.HEAD("/:code", handler.HandleRedirectUserCode)
 		authGroup.GET("/shortcodes", handler.HandleGetUserShortURLs)
+		authGroup.GET("/short/:code/stats", handler.HandleGetUserShortURLStats)
+		authGroup.POST("/short/:code/metadata", handler.HandleRefreshUserShortURLMetadata)
+		authGroup.POST("/short/:code/signed", handler.HandleCreateSignedURL)
+		authGroup.GET("/short/:code/signed", handler.HandleListSignedURLs)
+		authGroup.DELETE("/short/:code/signed/:nonce", handler.HandleRevokeSignedURL)
+		authGroup.GET("/:code/qr", handler.HandleUserQRCode)
+		authGroup.POST("/campaigns", handler.HandleCreateCampaign)
+		authGroup.GET("/campaigns", handler.HandleListCampaigns)
+		authGroup.DELETE("/campaigns/:id", handler.HandleDeleteCampaign)
+		authGroup.POST("/campaigns/:id/links", handler.HandleAttachCampaignLinks)
+		authGroup.DELETE("/campaigns/:id/links/:code", handler.HandleDetachCampaignLink)
+		authGroup.GET("/campaigns/:id/stats", handler.HandleGetCampaignStats)
+		authGroup.POST("/pages", handler.HandleCreateBioPage)
+		authGroup.GET("/pages", handler.HandleListBioPages)
+		authGroup.PUT("/pages/:code", handler.HandleUpdateBioPage)
+		authGroup.DELETE("/pages/:code", handler.HandleDeleteBioPage)
+		authGroup.POST("/domains", handler.HandleCreateDomain)
+		authGroup.GET("/domains", handler.HandleListDomains)
+		authGroup.PUT("/domains/:id", handler.HandleUpdateDomain)
+		authGroup.POST("/domains/:id/verify", handler.HandleVerifyDomain)
+		authGroup.DELETE("/domains/:id", handler.HandleDeleteDomain)
 	}
 
 	rbacGroup := r.Group("/rbac/v1")
@@ -53,9 +114,30 @@ func Router(rbacSys *rbacv1.RBACSystem) {
 		rbacGroup.GET("/rolebinding", rbacSys.HandleListRoleBindings)
 		rbacGroup.POST("/rolebinding", rbacSys.HandleCreateRoleBinding)
 	}
+	return r
+}
 
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal().Err(err).Msg("failed to start server")
+// newEngine returns a gin engine which only trusts the client IP forwarded by the
+// proxies of trusted_proxies in the config, none when the list is empty.
+func newEngine() *gin.Engine {
+	r := gin.Default()
+	if err := r.SetTrustedProxies(viper.GetStringSlice("trusted_proxies")); err != nil {
+		log.Fatal().Err(err).Msg("invalid trusted_proxies")
 	}
-	log.Info().Msg("server started on 8080")
+	return r
+}
+
+// registerRedirectRoutes serves GET /:code at the root of r.
+//
+// Static API routes (/health, /v1, /rbac/v1) always take precedence over the
+// code parameter. Custom aliases can not take their segments, nor the static
+// segments of /v1/public and /v1/auth, see service.ReservedAlias; new top-level
+// paths must be added there, TestReservedAliases fails otherwise.
+// When redirect.host is set, only requests for that host and for verified custom
+// domains are redirected. Custom domains also serve their root URL at "/".
+func registerRedirectRoutes(r *gin.Engine) {
+	resolveHost := service.ResolveHost(viper.GetString("redirect.host"))
+	r.GET("/", resolveHost, handler.HandleRedirectRoot)
+	r.GET("/:code", resolveHost, middleware.OptionalJwtAuth(), handler.HandleRedirectCode)
+	r.HEAD("/:code", resolveHost, middleware.OptionalJwtAuth(), handler.HandleRedirectCode)
 }
@@ -0,0 +1,92 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url-shortener/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// clientIP returns the client IP seen by an engine of newEngine for a request
+// from remoteAddr carrying the X-Forwarded-For header forwarded.
+func clientIP(t *testing.T, remoteAddr, forwarded string) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := newEngine()
+	var ip string
+	r.GET("/", func(c *gin.Context) { ip = c.ClientIP() })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-Forwarded-For", forwarded)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	return ip
+}
+
+func TestTrustedProxies(t *testing.T) {
+	defer viper.Set("trusted_proxies", nil)
+
+	// without trusted proxies a spoofed header is ignored
+	viper.Set("trusted_proxies", nil)
+	if ip := clientIP(t, "203.0.113.7:40000", "198.51.100.1"); ip != "203.0.113.7" {
+		t.Errorf("ClientIP() without trusted proxies = %q", ip)
+	}
+
+	viper.Set("trusted_proxies", []string{"10.0.0.0/8"})
+	if ip := clientIP(t, "10.0.0.2:40000", "198.51.100.1"); ip != "198.51.100.1" {
+		t.Errorf("ClientIP() behind a trusted proxy = %q", ip)
+	}
+	if ip := clientIP(t, "203.0.113.7:40000", "198.51.100.1"); ip != "203.0.113.7" {
+		t.Errorf("ClientIP() from an untrusted peer = %q", ip)
+	}
+}
+
+func TestReservedAliases(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := newRouter(nil)
+	registerRedirectRoutes(r)
+
+	// the prefixes serving a short code right after them
+	codePrefixes := map[string]bool{}
+	for _, route := range r.Routes() {
+		if prefix, ok := strings.CutSuffix(route.Path, "/:code"); ok {
+			codePrefixes[prefix] = true
+		}
+	}
+	if !codePrefixes[""] || !codePrefixes["/v1/public"] || !codePrefixes["/v1/auth"] {
+		t.Fatalf("short code routes = %v", codePrefixes)
+	}
+
+	// every static segment next to a short code must be reserved
+	for _, route := range r.Routes() {
+		for prefix := range codePrefixes {
+			rest, ok := strings.CutPrefix(route.Path, prefix+"/")
+			if !ok {
+				continue
+			}
+			segment, _, _ := strings.Cut(rest, "/")
+			if segment == "" || strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+				continue
+			}
+			if !service.ReservedAlias(segment) {
+				t.Errorf("%s %s: segment %q next to %s/:code is not a reserved alias", route.Method, route.Path, segment, prefix)
+			}
+		}
+	}
+}
+
+func TestStaticRoutesWinOverCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := newRouter(nil)
+	registerRedirectRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"ok"`) {
+		t.Fatalf("GET /health = %d %s", w.Code, w.Body.String())
+	}
+}
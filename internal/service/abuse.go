@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"url-shortener/internal/pkg/cache"
+	"url-shortener/internal/pkg/captcha"
+	"url-shortener/internal/pkg/pow"
+	"url-shortener/internal/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Request headers carrying the anti-abuse proofs of an anonymous create request.
+const (
+	powChallengeHeader = "X-Pow-Challenge"
+	powNonceHeader     = "X-Pow-Nonce"
+	captchaTokenHeader = "X-Captcha-Token"
+)
+
+const (
+	defaultIPv4Prefix    = 24
+	defaultIPv6Prefix    = 64
+	defaultPowDifficulty = 20
+	defaultPowTTL        = 2 * time.Minute
+)
+
+// createLimitTimeout bounds the round trip to a shared create rate limit or spent
+// challenge set.
+const createLimitTimeout = 200 * time.Millisecond
+
+// anonymousGuard protects the creation of public short URLs by anonymous clients.
+// Every check is optional, a nil limiter, issuer or verifier is skipped.
+type anonymousGuard struct {
+	ipLimiter     ratelimit.Limiter // 每个 IP 的创建频率
+	subnetLimiter ratelimit.Limiter // 每个子网的创建频率
+	ipv4Prefix    int
+	ipv6Prefix    int
+	pow           *pow.Issuer
+	captcha       captcha.Verifier
+}
+
+var (
+	anonymousGuardOnce sync.Once
+	guard              *anonymousGuard
+	captchaVerifier    captcha.Verifier
+)
+
+// SetCaptchaVerifier plugs a captcha provider into anonymous creation, instead
+// of the siteverify endpoint of the config. It must be called at startup.
+func SetCaptchaVerifier(v captcha.Verifier) {
+	captchaVerifier = v
+}
+
+// getAnonymousGuard returns the guard of the anonymous section in the config.
+func getAnonymousGuard() *anonymousGuard {
+	anonymousGuardOnce.Do(func() {
+		if guard == nil {
+			guard = newAnonymousGuard()
+		}
+	})
+	return guard
+}
+
+// newAnonymousGuard builds the guard from the config. The rate limits and the
+// spent challenges are kept in process, or in Redis when anonymous.shared is set
+// so all replicas share them.
+func newAnonymousGuard() *anonymousGuard {
+	g := &anonymousGuard{
+		ipv4Prefix: viper.GetInt("anonymous.ipv4_prefix"),
+		ipv6Prefix: viper.GetInt("anonymous.ipv6_prefix"),
+		captcha:    captchaVerifier,
+	}
+	if g.ipv4Prefix <= 0 || g.ipv4Prefix > 32 {
+		g.ipv4Prefix = defaultIPv4Prefix
+	}
+	if g.ipv6Prefix <= 0 || g.ipv6Prefix > 128 {
+		g.ipv6Prefix = defaultIPv6Prefix
+	}
+
+	var client *redis.Client
+	redisClient := func() *redis.Client {
+		if client == nil {
+			client = cache.NewRedisClient()
+		}
+		return client
+	}
+	newLimiter := func(perMinute int, prefix string) ratelimit.Limiter {
+		if perMinute <= 0 {
+			return nil
+		}
+		if viper.GetBool("anonymous.shared") {
+			return ratelimit.NewRedisLimiter(redisClient(), prefix, float64(perMinute)/60, perMinute)
+		}
+		return ratelimit.NewMemoryLimiter(float64(perMinute)/60, perMinute)
+	}
+	g.ipLimiter = newLimiter(viper.GetInt("anonymous.ip_per_minute"), "ratelimit:create:ip:")
+	g.subnetLimiter = newLimiter(viper.GetInt("anonymous.subnet_per_minute"), "ratelimit:create:subnet:")
+
+	if viper.GetBool("anonymous.pow.enabled") {
+		key := []byte(viper.GetString("anonymous.pow.key"))
+		if len(key) == 0 {
+			// challenges issued by one replica can not be checked by the others
+			log.Warn().Msg("anonymous.pow.key is not set, using a random key")
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				log.Fatal().Err(err).Msg("Failed to create proof-of-work key")
+			}
+		}
+		difficulty := defaultPowDifficulty
+		if viper.IsSet("anonymous.pow.difficulty") {
+			difficulty = viper.GetInt("anonymous.pow.difficulty")
+		}
+		ttl := viper.GetDuration("anonymous.pow.ttl")
+		if ttl <= 0 {
+			ttl = defaultPowTTL
+		}
+		var spent pow.SpentSet
+		if viper.GetBool("anonymous.shared") {
+			spent = pow.NewRedisSpentSet(redisClient(), "pow:spent:")
+		}
+		g.pow = pow.NewIssuer(key, difficulty, ttl, spent)
+	}
+
+	if g.captcha == nil {
+		if verifyURL := viper.GetString("anonymous.captcha.verify_url"); verifyURL != "" {
+			g.captcha = captcha.SiteVerify{
+				URL:    verifyURL,
+				Secret: viper.GetString("anonymous.captcha.secret"),
+				Client: &http.Client{Timeout: 5 * time.Second},
+			}
+		}
+	}
+	return g
+}
+
+// clientSubnet returns the network of ip that shares one rate limit, e.g. its /24
+// for IPv4 and its /64 for IPv6. Addresses that can not be parsed are their own subnet.
+func clientSubnet(ip string, ipv4Prefix, ipv6Prefix int) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	bits := ipv6Prefix
+	if addr.Is4() {
+		bits = ipv4Prefix
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
+
+// check runs the rate limits, the proof-of-work and the captcha of an anonymous
+// create request, cheapest first.
+func (g *anonymousGuard) check(c *gin.Context) error {
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+
+	if err := allowCreate(ctx, g.ipLimiter, ip); err != nil {
+		return err
+	}
+	if err := allowCreate(ctx, g.subnetLimiter, clientSubnet(ip, g.ipv4Prefix, g.ipv6Prefix)); err != nil {
+		return err
+	}
+
+	if g.pow != nil {
+		challenge := c.GetHeader(powChallengeHeader)
+		if challenge == "" {
+			return &createError{Status: http.StatusForbidden, Code: "challenge_required", Message: "a solved proof-of-work challenge is required, see GET /v1/public/challenge"}
+		}
+		if err := verifyChallenge(ctx, g.pow, challenge, c.GetHeader(powNonceHeader)); err != nil {
+			return err
+		}
+	}
+
+	if g.captcha != nil {
+		ok, err := g.captcha.Verify(ctx, c.GetHeader(captchaTokenHeader), ip)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to verify captcha")
+			return &createError{Status: http.StatusServiceUnavailable, Code: "captcha_unavailable", Message: "captcha verification is unavailable"}
+		}
+		if !ok {
+			return &createError{Status: http.StatusForbidden, Code: "captcha_invalid", Message: "captcha verification failed"}
+		}
+	}
+	return nil
+}
+
+// verifyChallenge checks the solved challenge of a create request and spends it.
+func verifyChallenge(ctx context.Context, issuer *pow.Issuer, challenge, nonce string) error {
+	ctx, cancel := context.WithTimeout(ctx, createLimitTimeout)
+	defer cancel()
+	err := issuer.Verify(ctx, challenge, nonce, time.Now())
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pow.ErrInvalid), errors.Is(err, pow.ErrExpired), errors.Is(err, pow.ErrUnsolved), errors.Is(err, pow.ErrSpent):
+		return &createError{Status: http.StatusForbidden, Code: "challenge_invalid", Message: err.Error()}
+	default:
+		log.Warn().Err(err).Msg("Failed to spend proof-of-work challenge")
+		return &createError{Status: http.StatusServiceUnavailable, Code: "challenge_unavailable", Message: "challenge verification is unavailable"}
+	}
+}
+
+// allowCreate charges one create against key. Creation goes on when the limiter
+// can not be reached.
+func allowCreate(ctx context.Context, limiter ratelimit.Limiter, key string) error {
+	if limiter == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, createLimitTimeout)
+	defer cancel()
+	allowed, err := limiter.AllowN(ctx, key, 1)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to check create rate limit")
+		return nil
+	}
+	if !allowed {
+		return &createError{Status: http.StatusTooManyRequests, Code: "rate_limited", Message: "too many short URLs created, try again later"}
+	}
+	return nil
+}
+
+// IssueChallenge hands out a proof-of-work challenge for anonymous creation.
+func IssueChallenge(c *gin.Context) {
+	issuer := getAnonymousGuard().pow
+	if issuer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proof of work is not enabled"})
+		return
+	}
+	challenge, err := issuer.Issue(time.Now())
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to issue proof-of-work challenge")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, challenge)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/captcha"
+	"url-shortener/internal/pkg/pow"
+	"url-shortener/internal/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createContext returns a test context for an anonymous create request from ip.
+func createContext(ip string, header http.Header) *gin.Context {
+	c := testContext(httptest.NewRecorder(), http.MethodPost, "/v1/public/short/new", header)
+	c.Request.RemoteAddr = ip + ":40000"
+	return c
+}
+
+// createErrorCode returns the code of a createError, or "" for nil.
+func createErrorCode(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var createErr *createError
+	if !errors.As(err, &createErr) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return createErr.Code
+}
+
+func TestClientSubnet(t *testing.T) {
+	tests := []struct {
+		ip, want string
+	}{
+		{ip: "203.0.113.7", want: "203.0.113.0/24"},
+		{ip: "::ffff:203.0.113.7", want: "203.0.113.0/24"},
+		{ip: "2001:db8:1:2:3:4:5:6", want: "2001:db8:1:2::/64"},
+		{ip: "unknown", want: "unknown"},
+	}
+	for _, tt := range tests {
+		if got := clientSubnet(tt.ip, 24, 64); got != tt.want {
+			t.Errorf("clientSubnet(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestAnonymousGuardRateLimits(t *testing.T) {
+	g := &anonymousGuard{
+		ipLimiter:     ratelimit.NewMemoryLimiter(0.001, 2),
+		subnetLimiter: ratelimit.NewMemoryLimiter(0.001, 3),
+		ipv4Prefix:    24,
+		ipv6Prefix:    64,
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := g.check(createContext("203.0.113.7", nil)); err != nil {
+			t.Fatalf("create %d = %v", i, err)
+		}
+	}
+	if code := createErrorCode(t, g.check(createContext("203.0.113.7", nil))); code != "rate_limited" {
+		t.Fatalf("create over the IP limit = %q", code)
+	}
+	// the neighbour has its own IP limit but shares the subnet limit
+	if err := g.check(createContext("203.0.113.8", nil)); err != nil {
+		t.Fatalf("create from the same subnet = %v", err)
+	}
+	if code := createErrorCode(t, g.check(createContext("203.0.113.9", nil))); code != "rate_limited" {
+		t.Fatalf("create over the subnet limit = %q", code)
+	}
+	if err := g.check(createContext("198.51.100.1", nil)); err != nil {
+		t.Fatalf("create from another subnet = %v", err)
+	}
+}
+
+func TestAnonymousGuardProofs(t *testing.T) {
+	g := &anonymousGuard{
+		pow:     pow.NewIssuer([]byte("secret"), 8, time.Minute, nil),
+		captcha: captcha.Fake{Token: "pass"},
+	}
+	solved := func() http.Header {
+		challenge, err := g.pow.Issue(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return http.Header{
+			powChallengeHeader: {challenge.Challenge},
+			powNonceHeader:     {pow.Solve(challenge.Challenge, challenge.Difficulty)},
+			captchaTokenHeader: {"pass"},
+		}
+	}
+
+	if code := createErrorCode(t, g.check(createContext("203.0.113.7", nil))); code != "challenge_required" {
+		t.Fatalf("create without challenge = %q", code)
+	}
+
+	header := solved()
+	if err := g.check(createContext("203.0.113.7", header)); err != nil {
+		t.Fatalf("create with proofs = %v", err)
+	}
+	if code := createErrorCode(t, g.check(createContext("203.0.113.7", header))); code != "challenge_invalid" {
+		t.Fatalf("create with a used challenge = %q", code)
+	}
+
+	header = solved()
+	header.Set(captchaTokenHeader, "fail")
+	if code := createErrorCode(t, g.check(createContext("203.0.113.7", header))); code != "captcha_invalid" {
+		t.Fatalf("create with a wrong captcha = %q", code)
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"url-shortener/internal/pkg/database"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	maxTags      = 10 // 每个短链最多的标签数
+	maxTagLength = 32 // 单个标签最大长度
+)
+
+// aliasPattern matches the custom short codes, the column holds at most 10 characters.
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,10}$`)
+
+// reservedAliases are the path segments a short code must not take: the first
+// segments of the API, which GET /:code must not shadow, and the static segments
+// next to the other :code routes, such as /v1/public/:code and /v1/auth/:code.
+// Add every new route segment here, the router tests check it.
+var reservedAliases = map[string]bool{
+	"health":     true,
+	"v1":         true,
+	"rbac":       true,
+	"login":      true,
+	"public":     true,
+	"auth":       true,
+	"short":      true,
+	"new":        true,
+	"register":   true,
+	"challenge":  true,
+	"shortcodes": true,
+	"refresh":    true,
+	"admin":      true,
+	"campaigns":  true,
+	"pages":      true,
+	"domains":    true,
+}
+
+// ReservedAlias reports whether segment is a path segment of the API, which no
+// short code may take.
+func ReservedAlias(segment string) bool {
+	return reservedAliases[strings.ToLower(segment)]
+}
+
+// createError is a create request rejection with a machine readable code.
+type createError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"error"`
+}
+
+func (e *createError) Error() string {
+	return e.Message
+}
+
+// validateAlias checks the format of a custom short code.
+func validateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return &createError{Status: http.StatusBadRequest, Code: "alias_invalid", Message: "alias must be 3-10 letters, digits, '-' or '_'"}
+	}
+	if ReservedAlias(alias) {
+		return &createError{Status: http.StatusBadRequest, Code: "alias_reserved", Message: "alias is reserved"}
+	}
+	return nil
+}
+
+// validateTags checks the tags of a create request, they are stored comma separated.
+func validateTags(tags []string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("at most %d tags are allowed", maxTags)
+	}
+	for _, tag := range tags {
+		if tag == "" || len(tag) > maxTagLength || strings.Contains(tag, ",") {
+			return fmt.Errorf("tags must be 1-%d characters without commas", maxTagLength)
+		}
+	}
+	return nil
+}
+
+// checkAliasesAvailable returns an alias_taken error if one of the aliases is already used.
+func checkAliasesAvailable(aliases []string) error {
+	inUse, err := database.ShortCodesInUse(aliases)
+	if err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		if inUse[alias] {
+			return &createError{Status: http.StatusConflict, Code: "alias_taken", Message: "alias is already taken"}
+		}
+	}
+	return nil
+}
+
+// checkQuota returns a quota_exceeded error if creating n more links would exceed
+// quota.max_links_per_user. Zero means unlimited.
+func checkQuota(userID string, n int) error {
+	limit := viper.GetInt64("quota.max_links_per_user")
+	if limit <= 0 {
+		return nil
+	}
+	count, err := database.CountUserShortURLs(userID)
+	if err != nil {
+		return errors.Join(errors.New("failed to count short URLs"), err)
+	}
+	if count+int64(n) > limit {
+		return &createError{Status: http.StatusForbidden, Code: "quota_exceeded", Message: fmt.Sprintf("link quota of %d exceeded", limit)}
+	}
+	return nil
+}
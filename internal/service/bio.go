@@ -0,0 +1,365 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/page"
+	"url-shortener/internal/pkg/urlpolicy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	maxBioLinks             = 50
+	maxBioTitleLength       = 128
+	maxBioLabelLength       = 128
+	maxBioDescriptionLength = 512
+	defaultBioTheme         = "light"
+)
+
+// bioThemes are the color schemes of bio pages.
+var bioThemes = map[string]template.CSS{
+	"light":  "--bg: #f5f5f5; --fg: #1f1f1f; --card: #ffffff; --card-fg: #1f1f1f; --accent: #2563eb;",
+	"dark":   "--bg: #111827; --fg: #f9fafb; --card: #1f2937; --card-fg: #f9fafb; --accent: #60a5fa;",
+	"ocean":  "--bg: linear-gradient(160deg, #0ea5e9, #1e3a8a); --fg: #ffffff; --card: rgba(255, 255, 255, 0.9); --card-fg: #0c4a6e; --accent: #0369a1;",
+	"sunset": "--bg: linear-gradient(160deg, #f97316, #db2777); --fg: #ffffff; --card: rgba(255, 255, 255, 0.92); --card-fg: #7c2d12; --accent: #be185d;",
+}
+
+// BioLink is one entry of a bio page.
+type BioLink struct {
+	ShortURL string `json:"short_url"`
+	Label    string `json:"label,omitempty"`
+}
+
+// BioPageInfo describes a bio page.
+type BioPageInfo struct {
+	ShortURL    string    `json:"short_url"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	Theme       string    `json:"theme"`
+	ViewCount   uint      `json:"view_count"`
+	Links       []BioLink `json:"links"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newBioPageInfo(p database.BioPage) BioPageInfo {
+	info := BioPageInfo{
+		ShortURL:    p.ShortCode,
+		Title:       p.Title,
+		Description: p.Description,
+		AvatarURL:   p.AvatarURL,
+		Theme:       bioTheme(p.Theme),
+		ViewCount:   p.ViewCount,
+		Links:       make([]BioLink, 0, len(p.Links)),
+		CreatedAt:   p.CreatedAt,
+	}
+	for _, l := range p.Links {
+		info.Links = append(info.Links, BioLink{ShortURL: l.ShortCode, Label: l.Label})
+	}
+	return info
+}
+
+// bioTheme returns theme, or the default theme when it is not set.
+func bioTheme(theme string) string {
+	if theme == "" {
+		return defaultBioTheme
+	}
+	return theme
+}
+
+type bioPageRequest struct {
+	// Alias is the short code of the page, generated when empty.
+	Alias       string    `json:"alias,omitempty"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	Theme       string    `json:"theme,omitempty"`
+	Links       []BioLink `json:"links"`
+}
+
+func invalidPage(format string, args ...any) error {
+	return &createError{Status: http.StatusBadRequest, Code: "invalid_page", Message: fmt.Sprintf(format, args...)}
+}
+
+// validate checks the request and normalizes the avatar URL.
+func (r *bioPageRequest) validate() error {
+	if r.Title == "" || utf8.RuneCountInString(r.Title) > maxBioTitleLength {
+		return invalidPage("title must be 1-%d characters", maxBioTitleLength)
+	}
+	if utf8.RuneCountInString(r.Description) > maxBioDescriptionLength {
+		return invalidPage("description must be at most %d characters", maxBioDescriptionLength)
+	}
+	if _, ok := bioThemes[bioTheme(r.Theme)]; !ok {
+		return invalidPage("theme must be one of light, dark, ocean, sunset")
+	}
+	if len(r.Links) > maxBioLinks {
+		return invalidPage("at most %d links are allowed", maxBioLinks)
+	}
+	for _, l := range r.Links {
+		if l.ShortURL == "" || utf8.RuneCountInString(l.Label) > maxBioLabelLength {
+			return invalidPage("links need a short_url and a label of at most %d characters", maxBioLabelLength)
+		}
+	}
+	if r.Alias != "" {
+		if err := validateAlias(r.Alias); err != nil {
+			return err
+		}
+	}
+	if r.AvatarURL != "" {
+		var err error
+		if r.AvatarURL, err = urlpolicy.FromConfig().Normalize(r.AvatarURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// links returns the entries of the page, which must all be short URLs of userID.
+func (r bioPageRequest) links(userID string) ([]database.BioPageLink, error) {
+	codes := make([]string, len(r.Links))
+	for i, l := range r.Links {
+		codes[i] = l.ShortURL
+	}
+	owned, err := database.GetUserShortURLsByCodes(userID, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]database.BioPageLink, len(r.Links))
+	for i, l := range r.Links {
+		if _, ok := owned[l.ShortURL]; !ok {
+			return nil, &createError{Status: http.StatusBadRequest, Code: "link_not_found", Message: "short URL " + l.ShortURL + " not found"}
+		}
+		links[i] = database.BioPageLink{Position: i, Label: l.Label, ShortCode: l.ShortURL}
+	}
+	return links, nil
+}
+
+// bindBioPageRequest reads and checks the page of a create or update request.
+func bindBioPageRequest(c *gin.Context, userID string) (bioPageRequest, []database.BioPageLink, bool) {
+	var req bioPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return req, nil, false
+	}
+	if err := req.validate(); err != nil {
+		respondInvalidRequest(c, err)
+		return req, nil, false
+	}
+	links, err := req.links(userID)
+	if err != nil {
+		respondCreateFailure(c, err)
+		return req, nil, false
+	}
+	return req, links, true
+}
+
+// CreateBioPage creates a link-in-bio page of the caller. Send JSON as follows:
+//
+//	{
+//	    "alias": "jane",
+//	    "title": "Jane Doe",
+//	    "description": "Photographer",
+//	    "avatar_url": "https://cdn.example.com/jane.png",
+//	    "theme": "dark",
+//	    "links": [{"short_url": "abc123", "label": "Portfolio"}]
+//	}
+//
+// The links must be short URLs of the caller, entries without a label show the
+// title of the link.
+func CreateBioPage(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	req, links, ok := bindBioPageRequest(c, userID)
+	if !ok {
+		return
+	}
+
+	shortCode := req.Alias
+	if shortCode != "" {
+		if err := checkAliasesAvailable([]string{shortCode}); err != nil {
+			respondCreateFailure(c, err)
+			return
+		}
+	} else {
+		var err error
+		if shortCode, err = createShortURL(); err != nil {
+			respondCreateFailure(c, err)
+			return
+		}
+	}
+
+	p := database.BioPage{
+		ShortCode:   shortCode,
+		UserID:      userID,
+		Title:       req.Title,
+		Description: req.Description,
+		AvatarURL:   req.AvatarURL,
+		Theme:       req.Theme,
+		Links:       links,
+	}
+	if err := database.CreateBioPage(&p); err != nil {
+		log.Warn().Err(err).Msg("Failed to create bio page")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
+		return
+	}
+	c.JSON(http.StatusOK, newBioPageInfo(p))
+}
+
+// findOwnBioPage reads the page of the ":code" path parameter, which must be owned by the caller.
+func findOwnBioPage(c *gin.Context) (database.BioPage, bool) {
+	p, err := database.GetBioPageByCode(c.Param("code"))
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && p.UserID != c.GetString("user_id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "page not found"})
+		return p, false
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to get bio page")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return p, false
+	}
+	return p, true
+}
+
+// UpdateBioPage replaces the content of a bio page of the caller, with the body of
+// CreateBioPage. The short code can not be changed, the alias is ignored.
+func UpdateBioPage(c *gin.Context) {
+	p, ok := findOwnBioPage(c)
+	if !ok {
+		return
+	}
+	req, links, ok := bindBioPageRequest(c, p.UserID)
+	if !ok {
+		return
+	}
+
+	p.Title, p.Description, p.AvatarURL, p.Theme, p.Links = req.Title, req.Description, req.AvatarURL, req.Theme, links
+	if err := database.UpdateBioPage(&p); err != nil {
+		log.Warn().Err(err).Str("shortCode", p.ShortCode).Msg("Failed to update bio page")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
+		return
+	}
+	c.JSON(http.StatusOK, newBioPageInfo(p))
+}
+
+// ListBioPages lists the bio pages of the caller.
+func ListBioPages(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	pages, err := database.ListBioPages(userID)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list bio pages")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list pages"})
+		return
+	}
+	infos := make([]BioPageInfo, 0, len(pages))
+	for _, p := range pages {
+		infos = append(infos, newBioPageInfo(p))
+	}
+	c.JSON(http.StatusOK, gin.H{"pages": infos})
+}
+
+// DeleteBioPage deletes a bio page of the caller, the short URLs it lists are kept.
+func DeleteBioPage(c *gin.Context) {
+	p, ok := findOwnBioPage(c)
+	if !ok {
+		return
+	}
+	if err := database.DeleteBioPage(p); err != nil {
+		log.Warn().Err(err).Str("shortCode", p.ShortCode).Msg("Failed to delete bio page")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "page deleted"})
+}
+
+// bioEntry is one rendered entry of a bio page.
+type bioEntry struct {
+	Label string
+	URL   string
+}
+
+// bioEntries returns the entries of the page that visitors can follow. Links that
+// are not active, were disabled or are not public are left out.
+func bioEntries(c *gin.Context, p database.BioPage, links map[string]database.UserShortURL) []bioEntry {
+	now := time.Now()
+	entries := make([]bioEntry, 0, len(p.Links))
+	for _, l := range p.Links {
+		link, ok := links[l.ShortCode]
+		if !ok || LinkStatus(link, now) != StatusActive || link.Disabled {
+			continue
+		}
+		if v := linkVisibility(link); v == VisibilityPrivate || v == VisibilityNamespace {
+			continue
+		}
+		entries = append(entries, bioEntry{Label: bioLabel(l, link), URL: LinkURL(c, link)})
+	}
+	return entries
+}
+
+// bioLabel returns the label of an entry, falling back to the title of the link,
+// the title of its destination page and the destination host.
+func bioLabel(l database.BioPageLink, link database.UserShortURL) string {
+	for _, label := range []string{l.Label, link.Title, link.PageTitle} {
+		if label != "" {
+			return label
+		}
+	}
+	if u, err := url.Parse(link.OriginalURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return l.ShortCode
+}
+
+// RenderBioPage serves a bio page as HTML and counts the view.
+//
+// Entries point to the short URLs, so their clicks go through the redirect path
+// and are logged like any other access.
+func RenderBioPage(c *gin.Context, p database.BioPage) {
+	codes := make([]string, len(p.Links))
+	for i, l := range p.Links {
+		codes[i] = l.ShortCode
+	}
+	links, err := database.GetUserShortURLsByCodes(p.UserID, codes)
+	if err != nil {
+		log.Warn().Err(err).Str("shortCode", p.ShortCode).Msg("Failed to get bio page links")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	if c.Request.Method != http.MethodHead {
+		if err := database.LogBioPageView(p.ShortCode); err != nil {
+			log.Warn().Err(err).Str("shortCode", p.ShortCode).Msg("Failed to log bio page view")
+		}
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	renderBioPage(c, p, links)
+}
+
+// renderBioPage renders the page with the given short URLs of its owner.
+func renderBioPage(c *gin.Context, p database.BioPage, links map[string]database.UserShortURL) {
+	page.Render(c, http.StatusOK, "bio.html", gin.H{
+		"Title":       p.Title,
+		"Description": p.Description,
+		"AvatarURL":   p.AvatarURL,
+		"Theme":       bioThemes[bioTheme(p.Theme)],
+		"Entries":     bioEntries(c, p, links),
+	})
+}
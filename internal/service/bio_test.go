@@ -0,0 +1,83 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/database"
+)
+
+func TestRenderBioPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := testContext(w, http.MethodGet, "http://s.example.com/jane", nil)
+
+	future := time.Now().Add(time.Hour)
+	p := database.BioPage{
+		ShortCode: "jane",
+		Title:     "Jane <Doe>",
+		Theme:     "dark",
+		Links: []database.BioPageLink{
+			{Position: 0, ShortCode: "blog", Label: "My blog"},
+			{Position: 1, ShortCode: "shop"},
+			{Position: 2, ShortCode: "old"},
+			{Position: 3, ShortCode: "secret"},
+			{Position: 4, ShortCode: "deleted"},
+			{Position: 5, ShortCode: "site"},
+		},
+	}
+	links := map[string]database.UserShortURL{
+		"blog":   {ShortCode: "blog", OriginalURL: "https://blog.example.com", ExpireAt: future},
+		"shop":   {ShortCode: "shop", OriginalURL: "https://shop.example.com", ExpireAt: future, LinkOptions: database.LinkOptions{Title: "Shop"}},
+		"old":    {ShortCode: "old", OriginalURL: "https://old.example.com", ExpireAt: time.Now().Add(-time.Hour)},
+		"secret": {ShortCode: "secret", OriginalURL: "https://secret.example.com", ExpireAt: future, LinkOptions: database.LinkOptions{Visibility: VisibilityPrivate}},
+		"site":   {ShortCode: "site", OriginalURL: "https://www.example.com/about", ExpireAt: future},
+	}
+	renderBioPage(c, p, links)
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	for _, want := range []string{
+		"<h1>Jane &lt;Doe&gt;</h1>",
+		string(bioThemes["dark"]),
+		`<a href="http://s.example.com/blog" rel="noopener">My blog</a>`,
+		`<a href="http://s.example.com/shop" rel="noopener">Shop</a>`,
+		`<a href="http://s.example.com/site" rel="noopener">www.example.com</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page misses %s:\n%s", want, body)
+		}
+	}
+	for _, unwanted := range []string{"/old", "/secret", "/deleted"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("page lists %s", unwanted)
+		}
+	}
+	if strings.Index(body, "/blog") > strings.Index(body, "/shop") {
+		t.Error("entries are not in page order")
+	}
+}
+
+func TestBioPageRequestValidate(t *testing.T) {
+	valid := bioPageRequest{Title: "Jane", Theme: "ocean", Links: []BioLink{{ShortURL: "abc123"}}}
+	if err := valid.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+
+	tests := map[string]bioPageRequest{
+		"no title":      {},
+		"unknown theme": {Title: "Jane", Theme: "neon"},
+		"empty link":    {Title: "Jane", Links: []BioLink{{Label: "x"}}},
+		"long label":    {Title: "Jane", Links: []BioLink{{ShortURL: "abc", Label: strings.Repeat("x", maxBioLabelLength+1)}}},
+		"bad alias":     {Title: "Jane", Alias: "a"},
+		"many links":    {Title: "Jane", Links: make([]BioLink, maxBioLinks+1)},
+	}
+	for name, req := range tests {
+		if err := req.validate(); err == nil {
+			t.Errorf("%s: validate() error = nil", name)
+		}
+	}
+}
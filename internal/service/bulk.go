@@ -0,0 +1,326 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/ratelimit"
+	"url-shortener/internal/pkg/urlpolicy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultBulkMaxRows   = 1000 // 单次批量创建的最大行数
+	defaultBulkChunkSize = 100  // 每个事务写入的行数
+)
+
+var (
+	bulkLimiterOnce sync.Once
+	bulkLimiter     ratelimit.Limiter
+)
+
+// BulkResult is the outcome of one row of a bulk create request.
+type BulkResult struct {
+	Row         int    `json:"row"` // 从 1 开始的行号，CSV 不计表头
+	OriginalURL string `json:"original_url,omitempty"`
+	ShortURL    string `json:"short_url,omitempty"`
+	// OriginalCode is the requested code of an imported link which got a new one.
+	OriginalCode string `json:"original_code,omitempty"`
+	Code         string `json:"code,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// bulkLimit returns the per-user row limiter, or nil when bulk.rows_per_minute is not set.
+func bulkLimit() ratelimit.Limiter {
+	bulkLimiterOnce.Do(func() {
+		perMinute := viper.GetInt("bulk.rows_per_minute")
+		if perMinute > 0 {
+			bulkLimiter = ratelimit.NewMemoryLimiter(float64(perMinute)/60, perMinute)
+		}
+	})
+	return bulkLimiter
+}
+
+// parseBulkCSV reads bulk rows from CSV. The first line is a header naming the columns:
+// long_url (or url), alias, expire_at (RFC 3339), tags (separated by ';') and title.
+// Unknown columns are ignored.
+func parseBulkCSV(r io.Reader, maxRows int) ([]shortURLRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("csv header is required")
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["long_url"]; !ok {
+		if i, ok := columns["url"]; ok {
+			columns["long_url"] = i
+		} else {
+			return nil, errors.New("csv header must have a long_url column")
+		}
+	}
+
+	var reqs []shortURLRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(reqs) == maxRows {
+			return nil, fmt.Errorf("at most %d rows are allowed", maxRows)
+		}
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		req := shortURLRequest{LongURL: field("long_url"), Alias: field("alias"), Title: field("title")}
+		if tags := field("tags"); tags != "" {
+			for _, tag := range strings.Split(tags, ";") {
+				req.Tags = append(req.Tags, strings.TrimSpace(tag))
+			}
+		}
+		if expireAt := field("expire_at"); expireAt != "" {
+			t, err := time.Parse(time.RFC3339, expireAt)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: expire_at must be RFC 3339", len(reqs)+1)
+			}
+			req.ExpireAt = &t
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// bindBulkRequest reads the rows of a bulk create request. It accepts a JSON array,
+// a CSV body (text/csv) or a CSV file uploaded in the multipart "file" field.
+func bindBulkRequest(c *gin.Context, maxRows int) ([]shortURLRequest, error) {
+	switch c.ContentType() {
+	case "text/csv":
+		return parseBulkCSV(c.Request.Body, maxRows)
+	case "multipart/form-data":
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return parseBulkCSV(f, maxRows)
+	default:
+		var reqs []shortURLRequest
+		if err := json.NewDecoder(c.Request.Body).Decode(&reqs); err != nil {
+			return nil, err
+		}
+		if len(reqs) > maxRows {
+			return nil, fmt.Errorf("at most %d rows are allowed", maxRows)
+		}
+		return reqs, nil
+	}
+}
+
+// BulkShortCodeCreater creates many user short URLs in one request.
+// The body is a JSON array of the objects accepted by UserShortCodeCreater, or a CSV
+// file (see parseBulkCSV).
+//
+// Rows are validated one by one and valid rows are saved in chunks of bulk.chunk_size,
+// each chunk in its own transaction. The rate limit and the link quota are charged for
+// the whole batch. The response reports every row:
+//
+//	{
+//	    "created": 1,
+//	    "failed": 1,
+//	    "results": [
+//	        {"row": 1, "original_url": "https://www.example.com", "short_url": "abc123"},
+//	        {"row": 2, "code": "alias_taken", "error": "alias is already taken"}
+//	    ]
+//	}
+func BulkShortCodeCreater(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	maxRows := viper.GetInt("bulk.max_rows")
+	if maxRows <= 0 {
+		maxRows = defaultBulkMaxRows
+	}
+	chunkSize := viper.GetInt("bulk.chunk_size")
+	if chunkSize <= 0 {
+		chunkSize = defaultBulkChunkSize
+	}
+
+	reqs, err := bindBulkRequest(c, maxRows)
+	if err != nil {
+		log.Err(err).Msg("Invalid bulk request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(reqs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no rows"})
+		return
+	}
+
+	if limiter := bulkLimit(); limiter != nil {
+		allowed, err := limiter.AllowN(c.Request.Context(), userID, len(reqs))
+		if err != nil {
+			respondCreateFailure(c, err)
+			return
+		}
+		if !allowed {
+			respondCreateFailure(c, &createError{Status: http.StatusTooManyRequests, Code: "rate_limited", Message: "too many links created, try again later"})
+			return
+		}
+	}
+
+	results := make([]BulkResult, len(reqs))
+	valid := validateBulkRows(c, reqs, results)
+	if len(valid) == 0 {
+		c.JSON(http.StatusOK, bulkResponse(results))
+		return
+	}
+	if err := checkQuota(userID, len(valid)); err != nil {
+		respondCreateFailure(c, err)
+		return
+	}
+
+	shorts := make([]database.UserShortURL, 0, len(valid))
+	for _, i := range valid {
+		req := reqs[i]
+		shortCode := req.Alias
+		if shortCode == "" {
+			if shortCode, err = createShortURL(); err != nil {
+				respondCreateFailure(c, err)
+				return
+			}
+		}
+		shorts = append(shorts, database.UserShortURL{UserID: userID, ShortCode: shortCode, OriginalURL: req.LongURL, ExpireAt: req.expireAt(), LinkOptions: req.options()})
+	}
+
+	for start := 0; start < len(shorts); start += chunkSize {
+		end := min(start+chunkSize, len(shorts))
+		chunk := shorts[start:end]
+		if err := database.CreateUserShortURLs(chunk, c.ClientIP()); err != nil {
+			log.Warn().Err(err).Int("rows", len(chunk)).Msg("Failed to save bulk chunk")
+			for _, i := range valid[start:end] {
+				results[i].Code, results[i].Error = "save_failed", "save failed"
+			}
+			continue
+		}
+		for j, short := range chunk {
+			i := valid[start+j]
+			if err := saveVariants(short.ShortCode, reqs[i].Destinations); err != nil {
+				log.Warn().Err(err).Str("shortCode", short.ShortCode).Msg("Failed to save link variants")
+			}
+			results[i].ShortURL = short.ShortCode
+			enqueueMetadata(short.ShortCode, short.OriginalURL)
+		}
+	}
+
+	c.JSON(http.StatusOK, bulkResponse(results))
+}
+
+// validateBulkRows validates every row and fills in the failures.
+// It returns the indexes of the valid rows.
+func validateBulkRows(c *gin.Context, reqs []shortURLRequest, results []BulkResult) []int {
+	var valid, aliased []int
+	var aliases []string
+	seen := make(map[string]bool)
+	for i := range reqs {
+		results[i].Row = i + 1
+		if err := reqs[i].validate(c.Request.Context(), false); err != nil {
+			results[i].Code, results[i].Error = bulkErrorCode(err), err.Error()
+			continue
+		}
+		if reqs[i].Domain != "" {
+			results[i].Code, results[i].Error = "domain_invalid", "custom domains are not supported in bulk requests"
+			continue
+		}
+		results[i].OriginalURL = reqs[i].LongURL
+		if alias := reqs[i].Alias; alias != "" {
+			if seen[alias] {
+				results[i].Code, results[i].Error = "alias_taken", "alias is used by another row"
+				continue
+			}
+			seen[alias] = true
+			aliased = append(aliased, i)
+			aliases = append(aliases, alias)
+		}
+		valid = append(valid, i)
+	}
+	if len(aliases) == 0 {
+		return valid
+	}
+
+	inUse, err := database.ShortCodesInUse(aliases)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to check aliases")
+		for _, i := range aliased {
+			results[i].Code, results[i].Error = "save_failed", "failed to check alias"
+		}
+	}
+	kept := valid[:0]
+	for _, i := range valid {
+		alias := reqs[i].Alias
+		if alias != "" && (err != nil || inUse[alias]) {
+			if err == nil {
+				results[i].Code, results[i].Error = "alias_taken", "alias is already taken"
+			}
+			continue
+		}
+		kept = append(kept, i)
+	}
+	return kept
+}
+
+// bulkErrorCode returns the error code of a rejected row.
+func bulkErrorCode(err error) string {
+	var createErr *createError
+	if errors.As(err, &createErr) {
+		return createErr.Code
+	}
+	var policyErr *urlpolicy.Error
+	if errors.As(err, &policyErr) {
+		return policyErr.Code
+	}
+	return "invalid_request"
+}
+
+func bulkResponse(results []BulkResult) gin.H {
+	var created int
+	for _, r := range results {
+		if r.ShortURL != "" {
+			created++
+		}
+	}
+	return gin.H{
+		"created": created,
+		"failed":  len(results) - created,
+		"results": results,
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/linkio"
+	"url-shortener/internal/pkg/ratelimit"
+)
+
+func TestParseBulkCSV(t *testing.T) {
+	input := "url,alias,expire_at,tags,extra\n" +
+		"https://example.com/a,spring,2030-01-01T00:00:00Z,campaign; mail,x\n" +
+		"https://example.com/b\n"
+
+	reqs, err := parseBulkCSV(strings.NewReader(input), 10)
+	if err != nil {
+		t.Fatalf("parseBulkCSV() error = %v", err)
+	}
+	if len(reqs) != 2 {
+		t.Fatalf("got %d rows, want 2", len(reqs))
+	}
+
+	first := reqs[0]
+	if first.LongURL != "https://example.com/a" || first.Alias != "spring" {
+		t.Errorf("first row = %+v", first)
+	}
+	if first.ExpireAt == nil || !first.ExpireAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("first row expire_at = %v", first.ExpireAt)
+	}
+	if strings.Join(first.Tags, ",") != "campaign,mail" {
+		t.Errorf("first row tags = %v", first.Tags)
+	}
+	if reqs[1].LongURL != "https://example.com/b" || reqs[1].Alias != "" || reqs[1].ExpireAt != nil {
+		t.Errorf("second row = %+v", reqs[1])
+	}
+}
+
+func TestParseBulkCSVErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"no url column", "alias,tags\nabc,x\n"},
+		{"bad expiry", "long_url,expire_at\nhttps://example.com,tomorrow\n"},
+		{"too many rows", "long_url\nhttps://example.com/1\nhttps://example.com/2\nhttps://example.com/3\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseBulkCSV(strings.NewReader(tt.input), 2); err == nil {
+				t.Error("parseBulkCSV() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		alias string
+		code  string
+	}{
+		{"spring-24", ""},
+		{"a_b", ""},
+		{"ab", "alias_invalid"},
+		{"waytoolongalias", "alias_invalid"},
+		{"no/slash", "alias_invalid"},
+		{"Health", "alias_reserved"},
+		{"challenge", "alias_reserved"},
+		{"shortcodes", "alias_reserved"},
+		{"v1", "alias_invalid"},
+	}
+	for _, tt := range tests {
+		err := validateAlias(tt.alias)
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("validateAlias(%q) error = %v", tt.alias, err)
+			}
+			continue
+		}
+		if got := bulkErrorCode(err); err == nil || got != tt.code {
+			t.Errorf("validateAlias(%q) code = %q, want %q", tt.alias, got, tt.code)
+		}
+	}
+}
+
+func TestAllowImportRows(t *testing.T) {
+	bulkLimiterOnce.Do(func() {})
+	bulkLimiter = ratelimit.NewMemoryLimiter(1, 10)
+	defer func() { bulkLimiter = nil }()
+
+	ctx := context.Background()
+	if err := allowImportRows(ctx, "u1", 8); err != nil {
+		t.Fatalf("first chunk: %v", err)
+	}
+	if err := allowImportRows(ctx, "u1", 8); bulkErrorCode(err) != "rate_limited" {
+		t.Fatalf("second chunk error = %v, want rate_limited", err)
+	}
+	if err := allowImportRows(ctx, "u2", 8); err != nil {
+		t.Fatalf("other caller: %v", err)
+	}
+}
+
+func TestImportExpireAt(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := (importOptions{}).expireAt(now); !got.Equal(importNoExpiry) {
+		t.Errorf("default expireAt() = %v, want %v", got, importNoExpiry)
+	}
+	if got := (importOptions{ExpireAfter: time.Hour}).expireAt(now); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("expire_after expireAt() = %v", got)
+	}
+}
+
+func TestImportedLinkClicks(t *testing.T) {
+	input := "INSERT INTO `yourls_url` VALUES ('ozh','http://ozh.org/','Ozh','2009-04-24 12:00:00','127.0.0.1',12);\n"
+	r, err := linkio.NewReader(linkio.FormatYOURLSSQL, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := shortURLRequest{LongURL: rec.URL}
+
+	// migrations keep the click totals of the other shortener
+	short := importedLink(rec, req, rec.Code, importOptions{UserID: "u1", Migration: true})
+	if short.AccessCount != 12 || short.ShortCode != "ozh" || !short.CreatedAt.Equal(rec.CreatedAt) {
+		t.Errorf("migrated link = %+v", short)
+	}
+	// user imports start at zero
+	if short := importedLink(rec, req, rec.Code, importOptions{UserID: "u1"}); short.AccessCount != 0 {
+		t.Errorf("imported AccessCount = %d, want 0", short.AccessCount)
+	}
+}
+
+// sliceReader reads records from a slice.
+type sliceReader []linkio.Record
+
+func (r *sliceReader) Read() (linkio.Record, error) {
+	if len(*r) == 0 {
+		return linkio.Record{}, io.EOF
+	}
+	rec := (*r)[0]
+	*r = (*r)[1:]
+	return rec, nil
+}
+
+func TestImportLinksBulkLimit(t *testing.T) {
+	bulkLimiterOnce.Do(func() {})
+	bulkLimiter = ratelimit.NewMemoryLimiter(1, 10)
+	defer func() { bulkLimiter = nil }()
+
+	// expired records are rejected before anything is looked up
+	records := func() *sliceReader {
+		r := make(sliceReader, 25)
+		for i := range r {
+			r[i] = linkio.Record{URL: "https://www.example.com", ExpireAt: time.Now().Add(-time.Hour)}
+		}
+		return &r
+	}
+	ctx := context.Background()
+
+	results, err := importLinks(ctx, records(), importOptions{UserID: "u1", CallerID: "u1", ChunkSize: 10})
+	if bulkErrorCode(err) != "rate_limited" || len(results) != 10 {
+		t.Fatalf("user import = %d results, %v, want rate_limited after the first chunk", len(results), err)
+	}
+
+	// migrations run to the end of the file
+	results, err = importLinks(ctx, records(), importOptions{UserID: "u2", CallerID: "u2", ChunkSize: 10, Migration: true})
+	if err != nil || len(results) != 25 {
+		t.Fatalf("migration = %d results, %v", len(results), err)
+	}
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	maxCampaignNameLength = 64
+	maxCampaignLinks      = 1000 // 一次最多关联的短链数
+	maxStatsBuckets       = 1000 // 时间序列最多的时间段数
+	defaultStatsRange     = 30 * 24 * time.Hour
+	clickRetentionPeriod  = time.Hour // 两次清理过期点击记录的间隔
+)
+
+// statsIntervals are the bucket sizes of the campaign click series.
+var statsIntervals = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+// CampaignInfo describes a campaign.
+type CampaignInfo struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newCampaignInfo(campaign database.Campaign) CampaignInfo {
+	return CampaignInfo{
+		ID:          campaign.ID,
+		Name:        campaign.Name,
+		Description: campaign.Description,
+		Namespace:   campaign.Namespace,
+		CreatedAt:   campaign.CreatedAt,
+	}
+}
+
+type campaignRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Namespace makes the campaign shared by the users allowed to manage campaigns there.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// CreateCampaign creates a campaign owned by the caller, or by a namespace in which
+// the caller may "create campaigns".
+func CreateCampaign(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req campaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if len(req.Name) > maxCampaignNameLength || len(req.Description) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name or description is too long"})
+		return
+	}
+	if req.Namespace != "" && !authorizeCaller(c, "create", "campaigns", req.Namespace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	campaign := database.Campaign{Name: req.Name, Description: req.Description, OwnerID: userID, Namespace: req.Namespace}
+	if err := database.CreateCampaign(&campaign); err != nil {
+		log.Warn().Err(err).Msg("Failed to create campaign")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
+		return
+	}
+	c.JSON(http.StatusOK, newCampaignInfo(campaign))
+}
+
+// ListCampaigns lists the campaigns of the caller, or with ?namespace= the campaigns
+// of a namespace in which the caller may "get campaigns".
+func ListCampaigns(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	namespace := c.Query("namespace")
+	if namespace != "" && !authorizeCaller(c, "get", "campaigns", namespace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	campaigns, err := database.ListCampaigns(userID, namespace)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list campaigns")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list campaigns"})
+		return
+	}
+
+	infos := make([]CampaignInfo, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		infos = append(infos, newCampaignInfo(campaign))
+	}
+	c.JSON(http.StatusOK, gin.H{"campaigns": infos})
+}
+
+// canUseCampaign reports whether the caller may do verb on campaign. The creator of
+// a user campaign can do anything, namespace campaigns are checked with RBAC.
+func canUseCampaign(c *gin.Context, campaign database.Campaign, verb string) bool {
+	if campaign.Namespace == "" {
+		return campaign.OwnerID == c.GetString("user_id")
+	}
+	return authorizeCaller(c, verb, "campaigns", campaign.Namespace)
+}
+
+// loadCampaign reads the campaign of the ":id" path parameter and checks that the
+// caller may do verb on it. It responds and returns false otherwise.
+// Campaigns the caller can not see are reported as missing.
+func loadCampaign(c *gin.Context, verb string) (database.Campaign, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "campaign not found"})
+		return database.Campaign{}, false
+	}
+	campaign, err := database.GetCampaign(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !canUseCampaign(c, campaign, "get")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "campaign not found"})
+		return database.Campaign{}, false
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to get campaign")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return database.Campaign{}, false
+	}
+	if verb != "get" && !canUseCampaign(c, campaign, verb) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return database.Campaign{}, false
+	}
+	return campaign, true
+}
+
+// DeleteCampaign deletes a campaign. Its links are detached, not deleted.
+func DeleteCampaign(c *gin.Context) {
+	campaign, ok := loadCampaign(c, "delete")
+	if !ok {
+		return
+	}
+	if err := database.DeleteCampaign(campaign.ID); err != nil {
+		log.Warn().Err(err).Uint("campaign", campaign.ID).Msg("Failed to delete campaign")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "campaign deleted"})
+}
+
+// AttachCampaignLinks attaches short URLs of the caller to a campaign.
+// A link belongs to at most one campaign, attaching moves it. Send JSON as follows:
+//
+//	{"short_urls": ["abc123", "spring"]}
+//
+// Short codes the caller does not own are ignored, "attached" counts the others.
+func AttachCampaignLinks(c *gin.Context) {
+	campaign, ok := loadCampaign(c, "update")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ShortURLs []string `json:"short_urls"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.ShortURLs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "short_urls is required"})
+		return
+	}
+	if len(req.ShortURLs) > maxCampaignLinks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many short URLs"})
+		return
+	}
+
+	attached, err := database.SetLinksCampaign(c.GetString("user_id"), req.ShortURLs, campaign.ID)
+	if err != nil {
+		log.Warn().Err(err).Uint("campaign", campaign.ID).Msg("Failed to attach campaign links")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"attached": attached})
+}
+
+// DetachCampaignLink removes the short URL of the ":code" path parameter from a campaign.
+func DetachCampaignLink(c *gin.Context) {
+	campaign, ok := loadCampaign(c, "update")
+	if !ok {
+		return
+	}
+
+	shortCode := c.Param("code")
+	shortURL, err := database.FindUserShortURL(shortCode)
+	if err != nil || shortURL.CampaignID != campaign.ID || shortURL.UserID != c.GetString("user_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+	if _, err := database.SetLinksCampaign(shortURL.UserID, []string{shortCode}, 0); err != nil {
+		log.Warn().Err(err).Str("shortCode", shortCode).Msg("Failed to detach campaign link")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "link detached"})
+}
+
+// statsRange is the time range and bucket size of a stats request.
+type statsRange struct {
+	from, to time.Time
+	interval string
+	bucket   time.Duration
+}
+
+// parseStatsRange reads the query parameters of a stats request:
+//
+//	from, to: RFC 3339 times, default the last 30 days
+//	interval: hour or day (default)
+func parseStatsRange(c *gin.Context, now time.Time) (statsRange, error) {
+	r := statsRange{to: now, interval: c.DefaultQuery("interval", "day")}
+	var ok bool
+	if r.bucket, ok = statsIntervals[r.interval]; !ok {
+		return r, errors.New("interval must be hour or day")
+	}
+
+	var err error
+	if s := c.Query("to"); s != "" {
+		if r.to, err = time.Parse(time.RFC3339, s); err != nil {
+			return r, errors.New("to must be an RFC 3339 time")
+		}
+	}
+	r.from = r.to.Add(-defaultStatsRange)
+	if s := c.Query("from"); s != "" {
+		if r.from, err = time.Parse(time.RFC3339, s); err != nil {
+			return r, errors.New("from must be an RFC 3339 time")
+		}
+	}
+	if !r.from.Before(r.to) {
+		return r, errors.New("from must be before to")
+	}
+	if r.to.Sub(r.from)/r.bucket >= maxStatsBuckets {
+		return r, errors.New("time range has too many intervals")
+	}
+	return r, nil
+}
+
+// ClickPoint is the number of clicks in one interval of a time series.
+type ClickPoint struct {
+	Time           time.Time `json:"time"`
+	Clicks         int64     `json:"clicks"`
+	UniqueVisitors int64     `json:"unique_visitors"`
+}
+
+// fillSeries turns the non-empty buckets into a series with a point for every
+// interval of r. Buckets are aligned to multiples of the interval since the Unix epoch,
+// i.e. days start at midnight UTC.
+func fillSeries(buckets []database.ClickBucket, r statsRange) []ClickPoint {
+	seconds := int64(r.bucket.Seconds())
+	byStart := make(map[int64]database.ClickBucket, len(buckets))
+	for _, b := range buckets {
+		byStart[b.Bucket] = b
+	}
+
+	var series []ClickPoint
+	for start := r.from.Unix() / seconds * seconds; start < r.to.Unix(); start += seconds {
+		b := byStart[start]
+		series = append(series, ClickPoint{Time: time.Unix(start, 0).UTC(), Clicks: b.Clicks, UniqueVisitors: b.Visitors})
+	}
+	return series
+}
+
+// CampaignLinkStats is the per link breakdown of the campaign stats.
+type CampaignLinkStats struct {
+	ShortURL       string `json:"short_url"`
+	OriginalURL    string `json:"original_url"`
+	Title          string `json:"title,omitempty"`
+	Clicks         int64  `json:"clicks"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+	// AccessCount is the all time access count of the link, also before it joined the campaign.
+	AccessCount uint `json:"access_count"`
+}
+
+// CampaignStats responds with the aggregated clicks of a campaign: totals, a time
+// series and a breakdown per link, see parseStatsRange for the query parameters.
+//
+// Only clicks made while a link was attached to the campaign are counted.
+func CampaignStats(c *gin.Context) {
+	campaign, ok := loadCampaign(c, "get")
+	if !ok {
+		return
+	}
+	r, err := parseStatsRange(c, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	links, err := database.GetCampaignLinks(campaign.ID)
+	if err != nil {
+		log.Warn().Err(err).Uint("campaign", campaign.ID).Msg("Failed to get campaign links")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats"})
+		return
+	}
+	buckets, err := database.GetCampaignClickSeries(campaign.ID, r.from, r.to, r.bucket)
+	if err != nil {
+		log.Warn().Err(err).Uint("campaign", campaign.ID).Msg("Failed to get campaign click series")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats"})
+		return
+	}
+	total, perLink, err := database.GetCampaignClickCounts(campaign.ID, r.from, r.to)
+	if err != nil {
+		log.Warn().Err(err).Uint("campaign", campaign.ID).Msg("Failed to count campaign clicks")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"campaign":        newCampaignInfo(campaign),
+		"from":            r.from,
+		"to":              r.to,
+		"interval":        r.interval,
+		"clicks":          total.Clicks,
+		"unique_visitors": total.Visitors,
+		"series":          fillSeries(buckets, r),
+		"links":           campaignLinkStats(links, perLink),
+	})
+}
+
+// campaignLinkStats builds the breakdown of the attached links. Links that were
+// detached after being clicked are listed with an empty destination.
+func campaignLinkStats(links []database.UserShortURL, counts []database.ClickCount) []CampaignLinkStats {
+	byCode := make(map[string]database.ClickCount, len(counts))
+	for _, count := range counts {
+		byCode[count.ShortCode] = count
+	}
+
+	stats := make([]CampaignLinkStats, 0, len(links))
+	for _, link := range links {
+		count := byCode[link.ShortCode]
+		delete(byCode, link.ShortCode)
+		stats = append(stats, CampaignLinkStats{
+			ShortURL:       link.ShortCode,
+			OriginalURL:    link.OriginalURL,
+			Title:          link.Title,
+			Clicks:         count.Clicks,
+			UniqueVisitors: count.Visitors,
+			AccessCount:    link.GetAccessCount(),
+		})
+	}
+	for _, count := range counts {
+		if _, detached := byCode[count.ShortCode]; detached {
+			stats = append(stats, CampaignLinkStats{ShortURL: count.ShortCode, Clicks: count.Clicks, UniqueVisitors: count.Visitors})
+		}
+	}
+	return stats
+}
+
+// StartClickRetention deletes the campaign clicks older than
+// campaigns.click_retention every hour until ctx is done. Clicks are kept forever
+// when it is not set.
+func StartClickRetention(ctx context.Context) {
+	retention := viper.GetDuration("campaigns.click_retention")
+	if retention <= 0 {
+		log.Info().Msg("Campaign click retention disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(clickRetentionPeriod)
+		defer ticker.Stop()
+		for {
+			if deleted, err := database.DeleteClicksBefore(time.Now().Add(-retention)); err != nil {
+				log.Warn().Err(err).Msg("Failed to delete old campaign clicks")
+			} else if deleted > 0 {
+				log.Info().Int64("clicks", deleted).Msg("Deleted old campaign clicks")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Info().Dur("retention", retention).Msg("Campaign click retention started")
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/database"
+)
+
+func TestParseStatsRange(t *testing.T) {
+	now := time.Date(2030, 1, 31, 12, 0, 0, 0, time.UTC)
+	r, err := parseStatsRange(testContext(httptest.NewRecorder(), http.MethodGet, "/v1/auth/campaigns/1/stats", nil), now)
+	if err != nil || r.interval != "day" || !r.to.Equal(now) || !r.from.Equal(now.Add(-defaultStatsRange)) {
+		t.Fatalf("parseStatsRange() = %+v, %v", r, err)
+	}
+
+	r, err = parseStatsRange(testContext(httptest.NewRecorder(), http.MethodGet, "/v1/auth/campaigns/1/stats?interval=hour&from=2030-01-01T00:00:00Z&to=2030-01-02T00:00:00Z", nil), now)
+	if err != nil || r.bucket != time.Hour || r.to.Sub(r.from) != 24*time.Hour {
+		t.Fatalf("parseStatsRange(hour) = %+v, %v", r, err)
+	}
+
+	for _, query := range []string{"interval=week", "from=yesterday", "from=2030-02-01T00:00:00Z", "interval=hour&from=2029-01-01T00:00:00Z"} {
+		if _, err := parseStatsRange(testContext(httptest.NewRecorder(), http.MethodGet, "/v1/auth/campaigns/1/stats?"+query, nil), now); err == nil {
+			t.Errorf("parseStatsRange(%s) error = nil", query)
+		}
+	}
+}
+
+func TestFillSeries(t *testing.T) {
+	day := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := statsRange{from: day.Add(6 * time.Hour), to: day.Add(72 * time.Hour), interval: "day", bucket: 24 * time.Hour}
+	buckets := []database.ClickBucket{{Bucket: day.Add(24 * time.Hour).Unix(), Clicks: 5, Visitors: 3}}
+
+	series := fillSeries(buckets, r)
+	if len(series) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(series))
+	}
+	if !series[0].Time.Equal(day) || series[0].Clicks != 0 {
+		t.Errorf("first point = %+v", series[0])
+	}
+	if series[1].Clicks != 5 || series[1].UniqueVisitors != 3 {
+		t.Errorf("second point = %+v", series[1])
+	}
+}
+
+func TestCampaignLinkStats(t *testing.T) {
+	links := []database.UserShortURL{
+		{ShortCode: "abc123", OriginalURL: "https://a.example.com", AccessCount: 20},
+		{ShortCode: "quiet", OriginalURL: "https://b.example.com"},
+	}
+	counts := []database.ClickCount{{ShortCode: "abc123", Clicks: 7, Visitors: 4}, {ShortCode: "gone", Clicks: 2, Visitors: 2}}
+
+	stats := campaignLinkStats(links, counts)
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", stats)
+	}
+	if stats[0].Clicks != 7 || stats[0].UniqueVisitors != 4 || stats[0].AccessCount != 20 {
+		t.Errorf("attached link = %+v", stats[0])
+	}
+	if stats[1].Clicks != 0 {
+		t.Errorf("link without clicks = %+v", stats[1])
+	}
+	if stats[2].ShortURL != "gone" || stats[2].Clicks != 2 || stats[2].OriginalURL != "" {
+		t.Errorf("detached link = %+v", stats[2])
+	}
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testContext returns a gin context for a request of method to target with the
+// given headers, its response is recorded in w.
+func testContext(w *httptest.ResponseRecorder, method, target string, header http.Header) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		c.Request.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	return c
+}
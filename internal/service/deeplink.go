@@ -0,0 +1,93 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"url-shortener/internal/pkg/database"
+)
+
+const (
+	platformIOS     = "ios"
+	platformAndroid = "android"
+)
+
+// appSchemePattern matches the scheme of an app link, e.g. "myapp".
+var appSchemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// unsafeAppSchemes run code or read local files instead of opening an app.
+// The deep-link page assigns the app link to window.location, so they must never get there.
+var unsafeAppSchemes = map[string]bool{
+	"javascript": true,
+	"data":       true,
+	"vbscript":   true,
+	"file":       true,
+	"blob":       true,
+}
+
+// detectPlatform tells iOS and Android visitors apart by their User-Agent.
+// Any other client returns an empty string.
+func detectPlatform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return platformIOS
+	case strings.Contains(ua, "android"):
+		return platformAndroid
+	default:
+		return ""
+	}
+}
+
+// appLinkFor returns the app link of the visitor platform, or an empty string
+// when the link has none for it.
+//
+// App links stored before they were validated are checked again, unsafe ones are ignored.
+func appLinkFor(opts database.LinkOptions, userAgent string) string {
+	var appURL string
+	switch detectPlatform(userAgent) {
+	case platformIOS:
+		appURL = opts.IOSURL
+	case platformAndroid:
+		appURL = opts.AndroidURL
+	}
+	if appURL == "" || validateAppLink(appURL) != nil {
+		return ""
+	}
+	return appURL
+}
+
+// validateAppLink checks the scheme of a custom scheme app link. Web app links
+// (universal links) are checked by the URL policy instead.
+func validateAppLink(appURL string) error {
+	u, err := url.Parse(appURL)
+	if err != nil || u.Scheme == "" {
+		return &createError{Status: http.StatusBadRequest, Code: "invalid_app_link", Message: "app links must be absolute URLs"}
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if !appSchemePattern.MatchString(scheme) || unsafeAppSchemes[scheme] {
+		return &createError{Status: http.StatusBadRequest, Code: "invalid_app_link", Message: "app link scheme " + scheme + " is not allowed"}
+	}
+	return nil
+}
+
+// webAppLinks returns the app links of opts that are plain web URLs, they are
+// redirected to like any destination.
+func webAppLinks(opts database.LinkOptions) []string {
+	var links []string
+	for _, appURL := range []string{opts.IOSURL, opts.AndroidURL} {
+		if isWebURL(appURL) {
+			links = append(links, appURL)
+		}
+	}
+	return links
+}
+
+// isWebURL reports whether the link can be followed by a plain HTTP redirect,
+// e.g. universal links and app links. Custom app schemes need the deep-link page.
+func isWebURL(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
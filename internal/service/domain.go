@@ -0,0 +1,607 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/urlpolicy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	// domainVerifyLabel is prepended to the host to name the TXT verification record.
+	domainVerifyLabel = "_url-shortener"
+	// domainVerifyPrefix precedes the token in the value of the TXT record.
+	domainVerifyPrefix  = "url-shortener-verification="
+	domainVerifyTimeout = 5 * time.Second
+
+	// domainKey is the context key of the custom domain a request was sent to.
+	domainKey = "domain"
+
+	defaultDomainCacheTTL = 30 * time.Second
+	maxDomainCacheEntries = 10000 // 缓存的主机名上限，Host 头由访客决定
+)
+
+// hostPattern matches lower case DNS host names with at least two labels.
+// The last label starts with a letter, so IPv4 addresses are rejected.
+var hostPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// TXTResolver looks up DNS TXT records, it is implemented by net.Resolver.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+var txtResolver TXTResolver = net.DefaultResolver
+
+// SetTXTResolver sets the resolver used to verify custom domains.
+func SetTXTResolver(r TXTResolver) {
+	txtResolver = r
+}
+
+// DomainVerification is the DNS record that proves the ownership of a domain.
+type DomainVerification struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// DomainInfo describes a custom domain.
+type DomainInfo struct {
+	ID             uint               `json:"id"`
+	Host           string             `json:"host"`
+	Namespace      string             `json:"namespace,omitempty"`
+	Verified       bool               `json:"verified"`
+	VerifiedAt     *time.Time         `json:"verified_at,omitempty"`
+	Verification   DomainVerification `json:"verification"`
+	RedirectStatus int                `json:"redirect_status,omitempty"`
+	NotFoundURL    string             `json:"not_found_url,omitempty"`
+	RootURL        string             `json:"root_url,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+func newDomainInfo(domain database.Domain) DomainInfo {
+	return DomainInfo{
+		ID:             domain.ID,
+		Host:           domain.Host,
+		Namespace:      domain.Namespace,
+		Verified:       domain.VerifiedAt != nil,
+		VerifiedAt:     domain.VerifiedAt,
+		Verification:   domainVerification(domain),
+		RedirectStatus: domain.RedirectStatus,
+		NotFoundURL:    domain.NotFoundURL,
+		RootURL:        domain.RootURL,
+		CreatedAt:      domain.CreatedAt,
+	}
+}
+
+// domainVerification returns the TXT record the owner of domain has to publish.
+func domainVerification(domain database.Domain) DomainVerification {
+	return DomainVerification{
+		Type:  "TXT",
+		Name:  domainVerifyLabel + "." + domain.Host,
+		Value: domainVerifyPrefix + domain.VerifyToken,
+	}
+}
+
+// normalizeHost lower cases host and removes the trailing dot of a fully qualified name.
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// requestHost returns the normalized host of the request, without the port.
+func requestHost(c *gin.Context) string {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return normalizeHost(host)
+}
+
+type domainRequest struct {
+	Host string `json:"host"`
+	// Namespace makes the domain shared by the users allowed to manage domains there.
+	Namespace string `json:"namespace,omitempty"`
+
+	// RedirectStatus is the default status of the links on the domain, one of 301, 302, 307 or 308.
+	RedirectStatus int `json:"redirect_status,omitempty"`
+	// NotFoundURL receives the visitors of unknown codes, RootURL the visitors of "/".
+	NotFoundURL string `json:"not_found_url,omitempty"`
+	RootURL     string `json:"root_url,omitempty"`
+}
+
+// validateSettings checks and normalizes the per-domain defaults.
+func (r *domainRequest) validateSettings(policy urlpolicy.Policy) error {
+	if r.RedirectStatus != 0 && !validRedirectStatus(r.RedirectStatus) {
+		return errors.New("redirect status must be one of 301, 302, 307, 308")
+	}
+	var err error
+	if r.NotFoundURL != "" {
+		if r.NotFoundURL, err = policy.Normalize(r.NotFoundURL); err != nil {
+			return err
+		}
+	}
+	if r.RootURL != "" {
+		if r.RootURL, err = policy.Normalize(r.RootURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateHost checks the host of a new domain. The default short domain can not
+// be registered as a custom domain.
+func validateHost(host, defaultHost string) error {
+	if len(host) > 253 || !hostPattern.MatchString(host) {
+		return &createError{Status: http.StatusBadRequest, Code: "domain_invalid", Message: "host must be a DNS name such as go.example.com"}
+	}
+	if defaultHost != "" && host == normalizeHost(defaultHost) {
+		return &createError{Status: http.StatusConflict, Code: "domain_taken", Message: "host is the default short domain"}
+	}
+	return nil
+}
+
+// newVerifyToken returns a random token for the TXT verification record.
+func newVerifyToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateDomain registers a custom domain owned by the caller, or by a namespace in
+// which the caller may "create domains". The domain serves links once it is verified.
+//
+// Until then it is only a claim: other users may claim the same host, and the
+// first claim to be verified gets it.
+func CreateDomain(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domainRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "host is required"})
+		return
+	}
+	req.Host = normalizeHost(req.Host)
+	if err := validateHost(req.Host, viper.GetString("redirect.host")); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+	if err := req.validateSettings(urlpolicy.FromConfig()); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+	if req.Namespace != "" && !authorizeCaller(c, "create", "domains", req.Namespace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	// unverified claims do not hold the host, it belongs to whoever verifies it first
+	claims, err := database.ListDomainClaims(req.Host)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to get domain")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	for _, claim := range claims {
+		if claim.VerifiedAt != nil {
+			respondInvalidRequest(c, &createError{Status: http.StatusConflict, Code: "domain_taken", Message: "domain is already registered"})
+			return
+		}
+		if claim.OwnerID == userID && claim.Namespace == req.Namespace {
+			respondInvalidRequest(c, &createError{Status: http.StatusConflict, Code: "domain_claimed", Message: "domain is already registered by you, verify it"})
+			return
+		}
+	}
+
+	token, err := newVerifyToken()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to create domain verification token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	domain := database.Domain{
+		Host:           req.Host,
+		OwnerID:        userID,
+		Namespace:      req.Namespace,
+		VerifyToken:    token,
+		RedirectStatus: req.RedirectStatus,
+		NotFoundURL:    req.NotFoundURL,
+		RootURL:        req.RootURL,
+	}
+	if err := database.CreateDomain(&domain); err != nil {
+		log.Warn().Err(err).Msg("Failed to create domain")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
+		return
+	}
+	c.JSON(http.StatusOK, newDomainInfo(domain))
+}
+
+// ListDomains lists the custom domains of the caller, or with ?namespace= the domains
+// of a namespace in which the caller may "get domains".
+func ListDomains(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	namespace := c.Query("namespace")
+	if namespace != "" && !authorizeCaller(c, "get", "domains", namespace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	domains, err := database.ListDomains(userID, namespace)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list domains")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list domains"})
+		return
+	}
+
+	infos := make([]DomainInfo, 0, len(domains))
+	for _, domain := range domains {
+		infos = append(infos, newDomainInfo(domain))
+	}
+	c.JSON(http.StatusOK, gin.H{"domains": infos})
+}
+
+// canUseDomain reports whether the caller may do verb on domain. The creator of
+// a user domain can do anything, namespace domains are checked with RBAC.
+func canUseDomain(c *gin.Context, domain database.Domain, verb string) bool {
+	if domain.Namespace == "" {
+		return domain.OwnerID == c.GetString("user_id")
+	}
+	return authorizeCaller(c, verb, "domains", domain.Namespace)
+}
+
+// loadDomain reads the domain of the ":id" path parameter and checks that the
+// caller may do verb on it. It responds and returns false otherwise.
+// Domains the caller can not see are reported as missing.
+func loadDomain(c *gin.Context, verb string) (database.Domain, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+		return database.Domain{}, false
+	}
+	domain, err := database.GetDomain(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !canUseDomain(c, domain, "get")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+		return database.Domain{}, false
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to get domain")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return database.Domain{}, false
+	}
+	if verb != "get" && !canUseDomain(c, domain, verb) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return database.Domain{}, false
+	}
+	return domain, true
+}
+
+// UpdateDomain replaces the per-domain defaults of a domain, the host can not change.
+func UpdateDomain(c *gin.Context) {
+	domain, ok := loadDomain(c, "update")
+	if !ok {
+		return
+	}
+
+	var req domainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if err := req.validateSettings(urlpolicy.FromConfig()); err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+
+	domain.RedirectStatus, domain.NotFoundURL, domain.RootURL = req.RedirectStatus, req.NotFoundURL, req.RootURL
+	if err := database.UpdateDomainSettings(domain); err != nil {
+		log.Warn().Err(err).Str("host", domain.Host).Msg("Failed to update domain")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
+		return
+	}
+	getDomainCache().forget(domain.Host)
+	c.JSON(http.StatusOK, newDomainInfo(domain))
+}
+
+// checkDomainRecord reports whether the verification record of domain is published.
+func checkDomainRecord(ctx context.Context, resolver TXTResolver, domain database.Domain) (bool, error) {
+	record := domainVerification(domain)
+	values, err := resolver.LookupTXT(ctx, record.Name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, v := range values {
+		if strings.TrimSpace(v) == record.Value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// VerifyDomain looks up the TXT verification record of a domain and marks the domain
+// verified when the record holds its token. Verified domains stay verified.
+func VerifyDomain(c *gin.Context) {
+	domain, ok := loadDomain(c, "update")
+	if !ok {
+		return
+	}
+	if domain.VerifiedAt != nil {
+		c.JSON(http.StatusOK, newDomainInfo(domain))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), domainVerifyTimeout)
+	defer cancel()
+	found, err := checkDomainRecord(ctx, txtResolver, domain)
+	if err != nil {
+		log.Warn().Err(err).Str("host", domain.Host).Msg("Failed to look up domain verification record")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "DNS lookup failed, try again later"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":         "verification_failed",
+			"error":        "verification record not found",
+			"verification": domainVerification(domain),
+		})
+		return
+	}
+
+	now := time.Now()
+	if err := database.MarkDomainVerified(domain, now); errors.Is(err, database.ErrDomainVerified) {
+		respondInvalidRequest(c, &createError{Status: http.StatusConflict, Code: "domain_taken", Message: "domain is already verified by another owner"})
+		return
+	} else if err != nil {
+		log.Warn().Err(err).Str("host", domain.Host).Msg("Failed to mark domain verified")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
+		return
+	}
+	domain.VerifiedAt = &now
+	getDomainCache().forget(domain.Host)
+	c.JSON(http.StatusOK, newDomainInfo(domain))
+}
+
+// DeleteDomain deletes a domain. Domains which still have links can not be deleted.
+func DeleteDomain(c *gin.Context) {
+	domain, ok := loadDomain(c, "delete")
+	if !ok {
+		return
+	}
+
+	count, err := database.CountDomainLinks(domain.ID)
+	if err != nil {
+		log.Warn().Err(err).Str("host", domain.Host).Msg("Failed to count domain links")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "domain still has links"})
+		return
+	}
+	if err := database.DeleteDomain(domain.ID); err != nil {
+		log.Warn().Err(err).Str("host", domain.Host).Msg("Failed to delete domain")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	getDomainCache().forget(domain.Host)
+	c.JSON(http.StatusOK, gin.H{"message": "domain deleted"})
+}
+
+// linkDomain returns the verified domain a new link of the caller is put on.
+func linkDomain(c *gin.Context, host string) (database.Domain, error) {
+	host = normalizeHost(host)
+	domain, err := database.GetVerifiedDomain(host)
+	if err == nil {
+		if !canUseDomain(c, domain, "update") {
+			return database.Domain{}, &createError{Status: http.StatusBadRequest, Code: "domain_invalid", Message: "domain not found"}
+		}
+		return domain, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return database.Domain{}, err
+	}
+
+	claims, err := database.ListDomainClaims(host)
+	if err != nil {
+		return database.Domain{}, err
+	}
+	for _, claim := range claims {
+		if canUseDomain(c, claim, "update") {
+			return database.Domain{}, &createError{Status: http.StatusBadRequest, Code: "domain_unverified", Message: "domain is not verified"}
+		}
+	}
+	return database.Domain{}, &createError{Status: http.StatusBadRequest, Code: "domain_invalid", Message: "domain not found"}
+}
+
+// checkDomainCodeAvailable returns an alias_taken error if code is already used on domain.
+func checkDomainCodeAvailable(domain database.Domain, code string) error {
+	inUse, err := database.DomainCodesInUse(domain.ID, []string{code})
+	if err != nil {
+		return err
+	}
+	if inUse[code] {
+		return &createError{Status: http.StatusConflict, Code: "alias_taken", Message: "alias is already taken on " + domain.Host}
+	}
+	return nil
+}
+
+// domainCacheEntry is the verified domain of a host, or its absence.
+type domainCacheEntry struct {
+	domain  database.Domain
+	found   bool
+	expires time.Time
+}
+
+// domainCache keeps the verified domains of request hosts for a short time, so
+// redirects do not query the database for the domain of every request.
+// Changes made on this replica invalidate it, the others see them after the TTL.
+type domainCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]domainCacheEntry
+}
+
+func newDomainCache(ttl time.Duration) *domainCache {
+	return &domainCache{ttl: ttl, entries: make(map[string]domainCacheEntry)}
+}
+
+// lookup returns the verified domain of host, from the cache or from load.
+func (d *domainCache) lookup(host string, now time.Time, load func(string) (database.Domain, error)) (database.Domain, bool, error) {
+	d.mu.Lock()
+	entry, ok := d.entries[host]
+	d.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.domain, entry.found, nil
+	}
+
+	domain, err := load(host)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return database.Domain{}, false, err
+	}
+	entry = domainCacheEntry{domain: domain, found: err == nil, expires: now.Add(d.ttl)}
+	d.mu.Lock()
+	if len(d.entries) >= maxDomainCacheEntries {
+		clear(d.entries)
+	}
+	d.entries[host] = entry
+	d.mu.Unlock()
+	return entry.domain, entry.found, nil
+}
+
+// forget drops host from the cache.
+func (d *domainCache) forget(host string) {
+	d.mu.Lock()
+	delete(d.entries, host)
+	d.mu.Unlock()
+}
+
+var (
+	domainCacheOnce sync.Once
+	verifiedDomains *domainCache
+)
+
+// getDomainCache returns the cache of verified domains, its TTL is
+// redirect.domain_cache_ttl in the config.
+func getDomainCache() *domainCache {
+	domainCacheOnce.Do(func() {
+		ttl := viper.GetDuration("redirect.domain_cache_ttl")
+		if ttl <= 0 {
+			ttl = defaultDomainCacheTTL
+		}
+		verifiedDomains = newDomainCache(ttl)
+	})
+	return verifiedDomains
+}
+
+// ResolveHost middleware, finds the short domain a redirect request was sent to.
+//
+// Requests for a verified custom domain carry that domain, see RequestDomain.
+// The domains are cached for redirect.domain_cache_ttl, see domainCache.
+// Any other request is served by the default domain. When defaultHost is set,
+// requests for other hosts get 404.
+func ResolveHost(defaultHost string) gin.HandlerFunc {
+	defaultHost = normalizeHost(defaultHost)
+	return func(c *gin.Context) {
+		host := requestHost(c)
+		if defaultHost != "" && host == defaultHost {
+			c.Next()
+			return
+		}
+
+		domain, found, err := getDomainCache().lookup(host, time.Now(), database.GetVerifiedDomain)
+		if err != nil {
+			log.Warn().Err(err).Str("host", host).Msg("Failed to get domain")
+		}
+		if found {
+			c.Set(domainKey, domain)
+			c.Next()
+			return
+		}
+		if defaultHost != "" {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequestDomain returns the custom domain the request was sent to, if any.
+func RequestDomain(c *gin.Context) (database.Domain, bool) {
+	v, ok := c.Get(domainKey)
+	if !ok {
+		return database.Domain{}, false
+	}
+	domain, ok := v.(database.Domain)
+	return domain, ok
+}
+
+// DomainNotFound sends the visitor of an unknown code to the 404 page of the custom
+// domain, and reports whether it did.
+func DomainNotFound(c *gin.Context) bool {
+	domain, ok := RequestDomain(c)
+	if !ok || domain.NotFoundURL == "" {
+		return false
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, domain.NotFoundURL)
+	return true
+}
+
+// DomainRoot sends the visitors of "/" on a custom domain to its root URL.
+// The default domain and domains without a root URL have nothing at "/".
+func DomainRoot(c *gin.Context) {
+	domain, ok := RequestDomain(c)
+	if !ok || domain.RootURL == "" {
+		RespondLinkError(c, http.StatusNotFound, "not found", nil)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, domain.RootURL)
+}
+
+// LinkURL returns the full short URL of link.
+//
+// Links on a custom domain are served at their domain code on that domain, the
+// others at their short code, see ShortLinkURL.
+func LinkURL(c *gin.Context, link database.Link) string {
+	shortURL, ok := link.(database.UserShortURL)
+	if !ok || shortURL.DomainID == 0 || shortURL.DomainCode == nil {
+		return ShortLinkURL(c, link.GetShortCode())
+	}
+
+	domain, ok := RequestDomain(c)
+	if !ok || domain.ID != shortURL.DomainID {
+		var err error
+		if domain, err = database.GetDomain(shortURL.DomainID); err != nil {
+			log.Warn().Err(err).Str("shortCode", shortURL.ShortCode).Msg("Failed to get domain of short URL")
+			return ShortLinkURL(c, link.GetShortCode())
+		}
+	}
+	return requestScheme(c) + "://" + domain.Host + "/" + *shortURL.DomainCode
+}
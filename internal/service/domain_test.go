@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+// stubResolver serves TXT records from a map instead of DNS.
+type stubResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (r stubResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	values, ok := r.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return values, nil
+}
+
+func TestValidateHost(t *testing.T) {
+	for _, host := range []string{"go.example.com", "a.b.example.co", "xn--bcher-kva.example"} {
+		if err := validateHost(host, "s.example.com"); err != nil {
+			t.Errorf("validateHost(%q) = %v", host, err)
+		}
+	}
+	for _, host := range []string{"", "localhost", "127.0.0.1", "go.example.com:8080", "-go.example.com", "go_.example.com", "s.example.com"} {
+		if err := validateHost(host, "S.Example.com"); err == nil {
+			t.Errorf("validateHost(%q) error = nil", host)
+		}
+	}
+}
+
+func TestCheckDomainRecord(t *testing.T) {
+	domain := database.Domain{Host: "go.example.com", VerifyToken: "token"}
+	name := "_url-shortener.go.example.com"
+
+	tests := []struct {
+		name     string
+		resolver stubResolver
+		want     bool
+		wantErr  bool
+	}{
+		{name: "published", resolver: stubResolver{records: map[string][]string{name: {"v=spf1 -all", "url-shortener-verification=token"}}}, want: true},
+		{name: "other token", resolver: stubResolver{records: map[string][]string{name: {"url-shortener-verification=other"}}}},
+		{name: "missing", resolver: stubResolver{}},
+		{name: "lookup failure", resolver: stubResolver{err: errors.New("timeout")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkDomainRecord(context.Background(), tt.resolver, domain)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Fatalf("checkDomainRecord() = %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestDomainDefaults(t *testing.T) {
+	domain := database.Domain{Host: "go.example.com", RedirectStatus: http.StatusMovedPermanently, RootURL: "https://www.example.com", NotFoundURL: "https://www.example.com/404"}
+
+	w := httptest.NewRecorder()
+	c := testContext(w, http.MethodGet, "http://go.example.com/", nil)
+	c.Set(domainKey, domain)
+	DomainRoot(c)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != domain.RootURL {
+		t.Fatalf("DomainRoot() = %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	c = testContext(w, http.MethodGet, "http://go.example.com/missing", nil)
+	c.Set(domainKey, domain)
+	if !DomainNotFound(c) || w.Header().Get("Location") != domain.NotFoundURL {
+		t.Fatalf("DomainNotFound() = %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// the link status wins over the domain default
+	link := database.UserShortURL{ShortCode: "abc123", OriginalURL: "https://www.example.com/a", ExpireAt: time.Now().Add(time.Hour)}
+	w = httptest.NewRecorder()
+	c = testContext(w, http.MethodGet, "http://go.example.com/spring", nil)
+	c.Set(domainKey, domain)
+	Redirect(c, link, link.OriginalURL)
+	if w.Code != http.StatusMovedPermanently {
+		t.Fatalf("expected domain status 301, got %d", w.Code)
+	}
+	link.RedirectStatus = http.StatusTemporaryRedirect
+	w = httptest.NewRecorder()
+	c = testContext(w, http.MethodGet, "http://go.example.com/spring", nil)
+	c.Set(domainKey, domain)
+	Redirect(c, link, link.OriginalURL)
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected link status 307, got %d", w.Code)
+	}
+}
+
+func TestDomainDefaultsWithoutDomain(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := testContext(w, http.MethodGet, "/", nil)
+	DomainRoot(c)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("DomainRoot() on the default domain = %d", w.Code)
+	}
+	if DomainNotFound(c) {
+		t.Fatal("DomainNotFound() on the default domain = true")
+	}
+}
+
+func TestLinkURL(t *testing.T) {
+	code := "spring"
+	domain := database.Domain{Host: "go.example.com"}
+	domain.ID = 7
+	link := database.UserShortURL{ShortCode: "abc123", DomainID: 7, DomainCode: &code}
+
+	c := testContext(httptest.NewRecorder(), http.MethodGet, "http://go.example.com/v1/auth/abc123/qr", http.Header{"X-Forwarded-Proto": {"https"}})
+	c.Set(domainKey, domain)
+	if got := LinkURL(c, link); got != "https://go.example.com/spring" {
+		t.Errorf("LinkURL(domain link) = %q", got)
+	}
+	if got := LinkURL(c, database.PublicShortURL{ShortCode: "abc123"}); got != "https://go.example.com/abc123" {
+		t.Errorf("LinkURL(public link) = %q", got)
+	}
+}
+
+func TestDomainCache(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	loads := 0
+	load := func(host string) (database.Domain, error) {
+		loads++
+		if host == "go.example.com" {
+			return database.Domain{Host: host}, nil
+		}
+		return database.Domain{}, gorm.ErrRecordNotFound
+	}
+	cache := newDomainCache(time.Minute)
+
+	for range 2 {
+		if domain, found, err := cache.lookup("go.example.com", now, load); err != nil || !found || domain.Host != "go.example.com" {
+			t.Fatalf("lookup() = %+v, %v, %v", domain, found, err)
+		}
+		// unknown hosts are cached too, they are most of the traffic without redirect.host
+		if _, found, err := cache.lookup("other.example.com", now, load); err != nil || found {
+			t.Fatalf("lookup(other) = %v, %v", found, err)
+		}
+	}
+	if loads != 2 {
+		t.Errorf("expected 2 loads, got %d", loads)
+	}
+
+	cache.forget("go.example.com")
+	cache.lookup("go.example.com", now, load)
+	cache.lookup("other.example.com", now.Add(2*time.Minute), load)
+	if loads != 4 {
+		t.Errorf("expected forgotten and expired hosts to be loaded again, got %d loads", loads)
+	}
+
+	if _, _, err := cache.lookup("down.example.com", now, func(string) (database.Domain, error) { return database.Domain{}, errors.New("down") }); err == nil {
+		t.Error("expected the load error")
+	}
+}
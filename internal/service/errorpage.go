@@ -0,0 +1,111 @@
+package service
+
+import (
+	"html/template"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/page"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// templateNamePattern matches the hosts and namespaces that can name a custom
+// error page, so they never escape the error page directory.
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+var (
+	errorPageDirOnce sync.Once
+	errorPageDir     *page.Dir
+)
+
+// getErrorPageDir returns the directory of custom error pages, error_pages.dir
+// in the config, or nil when it is not set.
+func getErrorPageDir() *page.Dir {
+	errorPageDirOnce.Do(func() {
+		if dir := viper.GetString("error_pages.dir"); dir != "" {
+			errorPageDir = page.NewDir(dir)
+		}
+	})
+	return errorPageDir
+}
+
+// LinkErrorPage is the data of the error pages of the redirect path.
+type LinkErrorPage struct {
+	Status    int
+	Title     string // 状态码对应的标题，如 "Not Found"
+	Message   string
+	Host      string // 请求的自定义域名，默认域名为空
+	ShortCode string
+}
+
+// errorTemplateNames returns the custom error pages to try, most specific first:
+// the page of the custom domain, of the namespace and the default page.
+func errorTemplateNames(host, namespace string) []string {
+	var names []string
+	if host != "" && templateNamePattern.MatchString(host) {
+		names = append(names, "domains/"+host+".html")
+	}
+	if namespace != "" && templateNamePattern.MatchString(namespace) {
+		names = append(names, "namespaces/"+namespace+".html")
+	}
+	return append(names, "default.html")
+}
+
+// errorTemplate returns the custom error page for the request, or nil.
+func errorTemplate(dir *page.Dir, host, namespace string) *template.Template {
+	if dir == nil {
+		return nil
+	}
+	for _, name := range errorTemplateNames(host, namespace) {
+		if tmpl, ok := dir.Lookup(name); ok {
+			return tmpl
+		}
+	}
+	return nil
+}
+
+// RespondLinkError reports a missing, expired or disabled short link.
+//
+// Browsers, which accept text/html, get an HTML page, other clients get
+// {"error": message}. Custom pages in error_pages.dir are picked by the custom
+// domain of the request, then by the namespace of link or of the domain. link is nil when the code is unknown, or
+// when the caller must not learn anything about the link.
+func RespondLinkError(c *gin.Context, status int, message string, link database.Link) {
+	c.Header("Cache-Control", "no-store")
+	if !wantsHTML(c) {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	data := LinkErrorPage{Status: status, Title: http.StatusText(status), Message: message, ShortCode: strings.TrimSuffix(c.Param("code"), previewSuffix)}
+	var namespace string
+	if domain, ok := RequestDomain(c); ok {
+		data.Host, namespace = domain.Host, domain.Namespace
+	}
+	if link != nil && link.GetOptions().Namespace != "" {
+		namespace = link.GetOptions().Namespace
+	}
+
+	if tmpl := errorTemplate(getErrorPageDir(), data.Host, namespace); tmpl != nil {
+		page.RenderTemplate(c, status, tmpl, data)
+		return
+	}
+	page.Render(c, status, "error.html", data)
+}
+
+// LinkExpired answers the visitor of an expired link. Links with an expired URL
+// send visitors there, the others get 410 Gone: unlike an unknown code, the link
+// existed and will not come back.
+func LinkExpired(c *gin.Context, link database.Link) {
+	if expiredURL := link.GetOptions().ExpiredURL; expiredURL != "" {
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, expiredURL)
+		return
+	}
+	RespondLinkError(c, http.StatusGone, "URL expired", link)
+}
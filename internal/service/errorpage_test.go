@@ -0,0 +1,95 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/page"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondLinkErrorNegotiation(t *testing.T) {
+	w := httptest.NewRecorder()
+	RespondLinkError(testContext(w, http.MethodGet, "/abc123", http.Header{"Accept": {"application/json"}}), http.StatusNotFound, "URL not found", nil)
+	if w.Code != http.StatusNotFound || w.Body.String() != `{"error":"URL not found"}` {
+		t.Fatalf("JSON error = %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	c := testContext(w, http.MethodGet, "/abc123", http.Header{"Accept": {"text/html,application/xhtml+xml,*/*;q=0.8"}})
+	c.Params = gin.Params{{Key: "code", Value: "abc123"}}
+	RespondLinkError(c, http.StatusGone, "URL disabled", nil)
+	if w.Code != http.StatusGone || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("HTML error = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "Gone") || !strings.Contains(w.Body.String(), "abc123") {
+		t.Fatalf("unexpected HTML error page: %s", w.Body.String())
+	}
+	// API clients often send no Accept header or only */*, they get JSON too
+	for _, accept := range []string{"", "*/*", "text/html;q=0, */*"} {
+		w = httptest.NewRecorder()
+		RespondLinkError(testContext(w, http.MethodGet, "/abc123", http.Header{"Accept": {accept}}), http.StatusNotFound, "URL not found", nil)
+		if w.Body.String() != `{"error":"URL not found"}` {
+			t.Errorf("Accept %q: error = %s", accept, w.Body.String())
+		}
+	}
+}
+
+func TestErrorTemplate(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("default.html", "default")
+	write("namespaces/marketing.html", "marketing")
+	write("domains/go.example.com.html", "domain {{.Status}}")
+	dir := page.NewDir(root)
+
+	tests := []struct {
+		host, namespace, want string
+	}{
+		{host: "go.example.com", namespace: "marketing", want: "domain"},
+		{host: "other.example.com", namespace: "marketing", want: "marketing"},
+		{namespace: "sales", want: "default"},
+		{namespace: "../marketing", want: "default"},
+	}
+	for _, tt := range tests {
+		tmpl := errorTemplate(dir, tt.host, tt.namespace)
+		if tmpl == nil {
+			t.Fatalf("errorTemplate(%q, %q) = nil", tt.host, tt.namespace)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, LinkErrorPage{Status: http.StatusNotFound}); err != nil || !strings.HasPrefix(b.String(), tt.want) {
+			t.Errorf("errorTemplate(%q, %q) rendered %q, %v", tt.host, tt.namespace, b.String(), err)
+		}
+	}
+	if errorTemplate(nil, "go.example.com", "") != nil {
+		t.Error("errorTemplate without a directory is not nil")
+	}
+}
+
+func TestLinkExpired(t *testing.T) {
+	link := database.PublicShortURL{ShortCode: "abc123", LinkOptions: database.LinkOptions{ExpiredURL: "https://www.example.com/over"}}
+	w := httptest.NewRecorder()
+	LinkExpired(testContext(w, http.MethodGet, "/abc123", nil), link)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != link.ExpiredURL {
+		t.Fatalf("LinkExpired() = %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	LinkExpired(testContext(w, http.MethodGet, "/abc123", http.Header{"Accept": {"application/json"}}), database.PublicShortURL{ShortCode: "abc123"})
+	if w.Code != http.StatusGone {
+		t.Fatalf("LinkExpired() without expired URL = %d", w.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/page"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// Link statuses of the activation window, reported by the listings.
+const (
+	StatusScheduled = "scheduled" // active_from is still ahead
+	StatusActive    = "active"
+	StatusExpired   = "expired"
+)
+
+// maxSlidingTTL is the longest sliding TTL of a link, in seconds (5 years).
+const maxSlidingTTL = 5 * 365 * 24 * 60 * 60
+
+// validateSlidingTTL checks the sliding expiration of a create request.
+// max_expire_at only caps a sliding TTL, so it can not be used alone.
+func validateSlidingTTL(ttl int64, expireAt, maxExpireAt *time.Time, now time.Time) error {
+	if ttl < 0 || ttl > maxSlidingTTL {
+		return &createError{Status: http.StatusBadRequest, Code: "invalid_expiry", Message: fmt.Sprintf("sliding_ttl must be between 0 and %d seconds", maxSlidingTTL)}
+	}
+	if maxExpireAt == nil {
+		return nil
+	}
+	if ttl == 0 {
+		return &createError{Status: http.StatusBadRequest, Code: "invalid_expiry", Message: "max_expire_at requires sliding_ttl"}
+	}
+	if !maxExpireAt.After(now) {
+		return &createError{Status: http.StatusBadRequest, Code: "invalid_expiry", Message: "max_expire_at must be in the future"}
+	}
+	if expireAt != nil && expireAt.After(*maxExpireAt) {
+		return &createError{Status: http.StatusBadRequest, Code: "invalid_expiry", Message: "expire_at must not be after max_expire_at"}
+	}
+	return nil
+}
+
+// validateActiveFrom checks that the activation window of a create request is not empty.
+func validateActiveFrom(activeFrom, expireAt, maxExpireAt *time.Time) error {
+	if activeFrom == nil {
+		return nil
+	}
+	for _, end := range []*time.Time{expireAt, maxExpireAt} {
+		if end != nil && !activeFrom.Before(*end) {
+			return &createError{Status: http.StatusBadRequest, Code: "invalid_expiry", Message: "active_from must be before the expiration"}
+		}
+	}
+	return nil
+}
+
+// LinkStatus returns where now falls in the activation window of link, which
+// starts at its active_from and ends at its expiration.
+func LinkStatus(link database.Link, now time.Time) string {
+	if activeFrom := link.GetOptions().ActiveFrom; activeFrom != nil && now.Before(*activeFrom) {
+		return StatusScheduled
+	}
+	if link.GetExpireAt().Before(now) {
+		return StatusExpired
+	}
+	return StatusActive
+}
+
+// LinkScheduled answers the visitor of a link that is not active yet. Links with a
+// pre-launch URL send visitors there, the others get the "coming soon" response.
+//
+// Its status is activation.status in the config, 404 by default. The activation
+// time is only disclosed when activation.reveal_time is true.
+func LinkScheduled(c *gin.Context, link database.Link) {
+	opts := link.GetOptions()
+	c.Header("Cache-Control", "no-store")
+	if opts.PrelaunchURL != "" {
+		c.Redirect(http.StatusFound, opts.PrelaunchURL)
+		return
+	}
+
+	status := viper.GetInt("activation.status")
+	if status < http.StatusOK || status > 599 {
+		status = http.StatusNotFound
+	}
+	var activeFrom *time.Time
+	if viper.GetBool("activation.reveal_time") {
+		activeFrom = opts.ActiveFrom
+	}
+
+	if !wantsHTML(c) {
+		resp := gin.H{"error": "URL not active yet"}
+		if activeFrom != nil {
+			resp["active_from"] = activeFrom
+		}
+		c.JSON(status, resp)
+		return
+	}
+	page.Render(c, status, "comingsoon.html", gin.H{"ActiveFrom": activeFrom})
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/database"
+)
+
+func TestValidateSlidingTTL(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := now.Add(24 * time.Hour)
+	week := now.Add(7 * 24 * time.Hour)
+	past := now.Add(-time.Hour)
+
+	tests := []struct {
+		name                  string
+		ttl                   int64
+		expireAt, maxExpireAt *time.Time
+		wantErr               bool
+	}{
+		{name: "disabled"},
+		{name: "ttl", ttl: 3600},
+		{name: "ttl with max", ttl: 3600, expireAt: &day, maxExpireAt: &week},
+		{name: "negative", ttl: -1, wantErr: true},
+		{name: "too long", ttl: maxSlidingTTL + 1, wantErr: true},
+		{name: "max without ttl", maxExpireAt: &week, wantErr: true},
+		{name: "max in the past", ttl: 3600, maxExpireAt: &past, wantErr: true},
+		{name: "expire after max", ttl: 3600, expireAt: &week, maxExpireAt: &day, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSlidingTTL(tt.ttl, tt.expireAt, tt.maxExpireAt, now); (err != nil) != tt.wantErr {
+				t.Fatalf("validateSlidingTTL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSlidingExpireAt(t *testing.T) {
+	req := shortURLRequest{SlidingTTL: 3600}
+	if got := time.Until(req.expireAt()); got < 59*time.Minute || got > time.Hour {
+		t.Fatalf("expireAt() with sliding TTL is %v from now", got)
+	}
+
+	max := time.Now().Add(time.Minute).Truncate(time.Second)
+	req.MaxExpireAt = &max
+	if got := req.expireAt(); !got.Equal(max) {
+		t.Fatalf("expireAt() = %v, want the max %v", got, max)
+	}
+}
+
+func TestValidateActiveFrom(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := now.Add(24 * time.Hour)
+	week := now.Add(7 * 24 * time.Hour)
+
+	if err := validateActiveFrom(nil, &day, nil); err != nil {
+		t.Errorf("validateActiveFrom(nil) = %v", err)
+	}
+	if err := validateActiveFrom(&day, &week, nil); err != nil {
+		t.Errorf("validateActiveFrom(day, week) = %v", err)
+	}
+	if err := validateActiveFrom(&week, &day, nil); err == nil {
+		t.Error("validateActiveFrom after expire_at error = nil")
+	}
+	if err := validateActiveFrom(&week, nil, &week); err == nil {
+		t.Error("validateActiveFrom at max_expire_at error = nil")
+	}
+}
+
+func TestLinkStatus(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		link database.PublicShortURL
+		want string
+	}{
+		{name: "active", link: database.PublicShortURL{ExpiresAt: later}, want: StatusActive},
+		{name: "started", link: database.PublicShortURL{ExpiresAt: later, LinkOptions: database.LinkOptions{ActiveFrom: &earlier}}, want: StatusActive},
+		{name: "scheduled", link: database.PublicShortURL{ExpiresAt: later.Add(time.Hour), LinkOptions: database.LinkOptions{ActiveFrom: &later}}, want: StatusScheduled},
+		{name: "expired", link: database.PublicShortURL{ExpiresAt: earlier}, want: StatusExpired},
+	}
+	for _, tt := range tests {
+		if got := LinkStatus(tt.link, now); got != tt.want {
+			t.Errorf("%s: LinkStatus() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLinkScheduled(t *testing.T) {
+	activeFrom := time.Now().Add(time.Hour)
+	link := database.PublicShortURL{ShortCode: "abc123", LinkOptions: database.LinkOptions{ActiveFrom: &activeFrom, PrelaunchURL: "https://www.example.com/soon"}}
+	w := httptest.NewRecorder()
+	LinkScheduled(testContext(w, http.MethodGet, "/abc123", nil), link)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != link.PrelaunchURL {
+		t.Fatalf("LinkScheduled() = %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	link.PrelaunchURL = ""
+	w = httptest.NewRecorder()
+	LinkScheduled(testContext(w, http.MethodGet, "/abc123", http.Header{"Accept": {"application/json"}}), link)
+	if w.Code != http.StatusNotFound || w.Body.String() != `{"error":"URL not active yet"}` {
+		t.Fatalf("LinkScheduled() without pre-launch URL = %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	LinkScheduled(testContext(w, http.MethodGet, "/abc123", http.Header{"Accept": {"text/html"}}), link)
+	if w.Code != http.StatusNotFound || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("LinkScheduled() page = %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+}
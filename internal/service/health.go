@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/healthcheck"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// healthStore connects the health checker to the database.
+type healthStore struct{}
+
+func (healthStore) DueTargets(checkedBefore time.Time, limit int) ([]healthcheck.Target, error) {
+	links, err := database.GetLinksDueForHealthCheck(checkedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]healthcheck.Target, 0, len(links))
+	for _, link := range links {
+		targets = append(targets, healthcheck.Target{
+			ShortCode: link.GetShortCode(),
+			URL:       link.GetOriginalURL(),
+			OwnerID:   link.GetOwnerID(),
+			WasBroken: link.GetHealth().Broken,
+		})
+	}
+	return targets, nil
+}
+
+func (healthStore) SaveResult(result healthcheck.Result) error {
+	checkedAt := result.CheckedAt
+	health := database.LinkHealth{
+		HealthStatus:    result.StatusCode,
+		HealthLatencyMs: result.Latency.Milliseconds(),
+		HealthCheckedAt: &checkedAt,
+		Broken:          result.Broken(),
+	}
+	if result.Err != nil {
+		health.HealthError = truncate(result.Err.Error(), 255)
+	}
+	return database.SaveLinkHealth(result.ShortCode, health)
+}
+
+// truncate cuts s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// StartHealthChecker probes link destinations in the background until ctx is done.
+// It is configured by the health_check section of the config and does nothing
+// unless health_check.enabled is true.
+func StartHealthChecker(ctx context.Context) {
+	if !viper.GetBool("health_check.enabled") {
+		log.Info().Msg("Health checker disabled")
+		return
+	}
+
+	checker := &healthcheck.Checker{
+		Concurrency: viper.GetInt("health_check.concurrency"),
+		Timeout:     viper.GetDuration("health_check.timeout"),
+		HostDelay:   viper.GetDuration("health_check.host_delay"),
+		Interval:    viper.GetDuration("health_check.interval"),
+		BatchSize:   viper.GetInt("health_check.batch_size"),
+		Store:       healthStore{},
+	}
+	if webhook := viper.GetString("health_check.notify_webhook"); webhook != "" {
+		checker.Notifier = healthcheck.WebhookNotifier{URL: webhook}
+	}
+
+	go checker.Run(ctx)
+	log.Info().Msg("Health checker started")
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"time"
+
+	"url-shortener/internal/pkg/database"
+)
+
+// LinkSummary is one entry of a detailed link listing.
+type LinkSummary struct {
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	Title       string    `json:"title,omitempty"`
+	Visibility  string    `json:"visibility,omitempty"`
+	AccessCount uint      `json:"access_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpireAt    time.Time `json:"expire_at"`
+	Disabled    bool      `json:"disabled,omitempty"`
+	// Status is scheduled, active or expired, see LinkStatus.
+	Status     string     `json:"status"`
+	ActiveFrom *time.Time `json:"active_from,omitempty"`
+	// RateLimit caps the redirects per second, with bursts of RateBurst.
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	RateBurst int     `json:"rate_burst,omitempty"`
+
+	// Destination health, reported by the health checker.
+	Broken          bool       `json:"broken"`
+	HealthStatus    int        `json:"health_status,omitempty"`
+	HealthLatencyMs int64      `json:"health_latency_ms,omitempty"`
+	HealthCheckedAt *time.Time `json:"health_checked_at,omitempty"`
+
+	// Destination page, fetched when the link is created.
+	PageTitle string `json:"page_title,omitempty"`
+	Favicon   string `json:"favicon,omitempty"`
+}
+
+// Summarize builds the listing entry of link at time now.
+func Summarize(link database.Link, now time.Time) LinkSummary {
+	opts, health, meta := link.GetOptions(), link.GetHealth(), link.GetMetadata()
+	return LinkSummary{
+		ShortURL:    link.GetShortCode(),
+		OriginalURL: link.GetOriginalURL(),
+		Title:       opts.Title,
+		Visibility:  linkVisibility(link),
+		AccessCount: link.GetAccessCount(),
+		CreatedAt:   link.GetCreatedAt(),
+		ExpireAt:    link.GetExpireAt(),
+		Disabled:    opts.Disabled,
+		Status:      LinkStatus(link, now),
+		ActiveFrom:  opts.ActiveFrom,
+		RateLimit:   opts.RateLimit,
+		RateBurst:   opts.RateBurst,
+
+		Broken:          health.Broken,
+		HealthStatus:    health.HealthStatus,
+		HealthLatencyMs: health.HealthLatencyMs,
+		HealthCheckedAt: health.HealthCheckedAt,
+
+		PageTitle: meta.PageTitle,
+		Favicon:   meta.FaviconURL,
+	}
+}
+
+// SummarizeLinks builds the listing entries of the active links. Scheduled links
+// are left out as well, so a launch is not disclosed before its time.
+func SummarizeLinks[L database.Link](links []L) []LinkSummary {
+	now := time.Now()
+	summaries := make([]LinkSummary, 0, len(links))
+	for _, link := range links {
+		if LinkStatus(link, now) != StatusActive {
+			continue
+		}
+		summaries = append(summaries, Summarize(link, now))
+	}
+	return summaries
+}
+
+// SummarizeAllLinks builds the listing entries of all links, with their status.
+func SummarizeAllLinks[L database.Link](links []L) []LinkSummary {
+	now := time.Now()
+	summaries := make([]LinkSummary, 0, len(links))
+	for _, link := range links {
+		summaries = append(summaries, Summarize(link, now))
+	}
+	return summaries
+}
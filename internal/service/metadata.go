@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/metadata"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultMetadataWorkers   = 2
+	defaultMetadataQueueSize = 1000
+)
+
+// PageMetadata is the metadata of a link destination page.
+type PageMetadata struct {
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Image       string     `json:"image,omitempty"`
+	Favicon     string     `json:"favicon,omitempty"`
+	FetchedAt   *time.Time `json:"fetched_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+func newPageMetadata(meta database.LinkMetadata) PageMetadata {
+	return PageMetadata{
+		Title:       meta.PageTitle,
+		Description: meta.PageDescription,
+		Image:       meta.PageImage,
+		Favicon:     meta.FaviconURL,
+		FetchedAt:   meta.MetadataAt,
+		Error:       meta.MetadataError,
+	}
+}
+
+type metadataJob struct {
+	shortCode string
+	url       string
+}
+
+var (
+	// metadataQueue feeds the background fetchers, nil when they are disabled.
+	metadataQueue chan metadataJob
+
+	metadataFetcherOnce sync.Once
+	metadataFetcher     *metadata.Fetcher
+)
+
+// getMetadataFetcher returns the fetcher configured by the metadata section of the config.
+func getMetadataFetcher() *metadata.Fetcher {
+	metadataFetcherOnce.Do(func() {
+		metadataFetcher = &metadata.Fetcher{
+			Timeout:      viper.GetDuration("metadata.timeout"),
+			MaxBytes:     viper.GetInt64("metadata.max_bytes"),
+			MaxRedirects: viper.GetInt("metadata.max_redirects"),
+		}
+	})
+	return metadataFetcher
+}
+
+// StartMetadataFetcher fetches the metadata of new link destinations in the
+// background until ctx is done. It does nothing unless metadata.enabled is true.
+func StartMetadataFetcher(ctx context.Context) {
+	if !viper.GetBool("metadata.enabled") {
+		log.Info().Msg("Metadata fetcher disabled")
+		return
+	}
+
+	workers := viper.GetInt("metadata.workers")
+	if workers <= 0 {
+		workers = defaultMetadataWorkers
+	}
+	size := viper.GetInt("metadata.queue_size")
+	if size <= 0 {
+		size = defaultMetadataQueueSize
+	}
+
+	metadataQueue = make(chan metadataJob, size)
+	for range workers {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-metadataQueue:
+					if _, err := fetchMetadata(ctx, job.shortCode, job.url); err != nil {
+						log.Warn().Err(err).Str("shortCode", job.shortCode).Msg("Failed to save link metadata")
+					}
+				}
+			}
+		}()
+	}
+	log.Info().Int("workers", workers).Msg("Metadata fetcher started")
+}
+
+// enqueueMetadata schedules a metadata fetch of the destination of shortCode.
+// When the queue is full the fetch is dropped, it can be refreshed on demand later.
+func enqueueMetadata(shortCode, url string) {
+	if metadataQueue == nil {
+		return
+	}
+	select {
+	case metadataQueue <- metadataJob{shortCode: shortCode, url: url}:
+	default:
+		log.Warn().Str("shortCode", shortCode).Msg("Metadata queue is full, skipping fetch")
+	}
+}
+
+// fetchMetadata fetches the destination page and stores its metadata on the link.
+// A failed fetch is stored as well, the returned error is only about saving it.
+func fetchMetadata(ctx context.Context, shortCode, url string) (database.LinkMetadata, error) {
+	now := time.Now()
+	meta := database.LinkMetadata{MetadataAt: &now}
+
+	page, err := getMetadataFetcher().Fetch(ctx, url)
+	if err != nil {
+		log.Debug().Err(err).Str("shortCode", shortCode).Msg("Failed to fetch link metadata")
+		meta.MetadataError = truncate(err.Error(), 255)
+	} else {
+		meta.PageTitle = truncateRunes(page.Title, 255)
+		meta.PageDescription = truncateRunes(page.Description, 512)
+		meta.PageImage = page.Image
+		meta.FaviconURL = page.Favicon
+	}
+	return meta, database.SaveLinkMetadata(shortCode, meta)
+}
+
+// truncateRunes cuts s to at most n characters.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// RefreshMetadata fetches the metadata of the destination of link now and responds with it.
+func RefreshMetadata(c *gin.Context, link database.Link) {
+	meta, err := fetchMetadata(c.Request.Context(), link.GetShortCode(), link.GetOriginalURL())
+	if err != nil {
+		log.Warn().Err(err).Str("shortCode", link.GetShortCode()).Msg("Failed to save link metadata")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save metadata"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"short_url": link.GetShortCode(),
+		"metadata":  newPageMetadata(meta),
+	})
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/page"
+
+	"github.com/gin-gonic/gin"
+)
+
+// previewSuffix is appended to a short code to inspect it instead of following it.
+const previewSuffix = "+"
+
+// LinkPreview describes where a short URL goes.
+type LinkPreview struct {
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	Title       string    `json:"title,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpireAt    time.Time `json:"expire_at"`
+}
+
+// ParsePreview returns the short code of the request and whether the caller
+// asked to inspect the link, either with a trailing "+" or with
+// "Accept: application/json".
+func ParsePreview(c *gin.Context) (string, bool) {
+	code := c.Param("code")
+	if trimmed, ok := strings.CutSuffix(code, previewSuffix); ok {
+		return trimmed, true
+	}
+	return code, wantsJSON(c)
+}
+
+// wantsJSON reports whether the client prefers JSON over HTML. Clients without
+// an Accept header follow the link instead of asking for its preview.
+func wantsJSON(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON
+}
+
+// wantsHTML reports whether the client explicitly accepts HTML, as browsers do.
+// Clients without an Accept header or with only */* get JSON.
+func wantsHTML(c *gin.Context) bool {
+	for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(accepted, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if mediaType != gin.MIMEHTML && mediaType != "application/xhtml+xml" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok && strings.Trim(q, "0.") == "" {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// newLinkPreview builds the preview of link.
+func newLinkPreview(link database.Link) LinkPreview {
+	return LinkPreview{
+		ShortURL:    link.GetShortCode(),
+		OriginalURL: link.GetOriginalURL(),
+		Title:       link.GetOptions().Title,
+		CreatedAt:   link.GetCreatedAt(),
+		ExpireAt:    link.GetExpireAt(),
+	}
+}
+
+// Preview responds with the destination and details of link instead of redirecting.
+// API clients get JSON, browsers get an HTML page.
+func Preview(c *gin.Context, link database.Link) {
+	preview := newLinkPreview(link)
+	c.Header("Cache-Control", "no-store")
+	if !wantsHTML(c) {
+		c.JSON(http.StatusOK, preview)
+		return
+	}
+	page.Render(c, http.StatusOK, "preview.html", preview)
+}
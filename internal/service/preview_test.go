@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPreview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	link := database.PublicShortURL{
+		ShortCode:   "abc123",
+		OriginalURL: "https://www.example.com",
+		ExpiresAt:   time.Now().Add(time.Hour),
+		LinkOptions: database.LinkOptions{Title: "Example"},
+	}
+
+	r := gin.New()
+	r.GET("/:code", func(c *gin.Context) {
+		code, preview := ParsePreview(c)
+		if !preview {
+			Redirect(c, link, link.OriginalURL)
+			return
+		}
+		if code != link.ShortCode {
+			t.Errorf("expected code %s, got %s", link.ShortCode, code)
+		}
+		Preview(c, link)
+	})
+
+	t.Run("plus suffix renders html", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abc123+", nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "https://www.example.com") {
+			t.Errorf("expected preview page, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("accept json", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var got LinkPreview
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid preview JSON: %v", err)
+		}
+		if got.OriginalURL != link.OriginalURL || got.Title != "Example" {
+			t.Errorf("unexpected preview: %+v", got)
+		}
+	})
+
+	t.Run("browser is redirected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusFound {
+			t.Errorf("expected redirect, got %d", w.Code)
+		}
+	})
+
+	t.Run("interstitial", func(t *testing.T) {
+		untrusted := link
+		untrusted.Interstitial = true
+		w := serveRedirect(untrusted, untrusted.OriginalURL, nil)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Continue to the destination") {
+			t.Errorf("expected interstitial page, got %d", w.Code)
+		}
+	})
+}
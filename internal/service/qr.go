@@ -0,0 +1,226 @@
+package service
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/qrcode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	qrMinSize          = 64
+	qrMaxSize          = 2048
+	qrMaxMargin        = 16
+	qrCacheMaxAge      = 24 * 60 * 60 // QR 图片的 HTTP 缓存时间（秒）
+	defaultQRCacheSize = 512
+)
+
+// qrRequest is the rendering requested by the QR code query parameters.
+type qrRequest struct {
+	format string
+	level  qrcode.Level
+	opts   qrcode.RenderOptions
+}
+
+// key identifies the rendered image of content.
+func (r qrRequest) key(content string) string {
+	return fmt.Sprintf("%s|%s|%v|%d|%d|%v|%v", content, r.format, r.level, r.opts.Size, r.opts.Margin, r.opts.Foreground, r.opts.Background)
+}
+
+// parseQRRequest reads the QR code query parameters:
+//
+//	format: png (default) or svg
+//	size:   image width in pixels, 64 to 2048, default 256
+//	level:  error correction level L, M (default), Q or H
+//	margin: quiet zone in modules, 0 to 16, default 4
+//	fg, bg: hex colors, default 000000 and ffffff
+func parseQRRequest(c *gin.Context) (qrRequest, error) {
+	req := qrRequest{format: c.DefaultQuery("format", "png"), level: qrcode.Medium, opts: qrcode.DefaultRenderOptions()}
+	if req.format != "png" && req.format != "svg" {
+		return req, fmt.Errorf("format must be png or svg")
+	}
+
+	var err error
+	if s := c.Query("size"); s != "" {
+		if req.opts.Size, err = strconv.Atoi(s); err != nil || req.opts.Size < qrMinSize || req.opts.Size > qrMaxSize {
+			return req, fmt.Errorf("size must be between %d and %d", qrMinSize, qrMaxSize)
+		}
+	}
+	if s := c.Query("level"); s != "" {
+		if req.level, err = qrcode.ParseLevel(s); err != nil {
+			return req, fmt.Errorf("level must be one of L, M, Q, H")
+		}
+	}
+	if s := c.Query("margin"); s != "" {
+		if req.opts.Margin, err = strconv.Atoi(s); err != nil || req.opts.Margin < 0 || req.opts.Margin > qrMaxMargin {
+			return req, fmt.Errorf("margin must be between 0 and %d", qrMaxMargin)
+		}
+	}
+	if s := c.Query("fg"); s != "" {
+		if req.opts.Foreground, err = qrcode.ParseColor(s); err != nil {
+			return req, err
+		}
+	}
+	if s := c.Query("bg"); s != "" {
+		if req.opts.Background, err = qrcode.ParseColor(s); err != nil {
+			return req, err
+		}
+	}
+	return req, nil
+}
+
+// render encodes content and renders it in the requested format.
+func (r qrRequest) render(content string) ([]byte, error) {
+	code, err := qrcode.Encode([]byte(content), r.level)
+	if err != nil {
+		return nil, err
+	}
+	if r.format == "svg" {
+		return code.SVG(r.opts), nil
+	}
+	return code.PNG(r.opts)
+}
+
+// ShortLinkURL returns the full short URL of shortCode, served by GET /:code.
+//
+// The base is redirect.base_url when set, otherwise it is built from the request,
+// using redirect.host as the host when set.
+func ShortLinkURL(c *gin.Context, shortCode string) string {
+	if base := viper.GetString("redirect.base_url"); base != "" {
+		return strings.TrimRight(base, "/") + "/" + shortCode
+	}
+
+	host := viper.GetString("redirect.host")
+	if host == "" {
+		host = c.Request.Host
+	}
+	return requestScheme(c) + "://" + host + "/" + shortCode
+}
+
+// requestScheme returns the scheme the request was sent with, behind a proxy the
+// one of X-Forwarded-Proto.
+func requestScheme(c *gin.Context) string {
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		return proto
+	}
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// QRCode renders the full short URL of link as a PNG or SVG QR code,
+// see parseQRRequest for the query parameters.
+//
+// Rendered images are kept in an in-memory LRU cache of qr.cache_size entries and
+// may be cached by clients for a day, revalidated with an ETag. Shared caches must
+// not keep them: the encoded URL may come from the Host and X-Forwarded-Proto
+// headers, and user links are only served to their owner.
+func QRCode(c *gin.Context, link database.Link) {
+	req, err := parseQRRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	content := LinkURL(c, link)
+	key := req.key(content)
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	etag := fmt.Sprintf(`"%x"`, h.Sum64())
+
+	contentType := "image/png"
+	if req.format == "svg" {
+		contentType = "image/svg+xml"
+	}
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", qrCacheMaxAge))
+	c.Header("Vary", "Host, X-Forwarded-Proto")
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	cache := getQRCache()
+	image, ok := cache.get(key)
+	if !ok {
+		if image, err = req.render(content); err != nil {
+			log.Warn().Err(err).Str("shortCode", link.GetShortCode()).Msg("Failed to render QR code")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render QR code"})
+			return
+		}
+		cache.add(key, image)
+	}
+	c.Data(http.StatusOK, contentType, image)
+}
+
+var (
+	qrCacheOnce sync.Once
+	qrCache     *lruCache
+)
+
+func getQRCache() *lruCache {
+	qrCacheOnce.Do(func() {
+		size := viper.GetInt("qr.cache_size")
+		if size <= 0 {
+			size = defaultQRCacheSize
+		}
+		qrCache = newLRUCache(size)
+	})
+	return qrCache
+}
+
+// lruCache is a fixed size, concurrency safe least recently used cache.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // 最近使用的在前
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *lruCache) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+func (l *lruCache) add(key string, value []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		e.Value.(*lruEntry).value = value
+		l.order.MoveToFront(e)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, value: value})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url-shortener/internal/pkg/database"
+)
+
+func serveQRCode(target string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c := testContext(w, http.MethodGet, target, header)
+	QRCode(c, database.PublicShortURL{ShortCode: "abc123", OriginalURL: "https://www.example.com"})
+	return w
+}
+
+func TestQRCode(t *testing.T) {
+	w := serveQRCode("/v1/public/abc123/qr?size=200&level=H&fg=%23112233", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("expected PNG, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("invalid PNG: %v", err)
+	}
+	if img.Bounds().Dx() != 200 {
+		t.Errorf("expected 200px wide image, got %d", img.Bounds().Dx())
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" || !strings.HasPrefix(w.Header().Get("Cache-Control"), "private") || w.Header().Get("Vary") == "" {
+		t.Fatalf("expected cache headers, got %v", w.Header())
+	}
+	w = serveQRCode("/v1/public/abc123/qr?size=200&level=H&fg=%23112233", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for matching ETag, got %d", w.Code)
+	}
+
+	w = serveQRCode("/v1/public/abc123/qr?format=svg&margin=0", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/svg+xml" || !strings.HasPrefix(w.Body.String(), "<svg") {
+		t.Errorf("expected SVG, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("expected a different ETag for different options")
+	}
+}
+
+func TestQRCodeInvalidOptions(t *testing.T) {
+	for _, query := range []string{"format=gif", "size=10", "size=x", "level=Z", "margin=-1", "fg=red"} {
+		if w := serveQRCode("/v1/public/abc123/qr?"+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestShortLinkURL(t *testing.T) {
+	c := testContext(httptest.NewRecorder(), http.MethodGet, "http://s.example.com/v1/public/abc/qr", http.Header{"X-Forwarded-Proto": {"https"}})
+	if got := ShortLinkURL(c, "abc"); got != "https://s.example.com/abc" {
+		t.Errorf("ShortLinkURL() = %q", got)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := newLRUCache(2)
+	cache.add("a", []byte("1"))
+	cache.add("b", []byte("2"))
+	cache.get("a")
+	cache.add("c", []byte("3"))
+	if _, ok := cache.get("b"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if v, ok := cache.get("a"); !ok || string(v) != "1" {
+		t.Error("expected recently used entry to be kept")
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/page"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// validRedirectStatus reports whether status can be used to redirect a short URL.
+func validRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// redirectStatus returns the redirect status code of a link.
+//
+// A link without its own status uses redirect.default_status from the config,
+// and 302 if that is not set or not valid either.
+func redirectStatus(opts database.LinkOptions) int {
+	if validRedirectStatus(opts.RedirectStatus) {
+		return opts.RedirectStatus
+	}
+	if status := viper.GetInt("redirect.default_status"); validRedirectStatus(status) {
+		return status
+	}
+	return http.StatusFound
+}
+
+// maxCacheAge caps the max-age of permanent redirects to one year.
+const maxCacheAge = 365 * 24 * 60 * 60
+
+// isPermanentRedirect reports whether clients are allowed to remember the redirect.
+func isPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+// setCacheHeaders sets the caching headers of a redirect response of link.
+//
+// Permanent redirects may be cached until the link expires. Mutable redirects
+// must not be stored, otherwise a changed destination would never be seen, and
+// neither must redirects granted by a signed URL, which may be revoked, or to a
+// variant, which is picked per visitor. Capped links are not stored either, a
+// cached redirect would bypass their rate limit. Links with app links send mobile visitors
+// elsewhere, so only the browser may keep their redirect.
+func setCacheHeaders(c *gin.Context, status int, link database.Link) {
+	if !isPermanentRedirect(status) || c.GetBool(signedAccessKey) || c.GetBool(variantKey) || link.GetOptions().RateLimit > 0 {
+		c.Header("Cache-Control", "no-store")
+		return
+	}
+
+	expireAt := link.GetExpireAt()
+	maxAge := int(time.Until(expireAt).Seconds())
+	if expireAt.IsZero() || maxAge < 0 {
+		maxAge = 0
+	}
+	// links that practically never expire, such as imported ones, are kept a year
+	maxAge = min(maxAge, maxCacheAge)
+	scope := "public"
+	if opts := link.GetOptions(); opts.IOSURL != "" || opts.AndroidURL != "" {
+		scope = "private"
+		c.Header("Vary", "User-Agent")
+	}
+	c.Header("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, maxAge))
+	c.Header("Expires", time.Now().Add(time.Duration(maxAge)*time.Second).UTC().Format(http.TimeFormat))
+}
+
+// Redirect sends the visitor of link to destination.
+//
+// Links flagged as interstitial show the preview page with a continue link instead.
+// The status code comes from the link, its custom domain or the global default,
+// and the caching headers follow from it.
+// Mobile visitors are routed to the app link of their platform when the link has one.
+// A custom app scheme is opened from a small HTML page which falls back to the
+// fallback URL (or destination) when the app is not installed.
+func Redirect(c *gin.Context, link database.Link, destination string) {
+	opts := link.GetOptions()
+
+	// untrusted destinations always go through the preview page first
+	if opts.Interstitial {
+		preview := newLinkPreview(link)
+		preview.OriginalURL = destination
+		c.Header("Cache-Control", "no-store")
+		page.Render(c, http.StatusOK, "preview.html", preview)
+		return
+	}
+
+	if appURL := appLinkFor(opts, c.Request.UserAgent()); appURL != "" {
+		// app links depend on the User-Agent, so they are never cached
+		setCacheHeaders(c, http.StatusFound, link)
+		if isWebURL(appURL) {
+			c.Redirect(http.StatusFound, appURL)
+			return
+		}
+
+		fallback := opts.FallbackURL
+		if fallback == "" {
+			fallback = destination
+		}
+		page.Render(c, http.StatusOK, "deeplink.html", gin.H{
+			"AppURL":      appURL,
+			"FallbackURL": fallback,
+		})
+		return
+	}
+
+	// links without their own status use the default of their custom domain
+	if domain, ok := RequestDomain(c); ok && opts.RedirectStatus == 0 {
+		opts.RedirectStatus = domain.RedirectStatus
+	}
+	status := redirectStatus(opts)
+	setCacheHeaders(c, status, link)
+	if hasSocialCard(opts) {
+		// crawlers get the social card instead, a shared cache must not mix them up
+		c.Header("Vary", "User-Agent")
+	}
+	c.Redirect(status, destination)
+}
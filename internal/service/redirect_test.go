@@ -0,0 +1,157 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/database"
+)
+
+const (
+	iphoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36"
+	desktopUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+)
+
+// serveRedirect runs Redirect for link in a test gin context.
+func serveRedirect(link database.Link, destination string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	Redirect(testContext(w, http.MethodGet, "/"+link.GetShortCode(), header), link, destination)
+	return w
+}
+
+func TestRedirectDeepLink(t *testing.T) {
+	link := database.PublicShortURL{
+		ShortCode:   "abc123",
+		OriginalURL: "https://www.example.com",
+		LinkOptions: database.LinkOptions{
+			IOSURL:      "myapp://item/1",
+			AndroidURL:  "https://app.example.com/item/1",
+			FallbackURL: "https://www.example.com/download",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		userAgent  string
+		wantStatus int
+		wantTarget string
+	}{
+		{name: "desktop", userAgent: desktopUA, wantStatus: http.StatusFound, wantTarget: "https://www.example.com"},
+		{name: "android app link", userAgent: androidUA, wantStatus: http.StatusFound, wantTarget: "https://app.example.com/item/1"},
+		{name: "ios scheme page", userAgent: iphoneUA, wantStatus: http.StatusOK, wantTarget: "myapp://item/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRedirect(link, link.OriginalURL, http.Header{"User-Agent": {tt.userAgent}})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus == http.StatusFound && w.Header().Get("Location") != tt.wantTarget {
+				t.Errorf("expected location %s, got %s", tt.wantTarget, w.Header().Get("Location"))
+			}
+			if tt.wantStatus == http.StatusOK {
+				body := w.Body.String()
+				if !strings.Contains(body, tt.wantTarget) || !strings.Contains(body, link.FallbackURL) {
+					t.Errorf("deep-link page misses app or fallback URL: %s", body)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateAppLink(t *testing.T) {
+	for _, appURL := range []string{"myapp://item/1", "fb-messenger://share", "intent://item/1#Intent;scheme=myapp;end"} {
+		if err := validateAppLink(appURL); err != nil {
+			t.Errorf("validateAppLink(%q) = %v", appURL, err)
+		}
+	}
+	for _, appURL := range []string{"javascript:alert(1)", "JavaScript:alert(1)", "data:text/html,<script>alert(1)</script>",
+		"vbscript:msgbox(1)", "file:///etc/passwd", "java\tscript:alert(1)", "item/1", ""} {
+		if err := validateAppLink(appURL); err == nil {
+			t.Errorf("validateAppLink(%q) = nil", appURL)
+		}
+	}
+}
+
+func TestRedirectUnsafeAppLink(t *testing.T) {
+	// rows stored before app links were validated must not reach the deep-link page
+	link := database.PublicShortURL{
+		ShortCode:   "abc123",
+		OriginalURL: "https://www.example.com",
+		LinkOptions: database.LinkOptions{IOSURL: "javascript:alert(1)"},
+	}
+	w := serveRedirect(link, link.OriginalURL, http.Header{"User-Agent": {iphoneUA}})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != link.OriginalURL {
+		t.Fatalf("expected redirect to %s, got %d %q", link.OriginalURL, w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestRedirectStatusAndCache(t *testing.T) {
+	expireAt := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus int
+		wantCache  string
+	}{
+		{name: "default", status: 0, wantStatus: http.StatusFound, wantCache: "no-store"},
+		{name: "temporary", status: http.StatusTemporaryRedirect, wantStatus: http.StatusTemporaryRedirect, wantCache: "no-store"},
+		{name: "permanent", status: http.StatusMovedPermanently, wantStatus: http.StatusMovedPermanently, wantCache: "public, max-age="},
+		{name: "permanent keep method", status: http.StatusPermanentRedirect, wantStatus: http.StatusPermanentRedirect, wantCache: "public, max-age="},
+		{name: "invalid falls back", status: http.StatusOK, wantStatus: http.StatusFound, wantCache: "no-store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := database.UserShortURL{
+				ShortCode:   "abc123",
+				OriginalURL: "https://www.example.com",
+				ExpireAt:    expireAt,
+				LinkOptions: database.LinkOptions{RedirectStatus: tt.status},
+			}
+			w := serveRedirect(link, link.OriginalURL, http.Header{"User-Agent": {desktopUA}})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if cache := w.Header().Get("Cache-Control"); !strings.HasPrefix(cache, tt.wantCache) {
+				t.Errorf("expected Cache-Control %q, got %q", tt.wantCache, cache)
+			}
+			if isPermanentRedirect(tt.wantStatus) && w.Header().Get("Expires") == "" {
+				t.Error("expected Expires header on permanent redirect")
+			}
+		})
+	}
+}
+
+func TestRedirectCachePerVisitor(t *testing.T) {
+	link := database.UserShortURL{
+		ShortCode:   "abc123",
+		OriginalURL: "https://www.example.com",
+		ExpireAt:    time.Now().Add(24 * time.Hour),
+		LinkOptions: database.LinkOptions{RedirectStatus: http.StatusMovedPermanently, IOSURL: "myapp://item/1"},
+	}
+	// desktop visitors of a link with app links may only be cached by their browser
+	w := serveRedirect(link, link.OriginalURL, http.Header{"User-Agent": {desktopUA}})
+	if cache := w.Header().Get("Cache-Control"); !strings.HasPrefix(cache, "private, max-age=") || w.Header().Get("Vary") != "User-Agent" {
+		t.Errorf("app link: Cache-Control %q, Vary %q", cache, w.Header().Get("Vary"))
+	}
+
+	// a variant is picked per visitor and never cached
+	w = httptest.NewRecorder()
+	c := testContext(w, http.MethodGet, "/abc123", nil)
+	c.Set(variantKey, true)
+	link.IOSURL = ""
+	Redirect(c, link, "https://b.example.com")
+	if cache := w.Header().Get("Cache-Control"); cache != "no-store" {
+		t.Errorf("variant: Cache-Control %q", cache)
+	}
+	// a capped link must reach the server on every visit to enforce its rate limit
+	link.RateLimit = 10
+	w = serveRedirect(link, link.OriginalURL, nil)
+	if cache := w.Header().Get("Cache-Control"); w.Code != http.StatusMovedPermanently || cache != "no-store" {
+		t.Errorf("capped link: %d, Cache-Control %q", w.Code, cache)
+	}
+}
@@ -2,14 +2,295 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/urlpolicy"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
 
+// defaultLinkTTL is how long a short URL lives when the request sets no expiration.
+const defaultLinkTTL = 90 * 24 * time.Hour
+
+// shortURLRequest is the request body shared by the user and public creators.
+type shortURLRequest struct {
+	LongURL string `json:"long_url"`
+	// Destinations splits traffic between several weighted URLs (A/B testing).
+	Destinations []destinationRequest `json:"destinations,omitempty"`
+
+	// App deep links, chosen from the visitor's User-Agent.
+	IOSURL      string `json:"ios_url,omitempty"`
+	AndroidURL  string `json:"android_url,omitempty"`
+	FallbackURL string `json:"fallback_url,omitempty"`
+
+	// RedirectStatus is one of 301, 302, 307 or 308. Zero uses the global default.
+	RedirectStatus int `json:"redirect_status,omitempty"`
+
+	// Title is shown on the preview page, Interstitial always shows that page first.
+	Title        string `json:"title,omitempty"`
+	Interstitial bool   `json:"interstitial,omitempty"`
+
+	// Visibility is one of public, unlisted, private or namespace. User links
+	// default to private and public short URLs to public.
+	Visibility string `json:"visibility,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+
+	// Alias is a custom short code, only logged in users can choose one.
+	Alias string `json:"alias,omitempty"`
+	// Domain is the host of a verified custom domain the link is served on.
+	// The alias, or else the generated code, is its code on that domain.
+	Domain string `json:"domain,omitempty"`
+	// ExpireAt overrides the default 90 days expiration.
+	ExpireAt *time.Time `json:"expire_at,omitempty"`
+	// SlidingTTL, in seconds, pushes the expiration to at least now+ttl on every
+	// redirect, but never past MaxExpireAt.
+	SlidingTTL  int64      `json:"sliding_ttl,omitempty"`
+	MaxExpireAt *time.Time `json:"max_expire_at,omitempty"`
+	// ActiveFrom schedules the link, before it visitors are sent to PrelaunchURL or
+	// get a "coming soon" response.
+	ActiveFrom   *time.Time `json:"active_from,omitempty"`
+	PrelaunchURL string     `json:"prelaunch_url,omitempty"`
+	// RateLimit caps the redirects per second, with bursts of RateBurst. Visitors
+	// over the cap are sent to RateLimitURL, or get 429 without one.
+	RateLimit    float64 `json:"rate_limit,omitempty"`
+	RateBurst    int     `json:"rate_burst,omitempty"`
+	RateLimitURL string  `json:"rate_limit_url,omitempty"`
+	// ExpiredURL receives the visitors once the link has expired.
+	ExpiredURL string   `json:"expired_url,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+
+	// Open Graph overrides, served to link preview crawlers instead of a redirect.
+	OGTitle       string `json:"og_title,omitempty"`
+	OGDescription string `json:"og_description,omitempty"`
+	OGImage       string `json:"og_image,omitempty"`
+
+	// UTM parameters merged into the destination at redirect time.
+	UTMSource   string `json:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty"`
+	UTMTerm     string `json:"utm_term,omitempty"`
+	UTMContent  string `json:"utm_content,omitempty"`
+	// Passthrough forwards the query string of the short URL request to the destination.
+	Passthrough bool `json:"passthrough,omitempty"`
+}
+
+// options converts the request into the link options stored with the short URL.
+func (r shortURLRequest) options() database.LinkOptions {
+	return database.LinkOptions{
+		IOSURL:      r.IOSURL,
+		AndroidURL:  r.AndroidURL,
+		FallbackURL: r.FallbackURL,
+
+		RedirectStatus: r.RedirectStatus,
+
+		Title:        r.Title,
+		Interstitial: r.Interstitial,
+
+		Visibility: r.Visibility,
+		Namespace:  r.Namespace,
+
+		Tags: strings.Join(r.Tags, ","),
+
+		ExpiredURL:  r.ExpiredURL,
+		SlidingTTL:  r.SlidingTTL,
+		MaxExpireAt: r.MaxExpireAt,
+
+		ActiveFrom:   r.ActiveFrom,
+		PrelaunchURL: r.PrelaunchURL,
+
+		RateLimit:    r.RateLimit,
+		RateBurst:    r.RateBurst,
+		RateLimitURL: r.RateLimitURL,
+
+		OGTitle:       r.OGTitle,
+		OGDescription: r.OGDescription,
+		OGImage:       r.OGImage,
+
+		UTMSource:   r.UTMSource,
+		UTMMedium:   r.UTMMedium,
+		UTMCampaign: r.UTMCampaign,
+		UTMTerm:     r.UTMTerm,
+		UTMContent:  r.UTMContent,
+		Passthrough: r.Passthrough,
+	}
+}
+
+// expireAt returns the requested expiration, or the default 90 days from activation.
+// Links with a sliding TTL start with one TTL, capped by their max_expire_at.
+func (r shortURLRequest) expireAt() time.Time {
+	if r.ExpireAt != nil {
+		return *r.ExpireAt
+	}
+	start := time.Now()
+	if r.ActiveFrom != nil && r.ActiveFrom.After(start) {
+		start = *r.ActiveFrom
+	}
+	if r.SlidingTTL > 0 {
+		expireAt := start.Add(time.Duration(r.SlidingTTL) * time.Second)
+		if r.MaxExpireAt != nil && expireAt.After(*r.MaxExpireAt) {
+			return *r.MaxExpireAt
+		}
+		return expireAt
+	}
+	return start.Add(defaultLinkTTL)
+}
+
+// bindShortURLRequest binds and checks the create request body.
+// Anonymous requests create public short URLs, which have no owner.
+//
+// When only destinations are given, the first one becomes the long URL.
+func bindShortURLRequest(c *gin.Context, anonymous bool) (shortURLRequest, error) {
+	var req shortURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return req, err
+	}
+	if err := req.validate(c.Request.Context(), anonymous); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// validate checks and normalizes a create request.
+// It does not check whether the alias is already taken.
+func (r *shortURLRequest) validate(ctx context.Context, anonymous bool) error {
+	if err := validateVisibility(r.Visibility, r.Namespace, anonymous); err != nil {
+		return err
+	}
+	if err := validateDestinations(r.Destinations); err != nil {
+		return err
+	}
+	if r.RedirectStatus != 0 && !validRedirectStatus(r.RedirectStatus) {
+		return errors.New("redirect status must be one of 301, 302, 307, 308")
+	}
+	if r.Alias != "" {
+		if anonymous {
+			return &createError{Status: http.StatusBadRequest, Code: "alias_invalid", Message: "public short URLs can not have an alias"}
+		}
+		if err := validateAlias(r.Alias); err != nil {
+			return err
+		}
+	}
+	if r.Domain != "" && anonymous {
+		return &createError{Status: http.StatusBadRequest, Code: "domain_invalid", Message: "public short URLs can not use a custom domain"}
+	}
+	if r.ExpireAt != nil && !r.ExpireAt.After(time.Now()) {
+		return &createError{Status: http.StatusBadRequest, Code: "invalid_expiry", Message: "expire_at must be in the future"}
+	}
+	if err := validateSlidingTTL(r.SlidingTTL, r.ExpireAt, r.MaxExpireAt, time.Now()); err != nil {
+		return err
+	}
+	if err := validateActiveFrom(r.ActiveFrom, r.ExpireAt, r.MaxExpireAt); err != nil {
+		return err
+	}
+	if err := r.validateRateLimit(); err != nil {
+		return err
+	}
+	if err := validateTags(r.Tags); err != nil {
+		return err
+	}
+	if err := validateSocialCard(r.OGTitle, r.OGDescription); err != nil {
+		return err
+	}
+	if err := validateUTM(r.UTMSource, r.UTMMedium, r.UTMCampaign, r.UTMTerm, r.UTMContent); err != nil {
+		return err
+	}
+	if r.LongURL == "" && len(r.Destinations) > 0 {
+		r.LongURL = r.Destinations[0].URL
+	}
+	if err := r.normalizeURLs(urlpolicy.FromConfig()); err != nil {
+		return err
+	}
+	return r.checkURLs(ctx)
+}
+
+// normalizeURLs validates and normalizes every destination of the request.
+// Web app links follow the URL policy, custom scheme app links must not use a
+// scheme that runs code, see validateAppLink.
+func (r *shortURLRequest) normalizeURLs(policy urlpolicy.Policy) error {
+	var err error
+	if r.LongURL, err = policy.Normalize(r.LongURL); err != nil {
+		return err
+	}
+	for _, appURL := range []*string{&r.IOSURL, &r.AndroidURL} {
+		if *appURL == "" {
+			continue
+		}
+		if !isWebURL(*appURL) {
+			if err := validateAppLink(*appURL); err != nil {
+				return err
+			}
+			continue
+		}
+		if *appURL, err = policy.Normalize(*appURL); err != nil {
+			return err
+		}
+	}
+	for i := range r.Destinations {
+		if r.Destinations[i].URL, err = policy.Normalize(r.Destinations[i].URL); err != nil {
+			return err
+		}
+	}
+	if r.FallbackURL != "" {
+		if r.FallbackURL, err = policy.Normalize(r.FallbackURL); err != nil {
+			return err
+		}
+	}
+	if r.ExpiredURL != "" {
+		if r.ExpiredURL, err = policy.Normalize(r.ExpiredURL); err != nil {
+			return err
+		}
+	}
+	if r.PrelaunchURL != "" {
+		if r.PrelaunchURL, err = policy.Normalize(r.PrelaunchURL); err != nil {
+			return err
+		}
+	}
+	if r.RateLimitURL != "" {
+		if r.RateLimitURL, err = policy.Normalize(r.RateLimitURL); err != nil {
+			return err
+		}
+	}
+	if r.OGImage != "" {
+		if r.OGImage, err = policy.Normalize(r.OGImage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respondInvalidRequest reports a rejected create request.
+// URL policy, URL checker and alias/quota rejections carry their specific error code.
+func respondInvalidRequest(c *gin.Context, err error) {
+	var policyErr *urlpolicy.Error
+	if errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, policyErr)
+		return
+	}
+	var createErr *createError
+	if errors.As(err, &createErr) {
+		c.JSON(createErr.Status, createErr)
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+}
+
+// respondCreateFailure reports an alias, quota or rate limit rejection,
+// anything else is an internal error.
+func respondCreateFailure(c *gin.Context, err error) {
+	var createErr *createError
+	if errors.As(err, &createErr) {
+		c.JSON(createErr.Status, createErr)
+		return
+	}
+	log.Warn().Err(err).Msg("Failed to create short URL")
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create short URL"})
+}
+
 // UserShortCodeCreater creates a shorter code, integrating Snowflake and Base62,
 // and stores it in the database.
 // This is a private API, so user ID is needed.
@@ -27,7 +308,10 @@ import (
 //	    "short_url": "abc123"
 //	}
 //
-// The short URL will expire in 90 days. This is default expiration time.
+// The short URL will expire in 90 days unless "expire_at" is set. This is default expiration time.
+//
+// With "domain", the link is served on that verified custom domain at its alias, or
+// at its generated code. The response then carries "domain" and the full "link".
 func UserShortCodeCreater(c *gin.Context) {
 	userID, exist := c.Get("user_id")
 	if !exist {
@@ -39,20 +323,10 @@ func UserShortCodeCreater(c *gin.Context) {
 
 	// email := c.GetHeader("email")
 
-	var req struct {
-		LongURL string `json:"long_url"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Err(err).Msg("Invalid long URL request")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
-		return
-	}
-
-	// 生成短链（Base62 编码），Snowflake 算法确保唯一性，不用去重
-	shortCode, err := createShortURL()
+	req, err := bindShortURLRequest(c, false)
 	if err != nil {
-		log.Err(err).Msg("Failed to create short URL")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create short URL"})
+		log.Err(err).Msg("Invalid long URL request")
+		respondInvalidRequest(c, err)
 		return
 	}
 
@@ -62,11 +336,60 @@ func UserShortCodeCreater(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
-	if err := database.CreateUserShortURL(database.UserShortURL{UserID: userIDStr, ShortCode: shortCode, OriginalURL: req.LongURL, ExpireAt: time.Now().Add(90 * 24 * time.Hour)}, c.ClientIP()); err != nil {
+	if err := checkQuota(userIDStr, 1); err != nil {
+		respondCreateFailure(c, err)
+		return
+	}
+
+	shortURL := database.UserShortURL{UserID: userIDStr, OriginalURL: req.LongURL, ExpireAt: req.expireAt(), LinkOptions: req.options()}
+	var domain database.Domain
+	if req.Domain != "" {
+		// 自定义域名上的别名只需在该域名内唯一，短码本身仍然自动生成
+		if domain, err = linkDomain(c, req.Domain); err != nil {
+			respondCreateFailure(c, err)
+			return
+		}
+		if shortURL.ShortCode, err = createShortURL(); err != nil {
+			log.Err(err).Msg("Failed to create short URL")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create short URL"})
+			return
+		}
+		domainCode := req.Alias
+		if domainCode == "" {
+			domainCode = shortURL.ShortCode
+		}
+		if err := checkDomainCodeAvailable(domain, domainCode); err != nil {
+			respondCreateFailure(c, err)
+			return
+		}
+		shortURL.DomainID, shortURL.DomainCode = domain.ID, &domainCode
+	} else if req.Alias != "" {
+		if err := checkAliasesAvailable([]string{req.Alias}); err != nil {
+			respondCreateFailure(c, err)
+			return
+		}
+		shortURL.ShortCode = req.Alias
+	} else {
+		// 生成短链（Base62 编码），Snowflake 算法确保唯一性，不用去重
+		if shortURL.ShortCode, err = createShortURL(); err != nil {
+			log.Err(err).Msg("Failed to create short URL")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create short URL"})
+			return
+		}
+	}
+	shortCode := shortURL.ShortCode
+
+	if err := database.CreateUserShortURL(shortURL, c.ClientIP()); err != nil {
 		log.Warn().Err(err).Msg("Failed to create short URL")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
 		return
 	}
+	if err := saveVariants(shortCode, req.Destinations); err != nil {
+		log.Warn().Err(err).Msg("Failed to save link variants")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
+		return
+	}
+	enqueueMetadata(shortCode, req.LongURL)
 
 	// 未启用，缓存到 Redis（过期时间 24h）
 	// if err := cache.SetURL(shortCode, req.LongURL); err != nil {
@@ -74,10 +397,15 @@ func UserShortCodeCreater(c *gin.Context) {
 	// 	c.JSON(http.StatusInternalServerError, gin.H{"error": "cache failed"})
 	// }
 
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"original_url": req.LongURL,
 		"short_url":    shortCode,
-	})
+	}
+	if shortURL.DomainID != 0 {
+		resp["domain"] = domain.Host
+		resp["link"] = requestScheme(c) + "://" + domain.Host + "/" + *shortURL.DomainCode
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // PublicShortCodeCreater creates a public short code, integrating Snowflake and Base62,
@@ -97,14 +425,19 @@ func UserShortCodeCreater(c *gin.Context) {
 //	    "short_url": "abc123"
 //	}
 //
-// The short URL will expire in 90 days.This is default expiration time.
+// The short URL will expire in 90 days unless "expire_at" is set. This is default expiration time.
+// Requests are first checked by the anti-abuse guard of anonymous creation.
 func PublicShortCodeCreater(c *gin.Context) {
-	var req struct {
-		LongURL string `json:"long_url"`
+	if err := getAnonymousGuard().check(c); err != nil {
+		log.Warn().Err(err).Str("IP", c.ClientIP()).Msg("Anonymous create request rejected")
+		respondInvalidRequest(c, err)
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
+
+	req, err := bindShortURLRequest(c, true)
+	if err != nil {
 		log.Err(err).Msg("Invalid long URL request")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		respondInvalidRequest(c, err)
 		return
 	}
 
@@ -123,11 +456,17 @@ func PublicShortCodeCreater(c *gin.Context) {
 		return
 	}
 
-	if err := database.CreatePublicShortURL(database.PublicShortURL{ShortCode: shortCode, OriginalURL: req.LongURL, ExpiresAt: time.Now().Add(90 * 24 * time.Hour)}); err != nil {
+	if err := database.CreatePublicShortURL(database.PublicShortURL{ShortCode: shortCode, OriginalURL: req.LongURL, ExpiresAt: req.expireAt(), LinkOptions: req.options()}); err != nil {
 		log.Warn().Err(err).Msg("Failed to create public short URL")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
 		return
 	}
+	if err := saveVariants(shortCode, req.Destinations); err != nil {
+		log.Warn().Err(err).Msg("Failed to save link variants")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save failed"})
+		return
+	}
+	enqueueMetadata(shortCode, req.LongURL)
 
 	c.JSON(http.StatusOK, gin.H{
 		"original_url": req.LongURL,
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// Query parameters of a signed URL.
+const (
+	signedExpiresParam = "sig_expires" // 过期时间，Unix 秒
+	signedNonceParam   = "sig_nonce"
+	signatureParam     = "sig"
+)
+
+// signedAccessKey is the context key set when a signed URL granted the access.
+const signedAccessKey = "signed_access"
+
+// signedNonceKey is the context key of the nonce of the signed URL in use.
+const signedNonceKey = "signed_nonce"
+
+const (
+	defaultSignedTTL = 24 * time.Hour
+	maxSignedTTL     = 30 * 24 * time.Hour
+)
+
+// Statuses of a signed URL, reported by the listing.
+const (
+	SignedActive  = "active"
+	SignedExpired = "expired"
+	SignedUsedUp  = "used_up"
+	SignedRevoked = "revoked"
+)
+
+// signingKey returns the HMAC key of signed URLs, signed_urls.key in the config.
+// Signed URLs are disabled when it is empty.
+func signingKey() []byte {
+	return []byte(viper.GetString("signed_urls.key"))
+}
+
+// signedMaxTTL returns how long a signed URL may live, signed_urls.max_ttl in the config.
+func signedMaxTTL() time.Duration {
+	if ttl := viper.GetDuration("signed_urls.max_ttl"); ttl > 0 {
+		return ttl
+	}
+	return maxSignedTTL
+}
+
+// sign returns the signature of a signed URL, the HMAC-SHA256 of the short code,
+// the expiration and the nonce.
+func sign(key []byte, shortCode string, expires int64, nonce string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(shortCode + "\n" + strconv.FormatInt(expires, 10) + "\n" + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks the signature parameters of query for shortCode and
+// returns the nonce they carry.
+func verifySignature(key []byte, shortCode string, query url.Values, now time.Time) (string, bool) {
+	if len(key) == 0 {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(query.Get(signedExpiresParam), 10, 64)
+	if err != nil || !now.Before(time.Unix(expires, 0)) {
+		return "", false
+	}
+	nonce := query.Get(signedNonceParam)
+	if nonce == "" {
+		return "", false
+	}
+	want := sign(key, shortCode, expires, nonce)
+	if !hmac.Equal([]byte(want), []byte(query.Get(signatureParam))) {
+		return "", false
+	}
+	return nonce, true
+}
+
+// isSignatureParam reports whether key is a query parameter of signed URLs, which
+// is never passed through to the destination.
+func isSignatureParam(key string) bool {
+	return key == signedExpiresParam || key == signedNonceParam || key == signatureParam
+}
+
+// SignedAccess reports whether the request carries a valid signed URL for link.
+//
+// The signature is checked first, then the stored signed URL, which must not be
+// revoked or used up. The use is only counted by UseSignedAccess, once the
+// request is about to be redirected.
+func SignedAccess(c *gin.Context, link database.Link) bool {
+	query := c.Request.URL.Query()
+	if !query.Has(signatureParam) {
+		return false
+	}
+	now := time.Now()
+	nonce, ok := verifySignature(signingKey(), link.GetShortCode(), query, now)
+	if !ok {
+		log.Warn().Str("shortCode", link.GetShortCode()).Msg("Invalid URL signature")
+		return false
+	}
+
+	signed, err := database.GetSignedURL(link.GetShortCode(), nonce)
+	if err != nil || signedURLStatus(signed, now) != SignedActive {
+		return false
+	}
+	c.Set(signedAccessKey, true)
+	c.Set(signedNonceKey, nonce)
+	return true
+}
+
+// UseSignedAccess counts the redirect of a request let in by SignedAccess as one
+// use of its signed URL. It reports false when the signed URL has been used up,
+// revoked or has expired since. HEAD requests and other accesses are not counted.
+func UseSignedAccess(c *gin.Context, link database.Link) bool {
+	if !c.GetBool(signedAccessKey) || c.Request.Method == http.MethodHead {
+		return true
+	}
+	ok, err := database.UseSignedURL(link.GetShortCode(), c.GetString(signedNonceKey), time.Now())
+	if err != nil {
+		log.Warn().Err(err).Str("shortCode", link.GetShortCode()).Msg("Failed to use signed URL")
+		return false
+	}
+	return ok
+}
+
+// signedURLStatus returns whether signed can still be used at time now.
+func signedURLStatus(signed database.SignedURL, now time.Time) string {
+	switch {
+	case signed.RevokedAt != nil:
+		return SignedRevoked
+	case !now.Before(signed.ExpiresAt):
+		return SignedExpired
+	case signed.MaxUses > 0 && signed.Uses >= signed.MaxUses:
+		return SignedUsedUp
+	default:
+		return SignedActive
+	}
+}
+
+// SignedURLInfo is the listing entry of a signed URL. The URL itself is only
+// returned when it is minted.
+type SignedURLInfo struct {
+	Nonce     string     `json:"nonce"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	MaxUses   uint       `json:"max_uses"`
+	Uses      uint       `json:"uses"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Status    string     `json:"status"`
+}
+
+// signedURLRequest is the body of a request minting a signed URL. The signed URL
+// expires at expires_at, or ttl seconds from now, one day by default.
+type signedURLRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	TTL       int64      `json:"ttl"`
+	MaxUses   uint       `json:"max_uses"`
+}
+
+// expiresAt returns when the requested signed URL expires.
+func (r signedURLRequest) expiresAt(now time.Time) (time.Time, error) {
+	expiresAt := now.Add(defaultSignedTTL)
+	switch {
+	case r.ExpiresAt != nil && r.TTL != 0:
+		return time.Time{}, &createError{Status: http.StatusBadRequest, Code: "invalid_expiry", Message: "set either expires_at or ttl"}
+	case r.ExpiresAt != nil:
+		expiresAt = *r.ExpiresAt
+	case r.TTL != 0:
+		expiresAt = now.Add(time.Duration(r.TTL) * time.Second)
+	}
+	if !expiresAt.After(now) {
+		return time.Time{}, &createError{Status: http.StatusBadRequest, Code: "invalid_expiry", Message: "the signed URL must expire in the future"}
+	}
+	if maxTTL := signedMaxTTL(); expiresAt.Sub(now) > maxTTL {
+		return time.Time{}, &createError{Status: http.StatusBadRequest, Code: "invalid_expiry", Message: "the signed URL must expire within " + maxTTL.String()}
+	}
+	return expiresAt, nil
+}
+
+// CreateSignedURL mints a signed URL for link, which gives access to it without
+// an account until it expires, is used up or is revoked.
+func CreateSignedURL(c *gin.Context, link database.Link) {
+	key := signingKey()
+	if len(key) == 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "signed URLs are not enabled"})
+		return
+	}
+
+	var req signedURLRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+	}
+	now := time.Now()
+	expiresAt, err := req.expiresAt(now)
+	if err != nil {
+		respondInvalidRequest(c, err)
+		return
+	}
+	// the signature covers whole seconds only
+	expiresAt = expiresAt.Truncate(time.Second)
+	if link.GetExpireAt().Before(expiresAt) {
+		expiresAt = link.GetExpireAt().Truncate(time.Second)
+	}
+
+	nonce, err := newVerifyToken()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to create signed URL nonce")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	signed := database.SignedURL{
+		Nonce:     nonce,
+		ShortCode: link.GetShortCode(),
+		OwnerID:   c.GetString("user_id"),
+		ExpiresAt: expiresAt,
+		MaxUses:   req.MaxUses,
+	}
+	if err := database.CreateSignedURL(&signed); err != nil {
+		log.Warn().Err(err).Str("shortCode", link.GetShortCode()).Msg("Failed to save signed URL")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create signed URL"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"short_url":  link.GetShortCode(),
+		"url":        signedLinkURL(LinkURL(c, link), key, link.GetShortCode(), expiresAt.Unix(), nonce),
+		"nonce":      nonce,
+		"expires_at": expiresAt,
+		"max_uses":   signed.MaxUses,
+	})
+}
+
+// signedLinkURL appends the signature parameters to the short URL linkURL.
+func signedLinkURL(linkURL string, key []byte, shortCode string, expires int64, nonce string) string {
+	query := url.Values{}
+	query.Set(signedExpiresParam, strconv.FormatInt(expires, 10))
+	query.Set(signedNonceParam, nonce)
+	query.Set(signatureParam, sign(key, shortCode, expires, nonce))
+	sep := "?"
+	if strings.Contains(linkURL, "?") {
+		sep = "&"
+	}
+	return linkURL + sep + query.Encode()
+}
+
+// ListSignedURLs lists the signed URLs minted for link.
+func ListSignedURLs(c *gin.Context, link database.Link) {
+	signed, err := database.ListSignedURLs(link.GetShortCode())
+	if err != nil {
+		log.Warn().Err(err).Str("shortCode", link.GetShortCode()).Msg("Failed to list signed URLs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list signed URLs"})
+		return
+	}
+	now := time.Now()
+	infos := make([]SignedURLInfo, 0, len(signed))
+	for _, s := range signed {
+		infos = append(infos, SignedURLInfo{
+			Nonce:     s.Nonce,
+			ExpiresAt: s.ExpiresAt,
+			MaxUses:   s.MaxUses,
+			Uses:      s.Uses,
+			RevokedAt: s.RevokedAt,
+			CreatedAt: s.CreatedAt,
+			Status:    signedURLStatus(s, now),
+		})
+	}
+	c.JSON(http.StatusOK, infos)
+}
+
+// RevokeSignedURL revokes the signed URL of link with the nonce in the path.
+func RevokeSignedURL(c *gin.Context, link database.Link) {
+	err := database.RevokeSignedURL(link.GetShortCode(), c.Param("nonce"), time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "signed URL not found"})
+		return
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("shortCode", link.GetShortCode()).Msg("Failed to revoke signed URL")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "revoke failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "signed URL revoked"})
+}
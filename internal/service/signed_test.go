@@ -0,0 +1,126 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/database"
+)
+
+func TestVerifySignature(t *testing.T) {
+	key := []byte("secret")
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	expires := now.Add(time.Hour).Unix()
+
+	signedURL := signedLinkURL("https://s.example.com/abc123", key, "abc123", expires, "nonce")
+	u, err := url.Parse(signedURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce, ok := verifySignature(key, "abc123", u.Query(), now); !ok || nonce != "nonce" {
+		t.Fatalf("verifySignature(%q) = %q, %v", signedURL, nonce, ok)
+	}
+
+	tests := []struct {
+		name  string
+		key   []byte
+		code  string
+		query func(url.Values)
+		now   time.Time
+	}{
+		{name: "other code", key: key, code: "xyz789", now: now},
+		{name: "other key", key: []byte("other"), code: "abc123", now: now},
+		{name: "no key", code: "abc123", now: now},
+		{name: "expired", key: key, code: "abc123", now: now.Add(2 * time.Hour)},
+		{name: "extended", key: key, code: "abc123", now: now, query: func(q url.Values) { q.Set(signedExpiresParam, "1999999999") }},
+		{name: "other nonce", key: key, code: "abc123", now: now, query: func(q url.Values) { q.Set(signedNonceParam, "other") }},
+		{name: "no signature", key: key, code: "abc123", now: now, query: func(q url.Values) { q.Del(signatureParam) }},
+	}
+	for _, tt := range tests {
+		query := u.Query()
+		if tt.query != nil {
+			tt.query(query)
+		}
+		if _, ok := verifySignature(tt.key, tt.code, query, tt.now); ok {
+			t.Errorf("%s: verifySignature() = true", tt.name)
+		}
+	}
+}
+
+func TestSignedURLStatus(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		signed database.SignedURL
+		want   string
+	}{
+		{signed: database.SignedURL{ExpiresAt: later}, want: SignedActive},
+		{signed: database.SignedURL{ExpiresAt: later, MaxUses: 2, Uses: 1}, want: SignedActive},
+		{signed: database.SignedURL{ExpiresAt: later, MaxUses: 2, Uses: 2}, want: SignedUsedUp},
+		{signed: database.SignedURL{ExpiresAt: now}, want: SignedExpired},
+		{signed: database.SignedURL{ExpiresAt: later, RevokedAt: &now}, want: SignedRevoked},
+	}
+	for _, tt := range tests {
+		if got := signedURLStatus(tt.signed, now); got != tt.want {
+			t.Errorf("signedURLStatus(%+v) = %q, want %q", tt.signed, got, tt.want)
+		}
+	}
+}
+
+func TestSignedURLRequestExpiresAt(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	far := now.Add(maxSignedTTL + time.Hour)
+
+	if got, err := (signedURLRequest{}).expiresAt(now); err != nil || !got.Equal(now.Add(defaultSignedTTL)) {
+		t.Errorf("default expiresAt() = %v, %v", got, err)
+	}
+	if got, err := (signedURLRequest{TTL: 60}).expiresAt(now); err != nil || !got.Equal(now.Add(time.Minute)) {
+		t.Errorf("ttl expiresAt() = %v, %v", got, err)
+	}
+	for _, req := range []signedURLRequest{{ExpiresAt: &past}, {ExpiresAt: &far}, {TTL: -1}, {TTL: 60, ExpiresAt: &far}} {
+		if _, err := req.expiresAt(now); err == nil {
+			t.Errorf("expiresAt(%+v) error = nil", req)
+		}
+	}
+}
+
+func TestSignedAccessDestination(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := testContext(w, http.MethodGet, "/abc123?ref=mail&sig=x&sig_expires=1&sig_nonce=n", nil)
+	c.Set(signedAccessKey, true)
+
+	link := database.PublicShortURL{ShortCode: "abc123", OriginalURL: "https://www.example.com/a", ExpiresAt: time.Now().Add(time.Hour),
+		LinkOptions: database.LinkOptions{Passthrough: true, RedirectStatus: http.StatusMovedPermanently}}
+	destination := BuildDestination(c, link, link.OriginalURL)
+	if destination != "https://www.example.com/a?ref=mail" {
+		t.Fatalf("BuildDestination() = %q", destination)
+	}
+
+	// access granted by a signature is never cached, even for permanent redirects
+	Redirect(c, link, destination)
+	if w.Code != http.StatusMovedPermanently || !strings.Contains(w.Header().Get("Cache-Control"), "no-store") {
+		t.Fatalf("Redirect() = %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestUseSignedAccessWithoutUse(t *testing.T) {
+	link := database.PublicShortURL{ShortCode: "abc123"}
+
+	// access not granted by a signature has no use to count
+	if !UseSignedAccess(testContext(httptest.NewRecorder(), http.MethodGet, "/abc123", nil), link) {
+		t.Error("UseSignedAccess() without a signed URL = false")
+	}
+
+	// HEAD requests only inspect the link
+	c := testContext(httptest.NewRecorder(), http.MethodHead, "/abc123?sig=x&sig_expires=1&sig_nonce=n", nil)
+	c.Set(signedAccessKey, true)
+	c.Set(signedNonceKey, "n")
+	if !UseSignedAccess(c, link) {
+		t.Error("UseSignedAccess() on HEAD = false")
+	}
+}
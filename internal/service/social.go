@@ -0,0 +1,105 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/page"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxOGTitleLength       = 255
+	maxOGDescriptionLength = 512
+)
+
+// crawlerAgents are User-Agent substrings of the bots that fetch a link to
+// build its preview card in chats and social networks. Matched in lower case.
+var crawlerAgents = []string{
+	"facebookexternalhit",
+	"facebot",
+	"twitterbot",
+	"slackbot",
+	"linkedinbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"skypeuripreview",
+	"pinterest",
+	"redditbot",
+	"applebot",
+	"embedly",
+	"vkshare",
+	"iframely",
+	"mastodon",
+	"bitlybot",
+	"google-pagerenderer",
+}
+
+// isCrawler reports whether userAgent belongs to a link preview crawler.
+func isCrawler(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, agent := range crawlerAgents {
+		if strings.Contains(ua, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSocialCard checks the length of the Open Graph overrides.
+func validateSocialCard(title, description string) error {
+	if utf8.RuneCountInString(title) > maxOGTitleLength {
+		return fmt.Errorf("og_title must be at most %d characters", maxOGTitleLength)
+	}
+	if utf8.RuneCountInString(description) > maxOGDescriptionLength {
+		return fmt.Errorf("og_description must be at most %d characters", maxOGDescriptionLength)
+	}
+	return nil
+}
+
+// hasSocialCard reports whether the owner set any Open Graph override.
+func hasSocialCard(opts database.LinkOptions) bool {
+	return opts.OGTitle != "" || opts.OGDescription != "" || opts.OGImage != ""
+}
+
+// socialCard is the data of the social.html template.
+type socialCard struct {
+	Title       string
+	Description string
+	Image       string
+	ShortURL    string
+	OriginalURL string
+}
+
+// SocialCard serves the Open Graph card of link to link preview crawlers and
+// reports whether it did.
+//
+// Only links with overrides get a card, crawlers of other links follow the
+// redirect and read the tags of the destination. The title falls back to the
+// preview title of the link.
+func SocialCard(c *gin.Context, link database.Link) bool {
+	opts := link.GetOptions()
+	if !hasSocialCard(opts) || !isCrawler(c.Request.UserAgent()) {
+		return false
+	}
+
+	card := socialCard{
+		Title:       opts.OGTitle,
+		Description: opts.OGDescription,
+		Image:       opts.OGImage,
+		ShortURL:    LinkURL(c, link),
+		OriginalURL: link.GetOriginalURL(),
+	}
+	if card.Title == "" {
+		card.Title = opts.Title
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Vary", "User-Agent")
+	page.Render(c, http.StatusOK, "social.html", card)
+	return true
+}
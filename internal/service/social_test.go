@@ -0,0 +1,91 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url-shortener/internal/pkg/database"
+)
+
+const slackUA = "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"
+
+func serveSocialCard(link database.Link, userAgent string) (*httptest.ResponseRecorder, bool) {
+	w := httptest.NewRecorder()
+	c := testContext(w, http.MethodGet, "http://s.example.com/"+link.GetShortCode(), http.Header{"User-Agent": {userAgent}})
+	return w, SocialCard(c, link)
+}
+
+func TestIsCrawler(t *testing.T) {
+	for _, ua := range []string{slackUA, "facebookexternalhit/1.1", "Mozilla/5.0 (compatible; Discordbot/2.0)", "Twitterbot/1.0"} {
+		if !isCrawler(ua) {
+			t.Errorf("isCrawler(%q) = false", ua)
+		}
+	}
+	for _, ua := range []string{desktopUA, iphoneUA, ""} {
+		if isCrawler(ua) {
+			t.Errorf("isCrawler(%q) = true", ua)
+		}
+	}
+}
+
+func TestSocialCard(t *testing.T) {
+	link := database.PublicShortURL{
+		ShortCode:   "abc123",
+		OriginalURL: "https://www.example.com/a?b=1&c=2",
+		LinkOptions: database.LinkOptions{
+			Title:         "Fallback title",
+			OGDescription: `Spring <sale> "now"`,
+			OGImage:       "https://cdn.example.com/card.png",
+		},
+	}
+
+	w, served := serveSocialCard(link, slackUA)
+	if !served || w.Code != http.StatusOK {
+		t.Fatalf("expected card for crawler, got %v %d", served, w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`<meta property="og:title" content="Fallback title">`,
+		`<meta property="og:description" content="Spring &lt;sale&gt; &#34;now&#34;">`,
+		`<meta property="og:image" content="https://cdn.example.com/card.png">`,
+		`<meta property="og:url" content="http://s.example.com/abc123">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("card misses %s:\n%s", want, body)
+		}
+	}
+
+	if _, served := serveSocialCard(link, desktopUA); served {
+		t.Error("expected humans to be redirected")
+	}
+	link.LinkOptions = database.LinkOptions{Title: "Only a title"}
+	if _, served := serveSocialCard(link, slackUA); served {
+		t.Error("expected crawlers to follow links without overrides")
+	}
+}
+
+func TestRedirectVariesOnSocialCard(t *testing.T) {
+	link := database.PublicShortURL{
+		ShortCode:   "abc123",
+		OriginalURL: "https://www.example.com",
+		LinkOptions: database.LinkOptions{RedirectStatus: http.StatusMovedPermanently, OGTitle: "Card"},
+	}
+	w := serveRedirect(link, link.OriginalURL, http.Header{"User-Agent": {desktopUA}})
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Vary") != "User-Agent" {
+		t.Errorf("expected 301 varying on User-Agent, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestValidateSocialCard(t *testing.T) {
+	if err := validateSocialCard("title", "description"); err != nil {
+		t.Errorf("validateSocialCard() error = %v", err)
+	}
+	if err := validateSocialCard(strings.Repeat("x", maxOGTitleLength+1), ""); err == nil {
+		t.Error("expected error for long title")
+	}
+	if err := validateSocialCard("", strings.Repeat("x", maxOGDescriptionLength+1)); err == nil {
+		t.Error("expected error for long description")
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"url-shortener/internal/pkg/cache"
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxRedirectRate  = 10000  // 每秒最多跳转次数的上限
+	maxRedirectBurst = 100000 // 突发跳转次数的上限
+)
+
+// redirectCapTimeout bounds the Redis round trips of a capped redirect, so an
+// unreachable Redis does not hold up visitors.
+const redirectCapTimeout = 200 * time.Millisecond
+
+// redirectCaps enforces the redirect caps of links across replicas and counts the
+// visitors turned away.
+type redirectCaps interface {
+	Limiter(rate float64, burst int) ratelimit.Limiter
+	CountThrottled(ctx context.Context, shortCode string) error
+	Throttled(ctx context.Context, shortCode string) (int64, error)
+}
+
+// redisRedirectCaps keeps the buckets and counters of redirect caps in Redis.
+type redisRedirectCaps struct {
+	client *redis.Client
+}
+
+func (r redisRedirectCaps) Limiter(rate float64, burst int) ratelimit.Limiter {
+	return ratelimit.NewRedisLimiter(r.client, "ratelimit:redirect:", rate, burst)
+}
+
+func (r redisRedirectCaps) CountThrottled(ctx context.Context, shortCode string) error {
+	return r.client.Incr(ctx, "throttled:redirect:"+shortCode).Err()
+}
+
+func (r redisRedirectCaps) Throttled(ctx context.Context, shortCode string) (int64, error) {
+	count, err := r.client.Get(ctx, "throttled:redirect:"+shortCode).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return count, err
+}
+
+var (
+	redirectCapsOnce sync.Once
+	caps             redirectCaps
+)
+
+// getRedirectCaps returns the store of redirect caps, connecting to Redis the
+// first time a capped link is served.
+func getRedirectCaps() redirectCaps {
+	redirectCapsOnce.Do(func() {
+		if caps == nil {
+			caps = redisRedirectCaps{client: cache.NewRedisClient()}
+		}
+	})
+	return caps
+}
+
+// validateRateLimit checks the redirect cap of a create request. The burst
+// defaults to one second of traffic.
+func (r *shortURLRequest) validateRateLimit() error {
+	if r.RateLimit < 0 || r.RateLimit > maxRedirectRate || math.IsNaN(r.RateLimit) {
+		return &createError{Status: http.StatusBadRequest, Code: "invalid_rate_limit", Message: "rate_limit must be between 0 and 10000 requests per second"}
+	}
+	if r.RateLimit == 0 {
+		if r.RateBurst != 0 || r.RateLimitURL != "" {
+			return &createError{Status: http.StatusBadRequest, Code: "invalid_rate_limit", Message: "rate_burst and rate_limit_url require rate_limit"}
+		}
+		return nil
+	}
+	if r.RateBurst < 0 || r.RateBurst > maxRedirectBurst {
+		return &createError{Status: http.StatusBadRequest, Code: "invalid_rate_limit", Message: "rate_burst must be between 0 and 100000"}
+	}
+	if r.RateBurst == 0 {
+		r.RateBurst = int(math.Ceil(r.RateLimit))
+	}
+	return nil
+}
+
+// Throttle enforces the redirect cap of link and reports whether the visitor was
+// turned away. Visitors over the cap are sent to the rate limit URL of the link,
+// or get 429 without one.
+//
+// The cap is shared by every replica through Redis. When Redis can not be reached
+// visitors are let through, the cap only protects the destination.
+func Throttle(c *gin.Context, link database.Link) bool {
+	opts := link.GetOptions()
+	if opts.RateLimit <= 0 {
+		return false
+	}
+	store := getRedirectCaps()
+	shortCode := link.GetShortCode()
+	ctx, cancel := context.WithTimeout(c.Request.Context(), redirectCapTimeout)
+	defer cancel()
+
+	allowed, err := store.Limiter(opts.RateLimit, max(opts.RateBurst, 1)).AllowN(ctx, shortCode, 1)
+	if err != nil {
+		log.Warn().Err(err).Str("shortCode", shortCode).Msg("Failed to check redirect rate limit")
+		return false
+	}
+	if allowed {
+		return false
+	}
+
+	if err := store.CountThrottled(ctx, shortCode); err != nil {
+		log.Warn().Err(err).Str("shortCode", shortCode).Msg("Failed to count throttled redirect")
+	}
+	c.Header("Cache-Control", "no-store")
+	if opts.RateLimitURL != "" {
+		c.Redirect(http.StatusFound, opts.RateLimitURL)
+		return true
+	}
+	c.Header("Retry-After", "1")
+	RespondLinkError(c, http.StatusTooManyRequests, "too many requests", link)
+	return true
+}
+
+// RateLimitStats is the redirect cap of a link and how many visitors it turned away.
+type RateLimitStats struct {
+	Rate         float64 `json:"rate"`
+	Burst        int     `json:"burst"`
+	RateLimitURL string  `json:"rate_limit_url,omitempty"`
+	// Throttled is left out when Redis can not be reached.
+	Throttled *int64 `json:"throttled,omitempty"`
+}
+
+// LinkRateLimitStats returns the redirect cap stats of link, or nil when it has no cap.
+func LinkRateLimitStats(ctx context.Context, link database.Link) *RateLimitStats {
+	opts := link.GetOptions()
+	if opts.RateLimit <= 0 {
+		return nil
+	}
+	stats := &RateLimitStats{Rate: opts.RateLimit, Burst: opts.RateBurst, RateLimitURL: opts.RateLimitURL}
+	if throttled, err := getRedirectCaps().Throttled(ctx, link.GetShortCode()); err != nil {
+		log.Warn().Err(err).Str("shortCode", link.GetShortCode()).Msg("Failed to get throttled redirects")
+	} else {
+		stats.Throttled = &throttled
+	}
+	return stats
+}
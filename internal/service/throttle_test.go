@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/ratelimit"
+)
+
+// memoryRedirectCaps keeps redirect caps in process instead of Redis. Every link
+// shares one limiter, keyed by short code like the buckets in Redis.
+type memoryRedirectCaps struct {
+	limiter   ratelimit.Limiter
+	throttled map[string]int64
+}
+
+func (m memoryRedirectCaps) Limiter(float64, int) ratelimit.Limiter {
+	return m.limiter
+}
+
+func (m memoryRedirectCaps) CountThrottled(_ context.Context, shortCode string) error {
+	m.throttled[shortCode]++
+	return nil
+}
+
+func (m memoryRedirectCaps) Throttled(_ context.Context, shortCode string) (int64, error) {
+	return m.throttled[shortCode], nil
+}
+
+func TestValidateRateLimit(t *testing.T) {
+	req := shortURLRequest{RateLimit: 2.5}
+	if err := req.validateRateLimit(); err != nil || req.RateBurst != 3 {
+		t.Fatalf("validateRateLimit() = %v, burst %d", err, req.RateBurst)
+	}
+
+	for _, req := range []shortURLRequest{
+		{RateLimit: -1},
+		{RateLimit: maxRedirectRate + 1},
+		{RateBurst: 10},
+		{RateLimitURL: "https://www.example.com/busy"},
+		{RateLimit: 10, RateBurst: -1},
+		{RateLimit: 10, RateBurst: maxRedirectBurst + 1},
+	} {
+		if err := req.validateRateLimit(); err == nil {
+			t.Errorf("validateRateLimit(%+v) error = nil", req)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	redirectCapsOnce.Do(func() {})
+	caps = memoryRedirectCaps{limiter: ratelimit.NewMemoryLimiter(0.001, 1), throttled: make(map[string]int64)}
+	defer func() { caps = nil }()
+
+	link := database.PublicShortURL{ShortCode: "abc123", ExpiresAt: time.Now().Add(time.Hour), LinkOptions: database.LinkOptions{RateLimit: 0.001, RateBurst: 1}}
+	w := httptest.NewRecorder()
+	if Throttle(testContext(w, http.MethodGet, "/abc123", http.Header{"Accept": {"application/json"}}), link) {
+		t.Fatal("first visitor was throttled")
+	}
+
+	w = httptest.NewRecorder()
+	if !Throttle(testContext(w, http.MethodGet, "/abc123", http.Header{"Accept": {"application/json"}}), link) || w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Throttle() over the cap = %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	link.RateLimitURL = "https://www.example.com/busy"
+	w = httptest.NewRecorder()
+	if !Throttle(testContext(w, http.MethodGet, "/abc123", nil), link) || w.Code != http.StatusFound || w.Header().Get("Location") != link.RateLimitURL {
+		t.Fatalf("Throttle() with rate limit URL = %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	stats := LinkRateLimitStats(context.Background(), link)
+	if stats == nil || stats.Throttled == nil || *stats.Throttled != 2 {
+		t.Fatalf("LinkRateLimitStats() = %+v", stats)
+	}
+	if LinkRateLimitStats(context.Background(), database.PublicShortURL{ShortCode: "xyz789"}) != nil {
+		t.Error("LinkRateLimitStats() of an uncapped link is not nil")
+	}
+	if Throttle(testContext(httptest.NewRecorder(), http.MethodGet, "/abc123", nil), database.PublicShortURL{ShortCode: "xyz789"}) {
+		t.Error("uncapped link was throttled")
+	}
+}
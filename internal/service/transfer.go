@@ -0,0 +1,478 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/linkio"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	// adminNamespace is the RBAC namespace of the system wide permissions,
+	// "export urls" there allows exporting every link and "import urls" importing for any user.
+	adminNamespace = "default"
+
+	exportBatchSize = 500 // 导出时每批读取的行数
+
+	ConflictSkip   = "skip"   // 短码已被占用时跳过该行
+	ConflictRename = "rename" // 短码已被占用时生成新短码
+)
+
+// exportRecord converts a link into an export record.
+func exportRecord(link database.Link) linkio.Record {
+	opts := link.GetOptions()
+	rec := linkio.Record{
+		Code:        link.GetShortCode(),
+		URL:         link.GetOriginalURL(),
+		Title:       opts.Title,
+		Visibility:  opts.Visibility,
+		Namespace:   opts.Namespace,
+		Owner:       link.GetOwnerID(),
+		CreatedAt:   link.GetCreatedAt(),
+		ExpireAt:    link.GetExpireAt(),
+		AccessCount: link.GetAccessCount(),
+	}
+	if opts.Tags != "" {
+		rec.Tags = strings.Split(opts.Tags, ",")
+	}
+	return rec
+}
+
+// exportExtension returns the file name extension of an export format.
+func exportExtension(format string) string {
+	if format == linkio.FormatBookmark {
+		return "html"
+	}
+	return format
+}
+
+// ExportLinks streams the links of the caller as a file download.
+// Query parameters:
+//
+//	format: csv (default), ndjson or html (browser bookmarks)
+//	all:    true exports every user and public link, it needs the "export urls"
+//	        permission in the default namespace
+//
+// Links are read and written in batches, so large accounts are never held in memory.
+func ExportLinks(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	format := c.DefaultQuery("format", linkio.FormatCSV)
+	w, err := linkio.NewWriter(format, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of csv, ndjson, html"})
+		return
+	}
+
+	all := c.Query("all") == "true"
+	if all && !authorizeCaller(c, "export", "urls", adminNamespace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	filename := fmt.Sprintf("links-%s.%s", time.Now().Format("20060102"), exportExtension(format))
+	c.Header("Content-Type", linkio.ContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	flush := func() error {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	owner := userID
+	if all {
+		owner = ""
+	}
+	err = database.EachUserShortURL(owner, exportBatchSize, func(batch []database.UserShortURL) error {
+		for _, link := range batch {
+			if err := w.Write(exportRecord(link)); err != nil {
+				return err
+			}
+		}
+		return flush()
+	})
+	if err == nil && all {
+		err = database.EachPublicShortURL(exportBatchSize, func(batch []database.PublicShortURL) error {
+			for _, link := range batch {
+				if err := w.Write(exportRecord(link)); err != nil {
+					return err
+				}
+			}
+			return flush()
+		})
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		// 响应头已发送，只能中断输出
+		log.Warn().Err(err).Str("userID", userID).Msg("Failed to export links")
+		c.Abort()
+	}
+}
+
+// importOptions controls how imported records become links.
+type importOptions struct {
+	UserID     string // 导入链接的所有者
+	CallerID   string // 发起导入的用户，按其计入 bulk 行数限制
+	ClientIP   string
+	OnConflict string // ConflictSkip 或 ConflictRename
+	ChunkSize  int
+	// ExpireAfter is how long records without an expiration live after the
+	// import, zero keeps them until importNoExpiry.
+	ExpireAfter time.Duration
+	// Migration marks an admin migration from another shortener, whose click
+	// totals are carried over into AccessCount. Migrations are not charged against
+	// the bulk row limit, a large dump would otherwise stop halfway.
+	Migration bool
+}
+
+// importNoExpiry is the expiration of imported records without one when no
+// expire_after is given. Links of other shorteners usually never expire.
+var importNoExpiry = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// expireAt returns the expiration of an imported record without one.
+func (o importOptions) expireAt(now time.Time) time.Time {
+	if o.ExpireAfter > 0 {
+		return now.Add(o.ExpireAfter)
+	}
+	return importNoExpiry
+}
+
+// importLinks reads every record of r and creates a user link for each valid one.
+//
+// A record keeps its short code when the code is a valid alias and is not taken,
+// otherwise it is skipped or gets a new code, as opts.OnConflict says.
+// Creation times are carried over. Click counts are only carried over by
+// migrations (opts.Migration), links imported by users start at zero since the
+// counts of their files can not be verified. Records without an expiration get
+// one from opts.ExpireAfter, expired records are rejected with invalid_expiry.
+// Records are saved in chunks, each chunk in its own transaction, and the bulk
+// row limit of the caller (except for migrations) and the link quota are checked
+// per chunk.
+func importLinks(ctx context.Context, r linkio.Reader, opts importOptions) ([]BulkResult, error) {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = defaultBulkChunkSize
+	}
+
+	var results []BulkResult
+	seen := make(map[string]bool)
+	for done := false; !done; {
+		var chunk []linkio.Record
+		for len(chunk) < opts.ChunkSize {
+			rec, err := r.Read()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			if err != nil {
+				return results, err
+			}
+			chunk = append(chunk, rec)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+
+		if !opts.Migration {
+			if err := allowImportRows(ctx, opts.CallerID, len(chunk)); err != nil {
+				return results, err
+			}
+		}
+		chunkResults, err := importChunk(ctx, chunk, len(results), seen, opts)
+		results = append(results, chunkResults...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// allowImportRows charges rows against the bulk row limit of callerID.
+func allowImportRows(ctx context.Context, callerID string, rows int) error {
+	limiter := bulkLimit()
+	if limiter == nil {
+		return nil
+	}
+	allowed, err := limiter.AllowN(ctx, callerID, rows)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &createError{Status: http.StatusTooManyRequests, Code: "rate_limited", Message: "too many links created, try again later"}
+	}
+	return nil
+}
+
+// importChunk validates and saves one chunk of records, first is the number of
+// records before the chunk.
+func importChunk(ctx context.Context, chunk []linkio.Record, first int, seen map[string]bool, opts importOptions) ([]BulkResult, error) {
+	now := time.Now()
+	results := make([]BulkResult, len(chunk))
+	reqs := make([]shortURLRequest, len(chunk))
+	var valid []int
+	var codes []string
+	for i, rec := range chunk {
+		results[i].Row = first + i + 1
+		reqs[i] = shortURLRequest{LongURL: rec.URL, Title: rec.Title, Tags: rec.Tags, Visibility: rec.Visibility, Namespace: rec.Namespace}
+		expireAt := rec.ExpireAt
+		if expireAt.IsZero() {
+			expireAt = opts.expireAt(now)
+		}
+		reqs[i].ExpireAt = &expireAt
+		if err := reqs[i].validate(ctx, false); err != nil {
+			results[i].Code, results[i].Error = bulkErrorCode(err), err.Error()
+			continue
+		}
+		results[i].OriginalURL = reqs[i].LongURL
+		if rec.Code != "" {
+			codes = append(codes, rec.Code)
+		}
+		valid = append(valid, i)
+	}
+
+	inUse, err := database.ShortCodesInUse(codes)
+	if err != nil {
+		return results, err
+	}
+
+	shorts := make([]database.UserShortURL, 0, len(valid))
+	rows := make([]int, 0, len(valid))
+	for _, i := range valid {
+		rec := chunk[i]
+		shortCode := rec.Code
+		if shortCode != "" {
+			var conflict string
+			if err := validateAlias(shortCode); err != nil {
+				conflict = err.Error()
+			} else if inUse[shortCode] || seen[shortCode] {
+				conflict = "short code is already taken"
+			}
+			if conflict != "" {
+				if opts.OnConflict != ConflictRename {
+					results[i].Code, results[i].Error = "code_conflict", conflict
+					continue
+				}
+				results[i].OriginalCode = shortCode
+				shortCode = ""
+			}
+		}
+		if shortCode == "" {
+			if shortCode, err = createShortURL(); err != nil {
+				return results, err
+			}
+		}
+		seen[shortCode] = true
+
+		shorts = append(shorts, importedLink(rec, reqs[i], shortCode, opts))
+		rows = append(rows, i)
+	}
+	if len(shorts) == 0 {
+		return results, nil
+	}
+
+	if err := checkQuota(opts.UserID, len(shorts)); err != nil {
+		var createErr *createError
+		if !errors.As(err, &createErr) {
+			return results, err
+		}
+		for _, i := range rows {
+			results[i].Code, results[i].Error = createErr.Code, createErr.Message
+		}
+		return results, nil
+	}
+
+	if err := database.CreateUserShortURLs(shorts, opts.ClientIP); err != nil {
+		log.Warn().Err(err).Int("rows", len(shorts)).Msg("Failed to save imported links")
+		for _, i := range rows {
+			results[i].Code, results[i].Error = "save_failed", "save failed"
+		}
+		return results, nil
+	}
+	for j, i := range rows {
+		results[i].ShortURL = shorts[j].ShortCode
+	}
+	return results, nil
+}
+
+// importedLink returns the link saved for rec, req is its validated request.
+func importedLink(rec linkio.Record, req shortURLRequest, shortCode string, opts importOptions) database.UserShortURL {
+	short := database.UserShortURL{UserID: opts.UserID, ShortCode: shortCode, OriginalURL: req.LongURL, ExpireAt: req.expireAt(), LinkOptions: req.options()}
+	short.CreatedAt = rec.CreatedAt
+	if opts.Migration {
+		short.AccessCount = int(rec.AccessCount)
+	}
+	return short
+}
+
+// importReader opens the uploaded file of an import request: the multipart "file"
+// field or the request body. The format comes from the "format" query parameter,
+// the content type or the file name extension. A "columns" mapping (see
+// linkio.ParseColumnMap) reads any CSV file.
+func importReader(c *gin.Context) (linkio.Reader, io.Closer, error) {
+	body := io.ReadCloser(c.Request.Body)
+	contentType, name := c.ContentType(), ""
+	if contentType == "multipart/form-data" {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, nil, err
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		body, contentType, name = f, file.Header.Get("Content-Type"), file.Filename
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = linkio.FormatFromContentType(contentType)
+	}
+	if format == "" {
+		switch strings.ToLower(path.Ext(name)) {
+		case ".csv":
+			format = linkio.FormatCSV
+		case ".ndjson", ".jsonl":
+			format = linkio.FormatNDJSON
+		case ".html", ".htm":
+			format = linkio.FormatBookmark
+		case ".sql":
+			format = linkio.FormatYOURLSSQL
+		}
+	}
+
+	var r linkio.Reader
+	var err error
+	if spec := c.Query("columns"); spec != "" && (format == "" || format == linkio.FormatCSV) {
+		var columns linkio.ColumnMap
+		if columns, err = linkio.ParseColumnMap(spec); err == nil {
+			r, err = linkio.NewCSVReader(body, columns)
+		}
+	} else {
+		r, err = linkio.NewReader(format, body)
+	}
+	if err != nil {
+		body.Close()
+		return nil, nil, err
+	}
+	return r, body, nil
+}
+
+// ImportLinks creates links for the caller from a file written by ExportLinks,
+// a browser bookmark file or the export of another shortener (yourls-sql,
+// yourls-csv, bitly or csv with a "columns" mapping).
+// Codes are kept when they are free; the "on_conflict" query parameter decides
+// what happens to the others: skip (default) reports them as failed, rename
+// gives them a new code. Records without an expiration never expire, unless the
+// "expire_after" query parameter (a duration such as 8760h) says otherwise.
+//
+// The response is the same as the bulk create response, renamed rows carry
+// their "original_code".
+func ImportLinks(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	runImport(c, userID, ConflictSkip, false)
+}
+
+// AdminImportLinks imports links like ImportLinks for the user named by the "owner"
+// query parameter, the caller by default, which must be an existing user. It is
+// meant for migrations from other shorteners, so taken codes are renamed unless
+// on_conflict=skip is given, and click totals are carried over.
+// The caller needs the "import urls" permission in the default namespace.
+func AdminImportLinks(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if !authorizeCaller(c, "import", "urls", adminNamespace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	owner := c.DefaultQuery("owner", userID)
+	if owner != userID {
+		if _, err := database.GetUserByID(owner); errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "owner not found"})
+			return
+		} else if err != nil {
+			log.Warn().Err(err).Str("owner", owner).Msg("Failed to get import owner")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+	}
+	runImport(c, owner, ConflictRename, true)
+}
+
+// runImport imports the uploaded file for ownerID and writes the bulk response.
+// migration is set for admin migrations, see importOptions.
+func runImport(c *gin.Context, ownerID, defaultConflict string, migration bool) {
+	onConflict := c.DefaultQuery("on_conflict", defaultConflict)
+	if onConflict != ConflictSkip && onConflict != ConflictRename {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "on_conflict must be skip or rename"})
+		return
+	}
+
+	var expireAfter time.Duration
+	if v := c.Query("expire_after"); v != "" {
+		var err error
+		if expireAfter, err = time.ParseDuration(v); err != nil || expireAfter <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expire_after must be a positive duration such as 8760h"})
+			return
+		}
+	}
+
+	r, closer, err := importReader(c)
+	if err != nil {
+		log.Err(err).Msg("Invalid import request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer closer.Close()
+
+	results, err := importLinks(c.Request.Context(), r, importOptions{
+		UserID:      ownerID,
+		CallerID:    c.GetString("user_id"),
+		ClientIP:    c.ClientIP(),
+		OnConflict:  onConflict,
+		ChunkSize:   viper.GetInt("bulk.chunk_size"),
+		ExpireAfter: expireAfter,
+		Migration:   migration,
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("userID", ownerID).Int("rows", len(results)).Msg("Import stopped")
+		status := http.StatusBadRequest
+		resp := bulkResponse(results)
+		resp["error"] = err.Error()
+		var createErr *createError
+		if errors.As(err, &createErr) {
+			status = createErr.Status
+			resp["code"] = createErr.Code
+		}
+		c.JSON(status, resp)
+		return
+	}
+	c.JSON(http.StatusOK, bulkResponse(results))
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"url-shortener/internal/pkg/database"
+	"url-shortener/internal/pkg/urlcheck"
+	"url-shortener/internal/pkg/urlpolicy"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const defaultDomainReloadInterval = 30 * time.Second
+
+var (
+	// urlCheckers are asked about every destination when a short URL is created.
+	urlCheckers urlcheck.Chain
+	// domainList is also checked at redirect time, so links whose domain is blocked
+	// after creation get disabled.
+	domainList *urlcheck.DomainList
+)
+
+// InitURLChecks loads the domain lists of the domain_policy config and reloads
+// them whenever the files change, until ctx is done.
+//
+//	domain_policy:
+//	  blocklist_file: "./blocklist.txt"
+//	  allowlist_file: "./allowlist.txt"
+//	  allowlist_only: false
+//	  reload_interval: 30s
+func InitURLChecks(ctx context.Context) error {
+	blockFile := viper.GetString("domain_policy.blocklist_file")
+	allowFile := viper.GetString("domain_policy.allowlist_file")
+	if blockFile == "" && allowFile == "" {
+		log.Info().Msg("No domain lists configured, skipping domain checks")
+		return nil
+	}
+
+	list, err := urlcheck.NewFileDomainList(blockFile, allowFile, viper.GetBool("domain_policy.allowlist_only"))
+	if err != nil {
+		return err
+	}
+
+	interval := viper.GetDuration("domain_policy.reload_interval")
+	if interval <= 0 {
+		interval = defaultDomainReloadInterval
+	}
+	go list.Watch(ctx, interval)
+
+	domainList = list.DomainList
+	// the local lists go first, so allowlisted domains skip the reputation providers
+	urlCheckers = append(urlcheck.Chain{list}, urlCheckers...)
+	return nil
+}
+
+// AddURLChecker plugs a reputation provider into the create path.
+func AddURLChecker(checker urlcheck.URLChecker) {
+	urlCheckers = append(urlCheckers, checker)
+}
+
+// checkURLs asks the URL checkers about every destination of the request.
+func (r shortURLRequest) checkURLs(ctx context.Context) error {
+	urls := []string{r.LongURL}
+	for _, d := range r.Destinations {
+		urls = append(urls, d.URL)
+	}
+	if r.FallbackURL != "" {
+		urls = append(urls, r.FallbackURL)
+	}
+	urls = append(urls, webAppLinks(r.options())...)
+	if r.ExpiredURL != "" {
+		urls = append(urls, r.ExpiredURL)
+	}
+	if r.PrelaunchURL != "" {
+		urls = append(urls, r.PrelaunchURL)
+	}
+	if r.RateLimitURL != "" {
+		urls = append(urls, r.RateLimitURL)
+	}
+
+	for _, u := range urls {
+		verdict, err := urlCheckers.Check(ctx, u)
+		if err != nil {
+			return err
+		}
+		if !verdict.Allowed {
+			return &urlpolicy.Error{Code: "destination_blocked", Message: verdict.Reason}
+		}
+	}
+	return nil
+}
+
+// storedDestinations returns every URL link may send visitors to: its destination,
+// variants, web app links and the fallback, expired, pre-launch and rate limit URLs.
+func storedDestinations(link database.Link) ([]string, error) {
+	opts := link.GetOptions()
+	urls := []string{link.GetOriginalURL()}
+	urls = append(urls, webAppLinks(opts)...)
+	for _, u := range []string{opts.FallbackURL, opts.ExpiredURL, opts.PrelaunchURL, opts.RateLimitURL} {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+
+	variants, err := database.GetLinkVariants(link.GetShortCode())
+	if err != nil {
+		return urls, err
+	}
+	for _, v := range variants {
+		urls = append(urls, v.OriginalURL)
+	}
+	return urls, nil
+}
+
+// blockedDestination reports whether one of the stored destinations of link is on
+// the blocklist.
+func blockedDestination(link database.Link) bool {
+	urls, err := storedDestinations(link)
+	if err != nil {
+		log.Warn().Err(err).Str("shortCode", link.GetShortCode()).Msg("Failed to get link variants, checking the other destinations")
+	}
+	for _, u := range urls {
+		if domainList.Blocked(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// LinkDisabled reports whether link must no longer be followed.
+//
+// A link with a destination that landed on the blocklist after creation is
+// disabled here and stays disabled, even if the domain is removed from the list later.
+func LinkDisabled(link database.Link) bool {
+	if link.GetOptions().Disabled {
+		return true
+	}
+	if domainList == nil || !blockedDestination(link) {
+		return false
+	}
+
+	shortCode := link.GetShortCode()
+	log.Warn().Str("shortCode", shortCode).Msg("Destination is blocked, disabling short URL")
+	if err := database.DisableLink(shortCode, "destination domain is blocked"); err != nil {
+		log.Warn().Err(err).Str("shortCode", shortCode).Msg("Failed to disable short URL")
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"url-shortener/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxUTMLength = 128
+
+// utmParams returns the UTM parameters set on the link, in the usual order.
+func utmParams(opts database.LinkOptions) []queryParam {
+	fields := [][2]string{
+		{"utm_source", opts.UTMSource},
+		{"utm_medium", opts.UTMMedium},
+		{"utm_campaign", opts.UTMCampaign},
+		{"utm_term", opts.UTMTerm},
+		{"utm_content", opts.UTMContent},
+	}
+	var params []queryParam
+	for _, f := range fields {
+		if f[1] != "" {
+			params = append(params, queryParam{key: f[0], raw: url.QueryEscape(f[0]) + "=" + url.QueryEscape(f[1])})
+		}
+	}
+	return params
+}
+
+// validateUTM checks the length of the UTM fields.
+func validateUTM(values ...string) error {
+	for _, v := range values {
+		if utf8.RuneCountInString(v) > maxUTMLength {
+			return fmt.Errorf("utm fields must be at most %d characters", maxUTMLength)
+		}
+	}
+	return nil
+}
+
+// queryParam is one "key=value" segment of a query string, kept as it was encoded.
+type queryParam struct {
+	key string // 解码后的参数名
+	raw string
+}
+
+// splitQuery splits a raw query string into its segments.
+func splitQuery(rawQuery string) []queryParam {
+	var params []queryParam
+	for _, seg := range strings.Split(rawQuery, "&") {
+		if seg == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(seg, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		params = append(params, queryParam{key: key, raw: seg})
+	}
+	return params
+}
+
+// overrideQuery drops the parameters of base whose key is set in overrides and
+// appends overrides. The other parameters of base keep their order and encoding.
+func overrideQuery(base, overrides []queryParam) []queryParam {
+	if len(overrides) == 0 {
+		return base
+	}
+	set := make(map[string]bool, len(overrides))
+	for _, p := range overrides {
+		set[p.key] = true
+	}
+	merged := make([]queryParam, 0, len(base)+len(overrides))
+	for _, p := range base {
+		if !set[p.key] {
+			merged = append(merged, p)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+// BuildDestination adds the UTM parameters of link to destination and, in
+// passthrough mode, the query string of the short URL request.
+//
+// When a key is set more than once, the request query wins over the UTM fields of
+// the link, which win over the query of the stored destination. All values of the
+// winning source are kept, e.g. "?tag=a&tag=b".
+//
+// Browsers never send the fragment of the short URL. They carry it over to the
+// destination by themselves, unless the destination has a fragment of its own.
+func BuildDestination(c *gin.Context, link database.Link, destination string) string {
+	opts := link.GetOptions()
+	utm := utmParams(opts)
+	var incoming []queryParam
+	if opts.Passthrough {
+		for _, p := range splitQuery(c.Request.URL.RawQuery) {
+			// the signature of a signed URL must not leak to the destination
+			if !isSignatureParam(p.key) {
+				incoming = append(incoming, p)
+			}
+		}
+	}
+	if len(utm) == 0 && len(incoming) == 0 {
+		return destination
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+	params := overrideQuery(overrideQuery(splitQuery(u.RawQuery), utm), incoming)
+	raw := make([]string, len(params))
+	for i, p := range params {
+		raw[i] = p.raw
+	}
+	u.RawQuery = strings.Join(raw, "&")
+	u.ForceQuery = false
+	return u.String()
+}
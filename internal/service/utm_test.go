@@ -0,0 +1,79 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url-shortener/internal/pkg/database"
+)
+
+func TestBuildDestination(t *testing.T) {
+	utm := database.LinkOptions{UTMSource: "newsletter", UTMMedium: "email", UTMCampaign: "spring sale"}
+	tests := []struct {
+		name        string
+		opts        database.LinkOptions
+		target      string
+		destination string
+		want        string
+	}{
+		{
+			name:        "untouched without options",
+			target:      "/abc123?x=1",
+			destination: "https://www.example.com/p?b=2&a=1#top",
+			want:        "https://www.example.com/p?b=2&a=1#top",
+		},
+		{
+			name:        "utm appended",
+			opts:        utm,
+			target:      "/abc123",
+			destination: "https://www.example.com/p?id=7#top",
+			want:        "https://www.example.com/p?id=7&utm_source=newsletter&utm_medium=email&utm_campaign=spring+sale#top",
+		},
+		{
+			name:        "utm overrides destination",
+			opts:        database.LinkOptions{UTMSource: "newsletter"},
+			target:      "/abc123",
+			destination: "https://www.example.com/?utm_source=old&id=7",
+			want:        "https://www.example.com/?id=7&utm_source=newsletter",
+		},
+		{
+			name:        "query ignored without passthrough",
+			opts:        database.LinkOptions{UTMSource: "newsletter"},
+			target:      "/abc123?utm_source=twitter",
+			destination: "https://www.example.com/",
+			want:        "https://www.example.com/?utm_source=newsletter",
+		},
+		{
+			name:        "request query wins",
+			opts:        database.LinkOptions{UTMSource: "newsletter", UTMMedium: "email", Passthrough: true},
+			target:      "/abc123?utm_source=twitter&tag=a&tag=b&id=9",
+			destination: "https://www.example.com/?id=7&keep=%2F",
+			want:        "https://www.example.com/?keep=%2F&utm_medium=email&utm_source=twitter&tag=a&tag=b&id=9",
+		},
+		{
+			name:        "passthrough without query",
+			opts:        database.LinkOptions{Passthrough: true},
+			target:      "/abc123",
+			destination: "https://www.example.com/p#top",
+			want:        "https://www.example.com/p#top",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testContext(httptest.NewRecorder(), http.MethodGet, tt.target, nil)
+			if got := BuildDestination(c, database.PublicShortURL{ShortCode: "abc123", LinkOptions: tt.opts}, tt.destination); got != tt.want {
+				t.Errorf("BuildDestination() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateUTM(t *testing.T) {
+	if err := validateUTM("newsletter", ""); err != nil {
+		t.Errorf("validateUTM() error = %v", err)
+	}
+	if err := validateUTM("", strings.Repeat("x", maxUTMLength+1)); err == nil {
+		t.Error("expected error for long UTM value")
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+
+	"url-shortener/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	variantCookiePrefix = "us_variant_"     // 粘性分流 cookie 前缀
+	variantCookieMaxAge = 30 * 24 * 60 * 60 // 粘性分流 cookie 有效期（秒）
+)
+
+// variantKey is the context key set when the destination is picked among the
+// variants of a link.
+const variantKey = "link_variant"
+
+// destinationRequest is one weighted destination of an A/B split link.
+type destinationRequest struct {
+	URL    string `json:"url"`
+	Weight uint   `json:"weight"`
+}
+
+// VariantStat is the per-variant breakdown reported by the stats endpoints.
+type VariantStat struct {
+	ID          uint    `json:"id"`
+	OriginalURL string  `json:"original_url"`
+	Weight      uint    `json:"weight"`
+	AccessCount uint    `json:"access_count"`
+	Share       float64 `json:"share"` // 该变体点击数占所有变体点击数的比例
+}
+
+// validateDestinations checks the weighted destinations of a create request.
+func validateDestinations(destinations []destinationRequest) error {
+	for _, d := range destinations {
+		if d.URL == "" {
+			return errors.New("destination url is required")
+		}
+		if d.Weight == 0 {
+			return errors.New("destination weight must be greater than 0")
+		}
+	}
+	return nil
+}
+
+// saveVariants stores the weighted destinations of shortCode.
+func saveVariants(shortCode string, destinations []destinationRequest) error {
+	variants := make([]database.LinkVariant, 0, len(destinations))
+	for _, d := range destinations {
+		variants = append(variants, database.LinkVariant{ShortCode: shortCode, OriginalURL: d.URL, Weight: d.Weight})
+	}
+	return database.CreateLinkVariants(variants)
+}
+
+// pickVariant chooses a variant by weight.
+//
+// If stickyID names one of the variants, the visitor keeps it. Otherwise visitorKey
+// is hashed into the total weight, so the same visitor lands on the same variant
+// even without a cookie.
+func pickVariant(variants []database.LinkVariant, stickyID uint, visitorKey string) (database.LinkVariant, bool) {
+	var total uint64
+	for _, v := range variants {
+		if stickyID != 0 && v.ID == stickyID {
+			return v, true
+		}
+		total += uint64(v.Weight)
+	}
+	if total == 0 {
+		return database.LinkVariant{}, false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(visitorKey))
+	bucket := h.Sum64() % total
+	for _, v := range variants {
+		if bucket < uint64(v.Weight) {
+			return v, true
+		}
+		bucket -= uint64(v.Weight)
+	}
+	return database.LinkVariant{}, false
+}
+
+// ResolveVariant returns the destination of shortCode for the current visitor.
+//
+// Links without variants resolve to originalURL. Otherwise a variant is picked by
+// weight, remembered in a cookie and its access count is incremented.
+func ResolveVariant(c *gin.Context, shortCode, originalURL string) string {
+	variants, err := database.GetLinkVariants(shortCode)
+	if err != nil {
+		log.Warn().Str("shortCode", shortCode).Msg("Failed to get link variants, fallback to original URL")
+		return originalURL
+	}
+	if len(variants) == 0 {
+		return originalURL
+	}
+	c.Set(variantKey, true)
+
+	cookieName := variantCookiePrefix + shortCode
+	var stickyID uint
+	if value, err := c.Cookie(cookieName); err == nil {
+		if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+			stickyID = uint(id)
+		}
+	}
+
+	variant, ok := pickVariant(variants, stickyID, c.ClientIP()+"/"+shortCode)
+	if !ok {
+		return originalURL
+	}
+	c.SetCookie(cookieName, strconv.FormatUint(uint64(variant.ID), 10), variantCookieMaxAge, "/", "", false, true)
+
+	if c.Request.Method != http.MethodHead {
+		if err := database.LogVariantAccess(variant.ID); err != nil {
+			log.Warn().Str("shortCode", shortCode).Uint("variantID", variant.ID).Msg("Failed to log variant access")
+		}
+	}
+	return variant.OriginalURL
+}
+
+// VariantStats returns the click split between the variants of shortCode.
+func VariantStats(shortCode string) ([]VariantStat, error) {
+	variants, err := database.GetLinkVariants(shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	var total uint
+	for _, v := range variants {
+		total += v.AccessCount
+	}
+
+	stats := make([]VariantStat, 0, len(variants))
+	for _, v := range variants {
+		stat := VariantStat{ID: v.ID, OriginalURL: v.OriginalURL, Weight: v.Weight, AccessCount: v.AccessCount}
+		if total > 0 {
+			stat.Share = float64(v.AccessCount) / float64(total)
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
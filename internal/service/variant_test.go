@@ -0,0 +1,51 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"url-shortener/internal/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+func TestPickVariant(t *testing.T) {
+	variants := []database.LinkVariant{
+		{Model: gorm.Model{ID: 1}, OriginalURL: "https://a.example.com", Weight: 70},
+		{Model: gorm.Model{ID: 2}, OriginalURL: "https://b.example.com", Weight: 30},
+	}
+
+	t.Run("sticky cookie", func(t *testing.T) {
+		v, ok := pickVariant(variants, 2, "127.0.0.1/abc")
+		if !ok || v.ID != 2 {
+			t.Errorf("expected sticky variant 2, got %v", v.ID)
+		}
+	})
+
+	t.Run("same visitor same variant", func(t *testing.T) {
+		first, _ := pickVariant(variants, 0, "10.0.0.1/abc")
+		for range 10 {
+			v, _ := pickVariant(variants, 0, "10.0.0.1/abc")
+			if v.ID != first.ID {
+				t.Fatalf("expected variant %d, got %d", first.ID, v.ID)
+			}
+		}
+	})
+
+	t.Run("weighted split", func(t *testing.T) {
+		counts := map[uint]int{}
+		for i := range 10000 {
+			v, _ := pickVariant(variants, 0, fmt.Sprintf("10.0.%d.%d/abc", i/256, i%256))
+			counts[v.ID]++
+		}
+		t.Logf("split: %v", counts)
+		if counts[1] < 6500 || counts[1] > 7500 {
+			t.Errorf("expected about 7000 hits on variant 1, got %d", counts[1])
+		}
+	})
+
+	t.Run("zero weight", func(t *testing.T) {
+		if _, ok := pickVariant([]database.LinkVariant{{Weight: 0}}, 0, "x"); ok {
+			t.Error("expected no variant for zero total weight")
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+
+	"url-shortener/internal/pkg/database"
+	rbacv1 "url-shortener/pkg/apis/rbac/v1"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Link visibility levels.
+const (
+	VisibilityPublic    = "public"    // resolvable and listed
+	VisibilityUnlisted  = "unlisted"  // resolvable but not listed
+	VisibilityPrivate   = "private"   // only the owner can resolve it
+	VisibilityNamespace = "namespace" // users allowed to get urls in the namespace can resolve it
+)
+
+// Authorizer decides RBAC permissions, it is implemented by rbacv1.RBACSystem.
+type Authorizer interface {
+	Authorize(authReq rbacv1.AuthRequest) (bool, error)
+}
+
+var authorizer Authorizer
+
+// SetAuthorizer sets the RBAC authorizer used for namespace visibility.
+// Without one, namespace links can only be resolved by their owner.
+func SetAuthorizer(a Authorizer) {
+	authorizer = a
+}
+
+// validateVisibility checks the visibility of a create request.
+// Anonymous links have no owner, so they can not be private or namespace restricted.
+func validateVisibility(visibility, namespace string, anonymous bool) error {
+	switch visibility {
+	case "", VisibilityPublic, VisibilityUnlisted:
+		return nil
+	case VisibilityPrivate:
+		if anonymous {
+			return errors.New("public short URLs can not be private")
+		}
+		return nil
+	case VisibilityNamespace:
+		if anonymous {
+			return errors.New("public short URLs can not be namespace restricted")
+		}
+		if namespace == "" {
+			return errors.New("namespace is required for namespace visibility")
+		}
+		return nil
+	default:
+		return errors.New("visibility must be one of public, unlisted, private, namespace")
+	}
+}
+
+// linkVisibility returns the visibility of link. Links stored without one keep the
+// default of their type: user links are private, as they were before visibility
+// existed, and public short URLs are public.
+func linkVisibility(link database.Link) string {
+	if v := link.GetOptions().Visibility; v != "" {
+		return v
+	}
+	if link.GetOwnerID() != "" {
+		return VisibilityPrivate
+	}
+	return VisibilityPublic
+}
+
+// CanAccess reports whether the caller of c may resolve link.
+//
+// The caller is identified by the "user_id" (and "email") set by the JWT middlewares.
+// The owner can always resolve their own links.
+func CanAccess(c *gin.Context, link database.Link) bool {
+	visibility := linkVisibility(link)
+	switch visibility {
+	case VisibilityPrivate, VisibilityNamespace:
+	default:
+		return true
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		return false
+	}
+	if userID == link.GetOwnerID() {
+		return true
+	}
+	if visibility == VisibilityPrivate || authorizer == nil {
+		return false
+	}
+
+	return authorizeCaller(c, "get", "urls", link.GetOptions().Namespace)
+}
+
+// authorizeCaller asks the RBAC system whether the caller of c may do verb on resource
+// in namespace. RBAC subjects may be named by user ID or by email.
+func authorizeCaller(c *gin.Context, verb, resource, namespace string) bool {
+	if authorizer == nil {
+		return false
+	}
+	for _, name := range []string{c.GetString("user_id"), c.GetString("email")} {
+		if name == "" {
+			continue
+		}
+		allowed, err := authorizer.Authorize(rbacv1.AuthRequest{
+			Name:      name,
+			Verb:      verb,
+			Resource:  resource,
+			Namespace: namespace,
+		})
+		if err != nil {
+			log.Warn().Err(err).Str("namespace", namespace).Str("verb", verb).Msg("Failed to authorize caller")
+			return false
+		}
+		if allowed {
+			return true
+		}
+	}
+	return false
+}
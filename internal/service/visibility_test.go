@@ -0,0 +1,94 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"url-shortener/internal/pkg/database"
+	rbacv1 "url-shortener/pkg/apis/rbac/v1"
+)
+
+// fakeAuthorizer allows get on urls for the listed subjects in one namespace.
+type fakeAuthorizer struct {
+	namespace string
+	subjects  []string
+}
+
+func (f fakeAuthorizer) Authorize(req rbacv1.AuthRequest) (bool, error) {
+	if req.Namespace != f.namespace || req.Verb != "get" || req.Resource != "urls" {
+		return false, nil
+	}
+	for _, s := range f.subjects {
+		if s == req.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestCanAccess(t *testing.T) {
+	SetAuthorizer(fakeAuthorizer{namespace: "marketing", subjects: []string{"member@example.com"}})
+	defer SetAuthorizer(nil)
+
+	link := func(visibility, namespace string) database.UserShortURL {
+		return database.UserShortURL{
+			UserID:      "owner",
+			ShortCode:   "abc123",
+			LinkOptions: database.LinkOptions{Visibility: visibility, Namespace: namespace},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		link   database.UserShortURL
+		userID string
+		email  string
+		want   bool
+	}{
+		{name: "unlisted anonymous", link: link(VisibilityUnlisted, ""), want: true},
+		{name: "private anonymous", link: link(VisibilityPrivate, ""), want: false},
+		{name: "private other user", link: link(VisibilityPrivate, ""), userID: "other", want: false},
+		{name: "private owner", link: link(VisibilityPrivate, ""), userID: "owner", want: true},
+		{name: "namespace member", link: link(VisibilityNamespace, "marketing"), userID: "u1", email: "member@example.com", want: true},
+		{name: "namespace outsider", link: link(VisibilityNamespace, "marketing"), userID: "u2", email: "outsider@example.com", want: false},
+		{name: "namespace anonymous", link: link(VisibilityNamespace, "marketing"), want: false},
+		{name: "default anonymous", link: link("", ""), want: false},
+		{name: "default owner", link: link("", ""), userID: "owner", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testContext(httptest.NewRecorder(), http.MethodGet, "/abc123", nil)
+			if tt.userID != "" {
+				c.Set("user_id", tt.userID)
+				c.Set("email", tt.email)
+			}
+			if got := CanAccess(c, tt.link); got != tt.want {
+				t.Errorf("CanAccess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkVisibilityDefault(t *testing.T) {
+	if got := linkVisibility(database.UserShortURL{UserID: "owner"}); got != VisibilityPrivate {
+		t.Errorf("user link visibility = %q, want private", got)
+	}
+	if got := linkVisibility(database.PublicShortURL{}); got != VisibilityPublic {
+		t.Errorf("public link visibility = %q, want public", got)
+	}
+}
+
+func TestValidateVisibility(t *testing.T) {
+	if err := validateVisibility(VisibilityPrivate, "", true); err == nil {
+		t.Error("expected anonymous private link to be rejected")
+	}
+	if err := validateVisibility(VisibilityNamespace, "", false); err == nil {
+		t.Error("expected namespace link without namespace to be rejected")
+	}
+	if err := validateVisibility("secret", "", false); err == nil {
+		t.Error("expected unknown visibility to be rejected")
+	}
+	if err := validateVisibility(VisibilityUnlisted, "", true); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
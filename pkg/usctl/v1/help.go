@@ -44,6 +44,8 @@ func PrintGlobalHelp() {
 	fmt.Println("  usctl apply -f config.yaml")
 	fmt.Println("\n  # Get all short URLs")
 	fmt.Println("  usctl get shorturl")
+	fmt.Println("\n  # Import a YOURLS database dump")
+	fmt.Println("  usctl import -f yourls.sql --format yourls-sql")
 }
 
 func PrintHelp() {
@@ -89,3 +91,40 @@ func PrintApplyHelp(fs *pflag.FlagSet) {
 		fmt.Println(usage)
 	})
 }
+
+func PrintImportHelp(fs *pflag.FlagSet) {
+	fmt.Println("Import links from a file exported by this or another shortener")
+
+	fmt.Println("\nUsage:")
+	fmt.Printf("  usctl import [flags] -f FILENAME\n")
+
+	fmt.Println("\nExamples:")
+	fmt.Printf("  # Import a YOURLS database dump\n")
+	fmt.Printf("  usctl import -f yourls.sql --format yourls-sql\n\n")
+	fmt.Printf("  # Import a Bitly export for another user, skipping taken codes\n")
+	fmt.Printf("  usctl import -f bitly.csv --format bitly --owner 42 --on-conflict skip\n\n")
+	fmt.Printf("  # Import any CSV file with a column mapping\n")
+	fmt.Printf("  usctl import -f links.csv --columns \"code=Keyword,url=Target,clicks=Hits\"\n")
+
+	fmt.Println("\nFlags:")
+	fs.VisitAll(func(f *pflag.Flag) {
+		var usage string
+		if f.Shorthand == "" {
+			usage = fmt.Sprintf("      --%s", f.Name)
+		} else {
+			usage = fmt.Sprintf("  -%s, --%s", f.Shorthand, f.Name)
+		}
+
+		if len(usage) < 20 {
+			usage += strings.Repeat(" ", 20-len(usage))
+		}
+
+		if f.DefValue != "" {
+			usage += fmt.Sprintf("[default: %s]  ", f.DefValue)
+		}
+
+		usage += f.Usage
+
+		fmt.Println(usage)
+	})
+}
@@ -0,0 +1,125 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"url-shortener/pkg/usctl"
+
+	"github.com/spf13/pflag"
+)
+
+const adminImportAPI = "/v1/auth/admin/import"
+
+// formatFromExt guesses the import format when --format is not given.
+var formatFromExt = map[string]string{
+	".csv":    "csv",
+	".ndjson": "ndjson",
+	".jsonl":  "ndjson",
+	".html":   "html",
+	".htm":    "html",
+	".sql":    "yourls-sql",
+}
+
+// importHandler is a Command handler for import operation.
+func importHandler(args ...string) {
+	importFlags := &importFlags{}
+
+	importCmd := pflag.NewFlagSet("import", pflag.ExitOnError)
+	importCmd.StringVarP(&importFlags.filePath, "file", "f", "", "File to import")
+	importCmd.StringVarP(&importFlags.format, "format", "", "", "File format: csv, ndjson, html, yourls-sql, yourls-csv or bitly")
+	importCmd.StringVarP(&importFlags.columns, "columns", "", "", "Column mapping of a CSV file, e.g. \"code=Keyword,url=Target,clicks=Hits\"")
+	importCmd.StringVarP(&importFlags.owner, "owner", "", "", "User ID owning the imported links, defaults to the caller")
+	importCmd.StringVarP(&importFlags.onConflict, "on-conflict", "", "rename", "What to do with taken short codes: rename or skip")
+	importCmd.StringVarP(&importFlags.server, "server", "s", "http://localhost:8080", "API Server address")
+	importCmd.StringVarP(&importFlags.token, "token", "t", os.Getenv("USCTL_TOKEN"), "Access token, defaults to $USCTL_TOKEN")
+	importCmd.BoolVarP(&importFlags.showHelp, "help", "h", false, "Print help message")
+
+	importCmd.Parse(args)
+
+	if importFlags.showHelp {
+		PrintImportHelp(importCmd)
+		return
+	}
+	if importFlags.filePath == "" {
+		fmt.Fprintln(os.Stderr, "error: must specify -f")
+		PrintHelp()
+		os.Exit(1)
+	}
+
+	if err := processImportCommand(importFlags); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func processImportCommand(flags *importFlags) error {
+	file, err := os.Open(flags.filePath)
+	if err != nil {
+		return fmt.Errorf("%w: \""+flags.filePath+"\"", usctl.ErrFileNotFound)
+	}
+	defer file.Close()
+
+	if flags.format == "" && flags.columns == "" {
+		flags.format = formatFromExt[strings.ToLower(filepath.Ext(flags.filePath))]
+	}
+
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"format":      flags.format,
+		"columns":     flags.columns,
+		"owner":       flags.owner,
+		"on_conflict": flags.onConflict,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(flags.server, "/")+adminImportAPI+"?"+query.Encode(), file)
+	if err != nil {
+		return fmt.Errorf("%w: %v", usctl.ErrNewRequest, err)
+	}
+	req.Header.Set("Authorization", flags.token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// 大文件导入可能耗时较长
+	client := http.Client{Timeout: 10 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body importResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("import failed: %s", resp.Status)
+	}
+
+	p := NewTablePrinter(os.Stdout)
+	p.PrintHeader("Row", "Short Code", "Original Code", "Status")
+	for _, r := range body.Results {
+		status := "created"
+		if r.ShortURL == "" {
+			status = r.Code + ": " + r.Error
+		} else if r.OriginalCode != "" {
+			status = "renamed"
+		}
+		p.PrintRow(fmt.Sprint(r.Row), r.ShortURL, r.OriginalCode, status)
+	}
+	p.Flush()
+	fmt.Printf("\n%d created, %d failed\n", body.Created, body.Failed)
+
+	if resp.StatusCode != http.StatusOK {
+		if body.Error == "" {
+			body.Error = resp.Status
+		}
+		return fmt.Errorf("import failed: %s", body.Error)
+	}
+	return nil
+}
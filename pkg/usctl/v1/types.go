@@ -41,6 +41,18 @@ type applyFlags struct {
 	dryRun bool
 }
 
+// Special fields of import command.
+type importFlags struct {
+	filePath   string
+	format     string
+	columns    string
+	owner      string
+	onConflict string
+	server     string
+	token      string
+	showHelp   bool
+}
+
 type getFlags struct {
 	namespace     string
 	allNamespaces bool
@@ -78,3 +90,18 @@ type TypedResponse[T any] struct {
 	Data    T      `json:"data,omitempty"`
 	Error   string `json:"error,omitempty"`
 }
+
+// importResponse is the response of the admin import API.
+type importResponse struct {
+	Created int    `json:"created"`
+	Failed  int    `json:"failed"`
+	Error   string `json:"error,omitempty"`
+	Results []struct {
+		Row          int    `json:"row"`
+		OriginalURL  string `json:"original_url"`
+		ShortURL     string `json:"short_url"`
+		OriginalCode string `json:"original_code"`
+		Code         string `json:"code"`
+		Error        string `json:"error"`
+	} `json:"results"`
+}
@@ -25,6 +25,11 @@ var CLI = &cli{
 			Description: "Get all specified resources. Only one can be specified at a time.",
 			Handler:     getHandler,
 		},
+		"import": {
+			Name:        "import",
+			Description: "Import links from a file exported by another shortener",
+			Handler:     importHandler,
+		},
 	},
 	Debug:   false,
 	Help:    false,